@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// arithParser is a recursive-descent parser for $((EXPR)) integer
+// expressions with + - * / %, unary signs and parentheses.
+type arithParser struct {
+	expr string
+	pos  int
+}
+
+// expandArithmetic replaces every $((EXPR)) of a masked, already expanded
+// string by its integer value. Expressions that still hold a deferred
+// placeholder are kept for a later stage.
+func expandArithmetic(in string) (string, error) {
+	if !strings.Contains(in, "$((") {
+		return in, nil
+	}
+
+	var out strings.Builder
+	out.Grow(len(in))
+
+	for {
+		start := strings.Index(in, "$((")
+		if start < 0 {
+			out.WriteString(in)
+			break
+		}
+
+		out.WriteString(in[:start])
+		end, err := arithmeticEnd(in, start+3)
+		if err != nil {
+			return "", &ExpandError{Err: err}
+		}
+
+		expr := in[start+3 : end]
+		if strings.Contains(expr, maskStart) {
+			out.WriteString(in[start : end+2])
+		} else {
+			value, err := evalArithmetic(expr)
+			if err != nil {
+				return "", &ExpandError{Err: err}
+			}
+
+			out.WriteString(strconv.FormatInt(value, 10))
+		}
+
+		in = in[end+2:]
+	}
+
+	return out.String(), nil
+}
+
+// arithmeticEnd returns the offset of the "))" closing the expression that
+// starts at offset start, skipping balanced inner parentheses.
+func arithmeticEnd(in string, start int) (int, error) {
+	depth := 0
+	for i := start; i < len(in); i++ {
+		switch in[i] {
+		case '(':
+			depth++
+
+		case ')':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if i+1 < len(in) && in[i+1] == ')' {
+				return i, nil
+			}
+
+			return 0, fmt.Errorf("%w: unbalanced parentheses", ErrArithmetic)
+		}
+	}
+
+	return 0, fmt.Errorf("%w: missing closing ))", ErrArithmetic)
+}
+
+// evalArithmetic evaluates an integer expression.
+func evalArithmetic(expr string) (int64, error) {
+	p := &arithParser{expr: expr}
+	value, err := p.parseSum()
+	if err != nil {
+		return 0, err
+	}
+
+	if p.skipSpace(); p.pos < len(p.expr) {
+		return 0, p.unexpected()
+	}
+
+	return value, nil
+}
+
+// parseSum parses terms joined by + and -.
+func (p *arithParser) parseSum() (int64, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.expr) || (p.expr[p.pos] != '+' && p.expr[p.pos] != '-') {
+			return left, nil
+		}
+
+		op := p.expr[p.pos]
+		p.pos++
+		right, err := p.parseProduct()
+		if err != nil {
+			return 0, err
+		}
+
+		if op == '-' {
+			diff := left - right
+			if (right > 0 && diff > left) || (right < 0 && diff < left) {
+				return 0, errOverflow()
+			}
+			left = diff
+			continue
+		}
+
+		sum := left + right
+		if (right > 0 && sum < left) || (right < 0 && sum > left) {
+			return 0, errOverflow()
+		}
+		left = sum
+	}
+}
+
+// parseProduct parses factors joined by *, / and %.
+func (p *arithParser) parseProduct() (int64, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.expr) || !strings.ContainsRune("*/%", rune(p.expr[p.pos])) {
+			return left, nil
+		}
+
+		op := p.expr[p.pos]
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case op == '*':
+			product := left * right
+			if left != 0 && (product/left != right || (left == -1 && right == math.MinInt64)) {
+				return 0, errOverflow()
+			}
+			left = product
+		case right == 0:
+			return 0, fmt.Errorf("%w: division by zero", ErrArithmetic)
+		case op == '/':
+			if left == math.MinInt64 && right == -1 {
+				return 0, errOverflow()
+			}
+			left /= right
+		default:
+			left %= right
+		}
+	}
+}
+
+// parseUnary parses an optionally signed number or parenthesized expression.
+func (p *arithParser) parseUnary() (int64, error) {
+	p.skipSpace()
+	if p.pos >= len(p.expr) {
+		return 0, fmt.Errorf("%w: unexpected end of expression", ErrArithmetic)
+	}
+
+	switch c := p.expr[p.pos]; {
+	case c == '+' || c == '-':
+		p.pos++
+		value, err := p.parseUnary()
+		if err != nil || c == '+' {
+			return value, err
+		}
+
+		return negateInt(value)
+
+	case c == '(':
+		p.pos++
+		value, err := p.parseSum()
+		if err != nil {
+			return 0, err
+		}
+
+		if p.skipSpace(); p.pos >= len(p.expr) || p.expr[p.pos] != ')' {
+			return 0, fmt.Errorf("%w: missing )", ErrArithmetic)
+		}
+		p.pos++
+
+		return value, nil
+
+	case c >= '0' && c <= '9':
+		start := p.pos
+		for p.pos < len(p.expr) && p.expr[p.pos] >= '0' && p.expr[p.pos] <= '9' {
+			p.pos++
+		}
+
+		value, err := strconv.ParseInt(p.expr[start:p.pos], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q is out of range", ErrArithmetic, p.expr[start:p.pos])
+		}
+
+		return value, nil
+	}
+
+	return 0, p.unexpected()
+}
+
+// negateInt returns -value, failing for the one value without a positive
+// counterpart.
+func negateInt(value int64) (int64, error) {
+	if value == math.MinInt64 {
+		return 0, errOverflow()
+	}
+
+	return -value, nil
+}
+
+// errOverflow reports a result outside the int64 range.
+func errOverflow() error {
+	return fmt.Errorf("%w: overflow", ErrArithmetic)
+}
+
+// unexpected reports the operand at the current position, which is not an
+// integer, operator or parenthesis.
+func (p *arithParser) unexpected() error {
+	end := p.pos + 1
+	for end < len(p.expr) && !strings.ContainsRune(" \t+-*/%()", rune(p.expr[end])) {
+		end++
+	}
+
+	return fmt.Errorf("%w: %q is not an integer", ErrArithmetic, p.expr[p.pos:end])
+}
+
+// skipSpace advances past blanks.
+func (p *arithParser) skipSpace() {
+	for p.pos < len(p.expr) && (p.expr[p.pos] == ' ' || p.expr[p.pos] == '\t') {
+		p.pos++
+	}
+}
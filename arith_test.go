@@ -0,0 +1,87 @@
+package jamle
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpandStringWithOptions_Arithmetic(t *testing.T) {
+	env := map[string]string{"PORT": "9000", "NAME": "api", "ZERO": "0"}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		err   error
+		off   bool
+	}{
+		{name: "variable operand", input: "$((${PORT}+1))", want: "9001"},
+		{name: "default operand", input: "$((${MISSING:-8080}+1))", want: "8081"},
+		{name: "precedence", input: "$((2+3*4))", want: "14"},
+		{name: "parentheses", input: "$(( (2+3) * 4 ))", want: "20"},
+		{name: "left associative", input: "$((20-5-3)) $((64/4/2))", want: "12 8"},
+		{name: "modulo and unary", input: "$((-7 % 3)) $((-(2-5)))", want: "-1 3"},
+		{name: "embedded", input: "port-$((${PORT}*2))", want: "port-18000"},
+		{name: "escaped", input: "$$((1+1))", want: "$((1+1))"},
+		{name: "disabled", input: "$((1+1))", want: "$((1+1))", off: true},
+		{name: "division by zero", input: "$((1/${ZERO}))", err: ErrArithmetic},
+		{name: "modulo by zero", input: "$((1%0))", err: ErrArithmetic},
+		{name: "non-integer operand", input: "$((${NAME}+1))", err: ErrArithmetic},
+		{name: "float operand", input: "$((1.5+1))", err: ErrArithmetic},
+		{name: "unterminated", input: "$((1+1)", err: ErrArithmetic},
+		{name: "empty", input: "$(())", err: ErrArithmetic},
+		{name: "int64 bounds", input: "$((9223372036854775806+1)) $((-9223372036854775807-1))", want: "9223372036854775807 -9223372036854775808"},
+		{name: "addition overflow", input: "$((9223372036854775807+1))", err: ErrArithmetic},
+		{name: "subtraction overflow", input: "$((-9223372036854775807-2))", err: ErrArithmetic},
+		{name: "subtracting min", input: "$((-1-(-9223372036854775807-1)))", want: "9223372036854775807"},
+		{name: "subtracting min overflow", input: "$((0-(-9223372036854775807-1)))", err: ErrArithmetic},
+		{name: "multiplication overflow", input: "$((4611686018427387904*2))", err: ErrArithmetic},
+		{name: "negative multiplication overflow", input: "$((-1*(-9223372036854775807-1)))", err: ErrArithmetic},
+		{name: "division overflow", input: "$(((-9223372036854775807-1)/-1))", err: ErrArithmetic},
+		{name: "negation overflow", input: "$((-(-9223372036854775807-1)))", err: ErrArithmetic},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandStringWithOptions(tt.input, UnmarshalOptions{Env: env, Arithmetic: !tt.off})
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v (result %q)", tt.err, err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalWithOptions_Arithmetic(t *testing.T) {
+	var cfg struct {
+		Port        int `yaml:"port"`
+		MetricsPort int `yaml:"metrics_port"`
+	}
+
+	data := []byte("port: ${PORT:-8080}\nmetrics_port: $((${PORT:-8080}+1))\n")
+	opts := UnmarshalOptions{Env: map[string]string{}, Arithmetic: true}
+	if err := UnmarshalWithOptions(data, &cfg, opts); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+
+	if cfg.Port != 8080 || cfg.MetricsPort != 8081 {
+		t.Fatalf("got %+v", cfg)
+	}
+
+	var plain map[string]any
+	if err := UnmarshalWithOptions([]byte("n: $((6*7))\n"), &plain, opts); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+
+	if plain["n"] != 42 {
+		t.Fatalf("got %#v, want 42", plain["n"])
+	}
+}
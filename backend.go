@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// BackendFunc resolves a key for a named resolver backend registered with
+// RegisterResolver.
+type BackendFunc func(key string) (string, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]BackendFunc)
+)
+
+// RegisterResolver registers fn as the backend for `${name:key}` placeholders,
+// e.g. `${vault:secret/db#password}` calls the "vault" backend with key
+// "secret/db#password". Placeholders without a registered prefix keep
+// resolving through the environment or UnmarshalOptions.Resolver.
+// A registered name takes precedence over the `${VAR:default}` plain form
+// for that name, but not over operators: `${vault:-x}`, `${vault:=x}`,
+// `${vault:?msg}` and `${vault:+x}` still read the variable "vault", so
+// backend keys cannot start with -, =, ? or +. Backends are process-global
+// and apply to every call, including calls with an isolated
+// UnmarshalOptions.Env. Passing a nil fn removes the backend.
+func RegisterResolver(name string, fn BackendFunc) error {
+	if !isValidVarName(name) {
+		return fmt.Errorf("%w %q for resolver backend", ErrInvalidVariableName, name)
+	}
+
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if fn == nil {
+		delete(backends, name)
+		return nil
+	}
+
+	backends[name] = fn
+	return nil
+}
+
+// lookupBackend returns the backend registered under name.
+func lookupBackend(name string) (BackendFunc, bool) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	fn, ok := backends[name]
+	return fn, ok
+}
+
+// backendFor returns the backend for the placeholder `${name:rest}`, where
+// rest follows the colon. A rest starting with an expansion operator keeps
+// its Bash meaning.
+func backendFor(name, rest string) (BackendFunc, bool) {
+	if rest != "" && isExpansionOperator(rest[0]) {
+		return nil, false
+	}
+
+	return lookupBackend(name)
+}
+
+// resolveBackend resolves key through a named backend once per scalar.
+// The value is used literally: ${...} inside it is not expanded.
+func (st *scalarState) resolveBackend(name, key string, fn BackendFunc) (string, error) {
+	st.usedBackend = true
+	cacheKey := name + ":" + key
+	if got, ok := st.envCache[cacheKey]; ok {
+		return got.value, nil
+	}
+
+	value, err := fn(key)
+	if err != nil {
+		return "", fmt.Errorf("%w %q for key %q: %w", ErrResolverBackend, name, key, err)
+	}
+
+	if strings.ContainsAny(value, maskStart+maskEnd+maskDollar) {
+		return "", fmt.Errorf("%w: control character in %q value for key %q", ErrBinaryInput, name, key)
+	}
+
+	value = strings.ReplaceAll(value, "${", maskStart)
+	st.envCache[cacheKey] = envLookup{value: value, exists: true}
+	return value, nil
+}
@@ -0,0 +1,150 @@
+package jamle
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegisterResolver(t *testing.T) {
+	secrets := map[string]string{
+		"secret/db#password":   "s3cr3t",
+		"secret/prod#password": "prod-pass",
+		"secret/raw#value":     "${NOT_EXPANDED}",
+	}
+	calls := 0
+	if err := RegisterResolver("vault", func(key string) (string, error) {
+		calls++
+		value, ok := secrets[key]
+		if !ok {
+			return "", errors.New("secret not found")
+		}
+		return value, nil
+	}); err != nil {
+		t.Fatalf("RegisterResolver returned error: %v", err)
+	}
+	t.Cleanup(func() { _ = RegisterResolver("vault", nil) })
+
+	env := map[string]string{"STAGE": "prod", "USER_NAME": "app"}
+	input := []byte(`
+user: ${USER_NAME}
+password: ${vault:secret/db#password}
+again: ${vault:secret/db#password}
+stage: ${vault:secret/${STAGE}#password}
+raw: ${vault:secret/raw#value}
+`)
+
+	var got map[string]string
+	if err := UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: env}); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+
+	want := map[string]string{
+		"user":     "app",
+		"password": "s3cr3t",
+		"again":    "s3cr3t",
+		"stage":    "prod-pass",
+		"raw":      "${NOT_EXPANDED}",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("value mismatch:\ngot  %#v\nwant %#v", got, want)
+	}
+	if calls != 4 {
+		t.Fatalf("backend calls mismatch: got %d, want 4", calls)
+	}
+
+	_, err := ExpandStringWithOptions("${vault:secret/missing#key}", UnmarshalOptions{Env: env})
+	if !errors.Is(err, ErrResolverBackend) {
+		t.Fatalf("expected ErrResolverBackend, got %v", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, `"vault"`) || !strings.Contains(msg, `"secret/missing#key"`) {
+		t.Fatalf("error should name backend and key, got %q", msg)
+	}
+
+	vars, err := ExtractVariables(input)
+	if err != nil {
+		t.Fatalf("ExtractVariables returned error: %v", err)
+	}
+	if want := []string{"STAGE", "USER_NAME"}; !reflect.DeepEqual(vars, want) {
+		t.Fatalf("ExtractVariables mismatch: got %v, want %v", vars, want)
+	}
+}
+
+func TestRegisterResolver_Unregistered(t *testing.T) {
+	env := map[string]string{"file": "plain"}
+
+	got, err := ExpandStringWithOptions("${file:/etc/hostname}", UnmarshalOptions{Env: env})
+	if err != nil {
+		t.Fatalf("ExpandStringWithOptions returned error: %v", err)
+	}
+	if got != "plain" {
+		t.Fatalf("unregistered prefix should expand as ${VAR:...}, got %q", got)
+	}
+
+	if err := RegisterResolver("bad-name", func(string) (string, error) { return "", nil }); !errors.Is(err, ErrInvalidVariableName) {
+		t.Fatalf("expected ErrInvalidVariableName, got %v", err)
+	}
+}
+
+func TestRegisterResolver_Operators(t *testing.T) {
+	calls := 0
+	if err := RegisterResolver("vault", func(key string) (string, error) {
+		calls++
+		return "backend:" + key, nil
+	}); err != nil {
+		t.Fatalf("RegisterResolver returned error: %v", err)
+	}
+	t.Cleanup(func() { _ = RegisterResolver("vault", nil) })
+
+	env := map[string]string{"SET": "x"}
+	tests := []struct {
+		name  string
+		input string
+		env   map[string]string
+		want  string
+		err   string
+	}{
+		{name: "default", input: "${vault:-fallback}", want: "fallback"},
+		{name: "default when set", input: "${vault:-fallback}", env: map[string]string{"vault": "env"}, want: "env"},
+		{name: "assign", input: "${vault:=x}/${vault}", want: "x/x"},
+		{name: "alternate", input: "${vault:+alt}", env: map[string]string{"vault": "env"}, want: "alt"},
+		{name: "required", input: "${vault:?m}", err: `"vault" m`},
+		{name: "backend key", input: "${vault:a-b}", want: "backend:a-b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := UnmarshalOptions{Env: env}
+			if tt.env != nil {
+				opts.Env = tt.env
+			}
+
+			got, err := ExpandStringWithOptions(tt.input, opts)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v (result %q)", tt.err, err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if calls != 1 {
+		t.Fatalf("backend calls mismatch: got %d, want 1", calls)
+	}
+
+	vars, err := ExtractVariables([]byte("a: ${vault:-x}\nb: ${vault:key}\n"))
+	if err != nil {
+		t.Fatalf("ExtractVariables returned error: %v", err)
+	}
+	if want := []string{"vault"}; !reflect.DeepEqual(vars, want) {
+		t.Fatalf("ExtractVariables mismatch: got %v, want %v", vars, want)
+	}
+}
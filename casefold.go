@@ -0,0 +1,79 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"fmt"
+	"iter"
+	"os"
+	"slices"
+	"strings"
+)
+
+// foldEnvNames indexes variable names by their upper-cased form for
+// CaseInsensitive lookups. Each entry lists the sorted original names.
+// With a prefix, prefixed names are also indexed without it, since the
+// prefix resolver adds it back.
+func foldEnvNames(names iter.Seq[string], prefix string) map[string][]string {
+	folded := make(map[string][]string)
+	for name := range names {
+		addFoldedName(folded, name)
+		if rest, ok := strings.CutPrefix(name, prefix); ok && prefix != "" && rest != "" {
+			addFoldedName(folded, rest)
+		}
+	}
+
+	return folded
+}
+
+// addFoldedName adds name to the folded index, keeping entries sorted.
+func addFoldedName(folded map[string][]string, name string) {
+	key := strings.ToUpper(name)
+	list := folded[key]
+	if i, found := slices.BinarySearch(list, name); !found {
+		folded[key] = slices.Insert(list, i, name)
+	}
+}
+
+// processEnvNames yields the variable names of the process environment.
+func processEnvNames() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for _, kv := range os.Environ() {
+			name, _, ok := cutEnvEntry(kv)
+			if ok && !yield(name) {
+				return
+			}
+		}
+	}
+}
+
+// foldName returns the variable name to look up for name. Without a folded
+// index, or when name itself is set, it is returned unchanged.
+func (opts *runtimeOptions) foldName(name string) (string, error) {
+	if opts.foldedNames == nil {
+		return name, nil
+	}
+
+	candidates := opts.foldedNames[strings.ToUpper(name)]
+	switch {
+	case len(candidates) == 0 || slices.Contains(candidates, name):
+		return name, nil
+	case len(candidates) == 1:
+		return candidates[0], nil
+	}
+
+	return "", fmt.Errorf("%w: %q matches %s", ErrAmbiguousVariable, name, strings.Join(candidates, ", "))
+}
+
+// lookupFolded resolves name through the resolver after case folding.
+func (opts *runtimeOptions) lookupFolded(name string) (string, bool, error) {
+	lookupName, err := opts.foldName(name)
+	if err != nil {
+		return "", false, err
+	}
+
+	value, ok := opts.resolver.Lookup(lookupName)
+	return value, ok, nil
+}
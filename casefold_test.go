@@ -0,0 +1,142 @@
+package jamle
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpandStringWithOptions_CaseInsensitive(t *testing.T) {
+	env := map[string]string{"HOST": "db", "Port": "5432", "MODE": "upper", "mode": "lower", "Mode": "title"}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		err   error
+	}{
+		{name: "lower matches upper", input: "${host}", want: "db"},
+		{name: "upper matches mixed", input: "${PORT}", want: "5432"},
+		{name: "exact match wins", input: "${mode}/${MODE}", want: "lower/upper"},
+		{name: "unset uses default", input: "${missing:-x}", want: "x"},
+		{name: "ambiguous", input: "${MoDe}", err: ErrAmbiguousVariable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandStringWithOptions(tt.input, UnmarshalOptions{Env: env, CaseInsensitive: true})
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v (result %q)", tt.err, err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	got, err := ExpandStringWithOptions("${host:-none}", UnmarshalOptions{Env: env})
+	if err != nil || got != "none" {
+		t.Fatalf("case-sensitive default: got %q, %v", got, err)
+	}
+
+	t.Setenv("JAMLE_FOLD_TEST", "process")
+	got, err = ExpandStringWithOptions("${jamle_fold_test}", UnmarshalOptions{CaseInsensitive: true})
+	if err != nil || got != "process" {
+		t.Fatalf("process environment: got %q, %v", got, err)
+	}
+}
+
+func TestExpandStringWithOptions_CaseInsensitiveIndex(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  UnmarshalOptions
+		want  string
+	}{
+		{
+			name:  "prefixed name",
+			input: "${host}",
+			opts:  UnmarshalOptions{Env: map[string]string{"APP_HOST": "ph"}, Prefix: "APP_"},
+			want:  "ph",
+		},
+		{
+			name:  "prefixed name wins over plain",
+			input: "${host}",
+			opts:  UnmarshalOptions{Env: map[string]string{"APP_HOST": "ph", "HOST": "h"}, Prefix: "APP_"},
+			want:  "ph",
+		},
+		{
+			name:  "assigned name",
+			input: "${X:=1}${x}",
+			opts:  UnmarshalOptions{Env: map[string]string{}},
+			want:  "11",
+		},
+		{
+			name:  "assigned prefixed name",
+			input: "${X:=1}${x}",
+			opts:  UnmarshalOptions{Env: map[string]string{}, Prefix: "APP_"},
+			want:  "11",
+		},
+		{
+			name:  "defaults key",
+			input: "${host}",
+			opts:  UnmarshalOptions{Env: map[string]string{}, Defaults: map[string]string{"HOST": "d"}},
+			want:  "d",
+		},
+		{
+			name:  "environment wins over defaults key",
+			input: "${host}",
+			opts:  UnmarshalOptions{Env: map[string]string{"Host": "e"}, Defaults: map[string]string{"HOST": "d"}},
+			want:  "e",
+		},
+		{
+			name:  "folded environment beats exact defaults key",
+			input: "${HOST}",
+			opts:  UnmarshalOptions{Env: map[string]string{"Host": "e"}, Defaults: map[string]string{"HOST": "d"}},
+			want:  "e",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.CaseInsensitive = true
+			got, err := ExpandStringWithOptions(tt.input, tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCaseInsensitive_SetOnlyAndDumpEnv(t *testing.T) {
+	env := map[string]string{"DB_HOST": "h", "Mode": "a", "MODE": "b"}
+	opts := UnmarshalOptions{Env: env, CaseInsensitive: true, SetOnly: true}
+
+	got, err := ExpandStringWithOptions("${db_host}/${missing}", opts)
+	if err != nil {
+		t.Fatalf("ExpandStringWithOptions returned error: %v", err)
+	}
+	if got != "h/${missing}" {
+		t.Fatalf("SetOnly: got %q, want %q", got, "h/${missing}")
+	}
+
+	if _, err := ExpandStringWithOptions("${mode}", opts); !errors.Is(err, ErrAmbiguousVariable) {
+		t.Fatalf("SetOnly ambiguous: expected ErrAmbiguousVariable, got %v", err)
+	}
+
+	dump, err := DumpEnv([]byte("a: ${db_host}\nb: ${missing:-x}\n"), UnmarshalOptions{Env: env, CaseInsensitive: true})
+	if err != nil {
+		t.Fatalf("DumpEnv returned error: %v", err)
+	}
+	if want := "db_host=h\n"; string(dump) != want {
+		t.Fatalf("DumpEnv: got %q, want %q", dump, want)
+	}
+}
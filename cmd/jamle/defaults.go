@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadDefaultsFile reads KEY=VALUE definitions from path.
+func loadDefaultsFile(path string) (map[string]string, error) {
+	// #nosec G304 -- CLI intentionally reads a user-provided local file path.
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	defaults, err := parseDefaults(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return defaults, nil
+}
+
+// parseDefaults parses dotenv-style KEY=VALUE lines. Blank lines and lines
+// starting with '#' are ignored, an optional "export " prefix is allowed,
+// and values may be wrapped in single or double quotes.
+func parseDefaults(data []byte) (map[string]string, error) {
+	defaults := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+
+		key = strings.TrimSpace(key)
+		if !isValidDefaultsKey(key) {
+			return nil, fmt.Errorf("line %d: invalid variable name %q", lineNo, key)
+		}
+
+		defaults[key] = unquoteDefaultsValue(strings.TrimSpace(value))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return defaults, nil
+}
+
+// isValidDefaultsKey reports whether key matches [A-Za-z_][A-Za-z0-9_]*.
+func isValidDefaultsKey(key string) bool {
+	if key == "" {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// unquoteDefaultsValue strips one pair of matching surrounding quotes.
+func unquoteDefaultsValue(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if (first == '"' || first == '\'') && first == last {
+			return value[1 : len(value)-1]
+		}
+	}
+
+	return value
+}
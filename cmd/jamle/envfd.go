@@ -0,0 +1,60 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// readEnvFD reads a NUL-delimited NAME=VALUE environment from file
+// descriptor fd, as written by `env -0` or /proc/PID/environ.
+func readEnvFD(fd int, maxBytes int64) (map[string]string, error) {
+	if fd < 0 {
+		return nil, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("fd %d", fd))
+	if file == nil {
+		return nil, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("environment exceeds --max-bytes (%d bytes)", maxBytes)
+	}
+
+	return parseEnvRecords(data)
+}
+
+// parseEnvRecords parses NAME=VALUE records separated by NUL bytes.
+// Empty records are skipped; for duplicate names the last record wins.
+// Names are kept as is, like os.Environ, so records such as exported bash
+// functions (BASH_FUNC_f%%) do not reject the environment.
+func parseEnvRecords(data []byte) (map[string]string, error) {
+	env := make(map[string]string)
+	for i, record := range bytes.Split(data, []byte{0}) {
+		if len(record) == 0 {
+			continue
+		}
+
+		name, value, ok := bytes.Cut(record, []byte("="))
+		if !ok {
+			return nil, fmt.Errorf("record %d: expected NAME=VALUE, got %q", i+1, record)
+		}
+
+		env[string(name)] = string(value)
+	}
+
+	return env, nil
+}
@@ -0,0 +1,128 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"github.com/woozymasta/jamle"
+)
+
+// errorReport is one error object written to stderr with --json-errors.
+type errorReport struct {
+	Error    string `json:"error"`
+	Variable string `json:"variable,omitempty"`
+	Scalar   string `json:"scalar,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// outputError marks a failure to encode the expanded document.
+type outputError struct {
+	err error
+}
+
+// Error returns the underlying encoding error text.
+func (e outputError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying encoding error.
+func (e outputError) Unwrap() error {
+	return e.err
+}
+
+// fetchError marks a failure to download a remote input.
+type fetchError struct {
+	err error
+}
+
+// Error returns the underlying network error text.
+func (e fetchError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying network error.
+func (e fetchError) Unwrap() error {
+	return e.err
+}
+
+// inputErrorExit returns the exit code and error context for a failed
+// input read: 2 for a missing or unreadable file or a URL without
+// --allow-remote, 1 for fetch failures and other errors.
+func inputErrorExit(err error) (int, string) {
+	var fetchErr fetchError
+	switch {
+	case errors.Is(err, errRemoteDisabled):
+		return 2, "reading input"
+	case errors.As(err, &fetchErr):
+		return 1, "fetching input"
+	case errors.Is(err, fs.ErrNotExist):
+		return 2, "input file not found"
+	case errors.Is(err, fs.ErrPermission):
+		return 2, "input file not readable"
+	default:
+		return 1, "reading input"
+	}
+}
+
+// processErrorContext names the stage a processing error comes from:
+// variable resolution, output encoding or parsing.
+func processErrorContext(err error) string {
+	var expandErr *jamle.ExpandError
+	var outErr outputError
+	switch {
+	case errors.As(err, &expandErr):
+		return "resolving variables"
+	case errors.As(err, &outErr):
+		return "encoding output"
+	default:
+		return "parsing input"
+	}
+}
+
+// writeError writes err with optional context to w as text or JSON line.
+func writeError(w io.Writer, jsonErrors bool, context string, err error) {
+	msg := err.Error()
+	if context != "" {
+		msg = context + ": " + msg
+	}
+
+	if !jsonErrors {
+		writeTextError(w, context, err)
+		return
+	}
+
+	report := errorReport{Error: msg}
+	var expandErr *jamle.ExpandError
+	if errors.As(err, &expandErr) {
+		report.Variable = expandErr.Var
+		report.Scalar = expandErr.Scalar
+		report.Line = expandErr.Line
+		report.Column = expandErr.Column
+	}
+
+	data, marshalErr := json.Marshal(report)
+	if marshalErr != nil {
+		writeTextError(w, context, err)
+		return
+	}
+
+	_, _ = w.Write(append(data, '\n'))
+}
+
+// writeTextError writes a human-readable error line, e.g. "Error reading input: ...".
+func writeTextError(w io.Writer, context string, err error) {
+	if context == "" {
+		_, _ = fmt.Fprintf(w, "Error: %v\n", err)
+		return
+	}
+
+	_, _ = fmt.Fprintf(w, "Error %s: %v\n", context, err)
+}
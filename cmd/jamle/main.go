@@ -9,17 +9,25 @@ with Bash-style environment variable expansion.
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime/debug"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/woozymasta/jamle"
 	"github.com/woozymasta/jamle/yaml"
+	goyaml "go.yaml.in/yaml/v3"
 )
 
 var (
@@ -32,22 +40,48 @@ var (
 
 type cliOptions struct {
 	Args struct {
-		Input  string `positional-arg-name:"input" description:"Input file path, or '-' for stdin."`
-		Output string `positional-arg-name:"output" description:"Output file path, or '-' for stdout."`
+		Input  string   `positional-arg-name:"input" description:"Input file path, or '-' for stdin."`
+		Output string   `positional-arg-name:"output" description:"Output file path, or '-' for stdout."`
+		Files  []string `positional-arg-name:"file" description:"More input files with --keep-going."`
 	} `positional-args:"yes"`
 
-	To                    string   `short:"t" long:"to" choice:"auto" choice:"json" choice:"yaml" default:"auto" description:"Output format. In auto mode, output file extension is used (.json|.yaml|.yml); fallback is json."`
-	IgnoreExpandPaths     []string `short:"I" long:"ignore-expand-path" value-name:"PATH" description:"Skip expansion for matching YAML key paths (glob segments with *). Can be repeated."`
-	Indent                int      `short:"i" long:"indent" value-name:"N" default:"2" description:"Output indentation. Use 0 for compact output."`
-	MaxBytes              int64    `short:"m" long:"max-bytes" value-name:"N" default:"67108864" description:"Maximum input size in bytes."`
-	MaxPasses             int      `short:"p" long:"max-passes" value-name:"N" default:"10" description:"Maximum number of variable expansion passes."`
-	All                   bool     `short:"a" long:"all" description:"Decode all input documents (YAML multi-document stream)."`
-	DisableAssignment     bool     `short:"A" long:"disable-assignment" description:"Disable side effects of ${VAR:=default}; behaves like ${VAR:-default}."`
-	DisableRequiredErrors bool     `short:"R" long:"disable-required-errors" description:"Disable errors for ${VAR:?error}; behaves like ${VAR}."`
-	Version               bool     `short:"v" long:"version" description:"Print version information and exit."`
+	Format                string        `short:"f" long:"format" choice:"auto" choice:"json" choice:"yaml" choice:"toml" default:"auto" description:"Input format. In auto mode, the input file extension (.json, .yaml, .yml, .toml) selects the parser; otherwise input starting with '{' or '[' that is valid JSON is parsed as JSON, and anything else as YAML."`
+	To                    string        `short:"t" long:"to" choice:"auto" choice:"json" choice:"yaml" default:"auto" description:"Output format. In auto mode, output file extension is used (.json|.yaml|.yml); fallback is json."`
+	OutputFile            string        `short:"O" long:"output-file" value-name:"PATH" description:"Write output to file, creating parent directories. The file is replaced atomically. Conflicts with positional output."`
+	EnvFD                 int           `long:"env-fd" value-name:"FD" default:"-1" description:"Read a NUL-delimited NAME=VALUE environment from file descriptor FD and use it instead of the process environment; ${VAR:=default} assignments stay in memory. Values from --defaults apply underneath."`
+	Defaults              string        `short:"D" long:"defaults" value-name:"FILE" description:"Load KEY=VALUE defaults from file. Process environment takes precedence over defaults."`
+	IgnoreExpandPaths     []string      `short:"I" long:"ignore-expand-path" value-name:"PATH" description:"Skip expansion for matching YAML key paths (glob segments with *). Can be repeated."`
+	Indent                int           `short:"i" long:"indent" value-name:"N" default:"2" description:"Output indentation. Use 0 for compact output."`
+	MaxBytes              int64         `short:"m" long:"max-bytes" value-name:"N" default:"67108864" description:"Maximum input size in bytes."`
+	AllowRemote           bool          `long:"allow-remote" description:"Allow an http:// or https:// URL as input. HTTP_PROXY, HTTPS_PROXY and NO_PROXY are honored."`
+	Timeout               time.Duration `long:"timeout" value-name:"DURATION" default:"30s" description:"Timeout for fetching a remote input."`
+	MaxPasses             int           `short:"p" long:"max-passes" value-name:"N" default:"10" description:"Maximum number of variable expansion passes."`
+	PreserveStyle         bool          `long:"preserve-style" description:"Re-encode YAML input node by node, keeping quoting, comments, key order, leading directives and the --- marker; all documents are kept and --indent is ignored. Requires YAML input and output."`
+	All                   bool          `short:"a" long:"all" description:"Decode all input documents (YAML multi-document stream)."`
+	DisableAssignment     bool          `short:"A" long:"disable-assignment" description:"Disable side effects of ${VAR:=default}; behaves like ${VAR:-default}."`
+	DisallowAssign        bool          `long:"disallow-assign" description:"Fail on any ${VAR:=default} instead of assigning."`
+	WindowsVars           bool          `long:"windows-vars" description:"Also expand Windows-style %NAME% references; unset ones stay literal."`
+	Arithmetic            bool          `long:"arithmetic" description:"Evaluate integer $((EXPR)) expressions after variable expansion, e.g. $((${PORT:-8080}+1))."`
+	StrictDollar          bool          `long:"strict-dollar" description:"Fail on a $ that does not start ${...}, $$ or (with --arithmetic) $((, such as $HOST} or an unterminated ${HOST."`
+	ErrorOnUnset          bool          `short:"u" long:"error-on-unset" description:"Fail on plain ${VAR} when VAR is unset, like set -u; ${VAR:-} and other fallbacks still work."`
+	DisableRequiredErrors bool          `short:"R" long:"disable-required-errors" description:"Disable errors for ${VAR:?error}; behaves like ${VAR}."`
+	RetypeTOMLStrings     bool          `long:"retype-toml-strings" description:"Re-type quoted TOML strings containing ${...} after expansion, so port = \"${PORT}\" yields a number. TOML strings stay strings by default."`
+	DisableKeyExpansion   bool          `long:"disable-key-expansion" description:"Keep mapping keys such as ${REGION}_host literal; only values are expanded. Keys are expanded by default."`
+	Watch                 bool          `short:"w" long:"watch" description:"Re-render the input file whenever it or the --defaults file changes, printing a timestamped separator before each output, until interrupted. Output goes to stdout."`
+	KeepGoing             bool          `short:"k" long:"keep-going" description:"Treat every positional argument as an input file, write the outputs to stdout in order, continue after a failing file and exit non-zero if any failed."`
+	NoTrailingNewline     bool          `long:"no-trailing-newline" description:"Strip the final newline of the output."`
+	Quiet                 bool          `short:"q" long:"quiet" description:"Do not print usage help on empty input; report a short error instead."`
+	Manifest              string        `long:"manifest" value-name:"FILE" description:"Also write a JSON manifest of every substituted variable with its value and source (environment, defaults-file, inline-default or unset). Secret-looking values are redacted."`
+	Trace                 bool          `long:"trace" description:"Log each expansion pass to stderr: scalar text before/after and applied operators. Values of secret-looking variables are redacted."`
+	JSONErrors            bool          `long:"json-errors" description:"Write errors to stderr as JSON objects ({\"error\",\"variable\",\"scalar\",\"line\"})."`
+	Version               bool          `short:"v" long:"version" description:"Print version information and exit."`
 }
 
 func init() {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		applyBuildInfo(info)
+	}
+
 	if _buildTime == "" {
 		return
 	}
@@ -60,6 +94,27 @@ func init() {
 	BuildTime = parsed.UTC()
 }
 
+// applyBuildInfo fills version metadata not injected via ldflags from Go
+// build info, e.g. for binaries built with `go install ...@version`.
+func applyBuildInfo(info *debug.BuildInfo) {
+	if Version == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		Version = info.Main.Version
+	}
+
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if Commit == "unknown" {
+				Commit = setting.Value
+			}
+		case "vcs.time":
+			if parsed, err := time.Parse(time.RFC3339, setting.Value); err == nil && BuildTime.Equal(time.Unix(0, 0)) {
+				BuildTime = parsed.UTC()
+			}
+		}
+	}
+}
+
 // main runs the CLI input/read/expand/print flow.
 func main() {
 	var opts cliOptions
@@ -71,7 +126,22 @@ Supported placeholders:
 * ${VAR:-default}  default if VAR is unset or empty.
 * ${VAR:=default}  same as above, and sets VAR in current process environment.
 * ${VAR:?error}    error if VAR is unset or empty.
-* $${VAR}          escaping; keeps literal ${VAR} without expansion.`
+* ${VAR:?int}      also error unless VAR parses as int, float, bool, url or duration.
+* ${VAR:+alt}      alt if VAR is set and not empty, otherwise empty.
+* ${VAR-default}   no-colon forms (-, =, ?, +) treat an empty VAR as set.
+* ${VAR:-$OTHER}   bare $OTHER is expanded inside :- and := defaults.
+* ${VAR@U}         value of VAR in upper case (@u: first letter, @L: lower case).
+* ${VAR@Q}         value of VAR single-quoted for shell input.
+* ${VAR|split:,}   whole-scalar list; splits the value into a sequence.
+* $${VAR}          escaping; keeps literal ${VAR} without expansion.
+* $$               escaping; a $$ not followed by { becomes a literal $.
+
+Exit codes:
+* 0  success.
+* 1  parse error, variable resolution error, remote fetch failure, empty input
+     or output failure.
+* 2  invalid arguments, input file not found or not readable, URL input
+     without --allow-remote.`
 
 	_, err := parser.AddGroup("Options", "", &opts)
 	if err != nil {
@@ -87,7 +157,7 @@ Supported placeholders:
 			return
 		}
 
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		writeError(os.Stderr, opts.JSONErrors, "", err)
 		os.Exit(2)
 	}
 
@@ -96,54 +166,229 @@ Supported placeholders:
 		return
 	}
 
+	fail := func(code int, context string, err error) {
+		writeError(os.Stderr, opts.JSONErrors, context, err)
+		os.Exit(code)
+	}
+
 	if opts.MaxBytes <= 0 {
-		fmt.Fprintln(os.Stderr, "Error: --max-bytes must be greater than zero")
-		os.Exit(2)
+		fail(2, "", errors.New("--max-bytes must be greater than zero"))
 	}
 
 	if opts.MaxPasses <= 0 {
-		fmt.Fprintln(os.Stderr, "Error: --max-passes must be greater than zero")
-		os.Exit(2)
+		fail(2, "", errors.New("--max-passes must be greater than zero"))
+	}
+
+	unmarshalOptions := newUnmarshalOptions(opts)
+
+	if opts.Trace {
+		logger := &traceLogger{w: os.Stderr}
+		unmarshalOptions.Trace = logger.event
+	}
+
+	var defaults map[string]string
+	if opts.Defaults != "" {
+		defaults, err = loadDefaultsFile(opts.Defaults)
+		if err != nil {
+			fail(1, "reading defaults", err)
+		}
+	}
+
+	var fdEnv map[string]string
+	if opts.EnvFD >= 0 {
+		fdEnv, err = readEnvFD(opts.EnvFD, opts.MaxBytes)
+		if err != nil {
+			fail(2, "reading --env-fd", err)
+		}
+	}
+
+	var recorder *manifestRecorder
+	if opts.Manifest != "" {
+		recorder = &manifestRecorder{env: fdEnv, defaults: defaults}
+		if trace := unmarshalOptions.Trace; trace != nil {
+			unmarshalOptions.Trace = func(e jamle.TraceEvent) {
+				trace(e)
+				recorder.event(e)
+			}
+		} else {
+			unmarshalOptions.Trace = recorder.event
+		}
 	}
 
-	inputPath := opts.Args.Input
+	unmarshalOptions.Env = fdEnv
+	unmarshalOptions.Defaults = defaults
+
+	if opts.Manifest != "" && (opts.KeepGoing || opts.Watch) {
+		fail(2, "", errors.New("--manifest supports a single render; it cannot be combined with --keep-going or --watch"))
+	}
+
+	if opts.KeepGoing {
+		if opts.OutputFile != "" {
+			fail(2, "", errors.New("--keep-going writes to stdout; --output-file is not supported"))
+		}
+
+		outputFormat, err := resolveOutputFormat(opts.To, "-")
+		if err != nil {
+			fail(1, "encoding output", err)
+		}
+
+		paths := append([]string{opts.Args.Input, opts.Args.Output}, opts.Args.Files...)
+		if failed := runKeepGoing(paths, opts, outputFormat, unmarshalOptions, os.Stdout, os.Stderr); failed > 0 {
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if len(opts.Args.Files) > 0 {
+		fail(2, "", errors.New("too many arguments; use --keep-going to process several files"))
+	}
+
+	inputPath, err := expandPathArg(opts.Args.Input)
+	if err != nil {
+		fail(2, "expanding input path", err)
+	}
 	if inputPath == "" {
 		inputPath = "-"
 	}
 
-	input, err := readInput(inputPath, opts.MaxBytes)
+	if opts.Watch {
+		switch {
+		case inputPath == "-" || isRemoteInput(inputPath):
+			fail(2, "", errors.New("--watch needs a local input file"))
+		case opts.Args.Output != "" || opts.OutputFile != "":
+			fail(2, "", errors.New("--watch writes to stdout; an output path is not supported"))
+		}
+
+		outputFormat, err := resolveOutputFormat(opts.To, "-")
+		if err != nil {
+			fail(1, "encoding output", err)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		render := func() ([]byte, error) {
+			renderOptions := unmarshalOptions
+			if opts.Defaults != "" {
+				defaults, err := loadDefaultsFile(opts.Defaults)
+				if err != nil {
+					return nil, fmt.Errorf("reading defaults: %w", err)
+				}
+
+				renderOptions.Defaults = defaults
+			}
+
+			return renderWatched(inputPath, opts, outputFormat, renderOptions)
+		}
+
+		paths := []string{inputPath}
+		if opts.Defaults != "" {
+			paths = append(paths, opts.Defaults)
+		}
+
+		runWatch(ctx, paths, watchInterval, render, os.Stdout, os.Stderr, opts.JSONErrors)
+		return
+	}
+
+	input, err := loadInput(inputPath, opts)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
-		os.Exit(1)
+		code, context := inputErrorExit(err)
+		fail(code, context, err)
 	}
 
 	if len(input) == 0 {
+		if opts.Quiet || opts.JSONErrors {
+			fail(1, "reading input", errors.New("input is empty"))
+		}
+
 		parser.WriteHelp(os.Stderr)
 		os.Exit(1)
 	}
 
-	unmarshalOptions := jamle.UnmarshalOptions{
+	if opts.OutputFile != "" && opts.Args.Output != "" {
+		fail(2, "", errors.New("use either positional output or --output-file, not both"))
+	}
+
+	outputPath := opts.Args.Output
+	if opts.OutputFile != "" {
+		outputPath = opts.OutputFile
+	}
+	outputPath, err = expandPathArg(outputPath)
+	if err != nil {
+		fail(2, "expanding output path", err)
+	}
+	if outputPath == "" {
+		outputPath = "-"
+	}
+
+	outputFormat, err := resolveOutputFormat(opts.To, outputPath)
+	if err != nil {
+		fail(1, "encoding output", err)
+	}
+
+	output, err := renderInput(opts, inputPath, input, outputFormat, unmarshalOptions)
+	if err != nil {
+		fail(1, processErrorContext(err), err)
+	}
+
+	write := writeOutput
+	if opts.OutputFile != "" {
+		write = writeOutputFile
+	}
+
+	if err := write(outputPath, output); err != nil {
+		fail(1, "writing output", err)
+	}
+
+	if recorder != nil {
+		if err := writeManifest(opts.Manifest, inputPath, recorder); err != nil {
+			fail(1, "writing manifest", err)
+		}
+	}
+}
+
+// newUnmarshalOptions maps expansion flags to library options.
+func newUnmarshalOptions(opts cliOptions) jamle.UnmarshalOptions {
+	return jamle.UnmarshalOptions{
 		MaxPasses:             opts.MaxPasses,
 		IgnoreExpandPaths:     opts.IgnoreExpandPaths,
 		DisableAssignment:     opts.DisableAssignment,
+		DisallowAssign:        opts.DisallowAssign,
+		WindowsVars:           opts.WindowsVars,
+		Arithmetic:            opts.Arithmetic,
+		StrictDollar:          opts.StrictDollar,
+		ErrorOnUnset:          opts.ErrorOnUnset,
 		DisableRequiredErrors: opts.DisableRequiredErrors,
+		DisableKeyExpansion:   opts.DisableKeyExpansion,
+		RetypeTOMLStrings:     opts.RetypeTOMLStrings,
 	}
+}
 
-	decoded, err := decodeInput(input, opts.All, unmarshalOptions)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error processing file: %v\n", err)
-		os.Exit(1)
+// renderInput expands input and encodes it in outputFormat.
+func renderInput(opts cliOptions, path string, input []byte, outputFormat yaml.Format, unmarshalOptions jamle.UnmarshalOptions) ([]byte, error) {
+	if opts.PreserveStyle {
+		if opts.NoTrailingNewline {
+			trailingNewline := false
+			unmarshalOptions.TrailingNewline = &trailingNewline
+		}
+
+		return expandPreservingStyle(opts.Format, path, input, outputFormat, unmarshalOptions)
 	}
 
-	outputPath := opts.Args.Output
-	if outputPath == "" {
-		outputPath = "-"
+	if outputFormat == yaml.FormatJSON && !opts.All {
+		if output, ok, err := renderJSON(opts, path, input, unmarshalOptions); ok {
+			return output, err
+		}
 	}
 
-	outputFormat, err := resolveOutputFormat(opts.To, outputPath)
+	if outputFormat == yaml.FormatYAML && detectInputFormat(opts.Format, path, input) == "toml" {
+		return renderTOMLAsYAML(opts, input, unmarshalOptions)
+	}
+
+	decoded, err := decodeInputAs(opts.Format, path, input, opts.All, unmarshalOptions)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error encoding output: %v\n", err)
-		os.Exit(1)
+		return nil, err
 	}
 
 	output, err := yaml.MarshalWith(decoded, yaml.WriteOptions{
@@ -151,14 +396,165 @@ Supported placeholders:
 		Indent: opts.Indent,
 	})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error encoding output: %v\n", err)
-		os.Exit(1)
+		return nil, outputError{err: err}
 	}
 
-	if err := writeOutput(outputPath, output); err != nil {
-		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
-		os.Exit(1)
+	if opts.NoTrailingNewline {
+		output = bytes.TrimSuffix(output, []byte("\n"))
+	}
+
+	return output, nil
+}
+
+// renderJSON renders one YAML or JSON document with jamle.ToJSONAs, so the
+// library helper and the CLI share one JSON renderer and an explicit
+// --format wins over content sniffing. It reports false for input it does
+// not handle, such as TOML.
+func renderJSON(opts cliOptions, path string, input []byte, unmarshalOptions jamle.UnmarshalOptions) ([]byte, bool, error) {
+	format := detectInputFormat(opts.Format, path, input)
+	switch format {
+	case "json":
+		if !json.Valid(input) {
+			return nil, true, errors.New("input is not valid JSON")
+		}
+	case "yaml":
+	default:
+		return nil, false, nil
+	}
+
+	if opts.NoTrailingNewline {
+		trailingNewline := false
+		unmarshalOptions.TrailingNewline = &trailingNewline
+	}
+
+	output, err := jamle.ToJSONAs(input, yaml.Format(format), strings.Repeat(" ", max(opts.Indent, 0)), unmarshalOptions)
+	return output, true, err
+}
+
+// renderTOMLAsYAML renders TOML input as YAML from the expanded node tree,
+// so tables keep their source key order and strings keep their quotes.
+func renderTOMLAsYAML(opts cliOptions, input []byte, unmarshalOptions jamle.UnmarshalOptions) ([]byte, error) {
+	if err := jamle.CheckText(input); err != nil {
+		return nil, err
+	}
+
+	var root goyaml.Node
+	if err := jamle.UnmarshalTOMLWithOptions(input, &root, unmarshalOptions); err != nil {
+		return nil, err
+	}
+
+	var doc any = &root
+	if opts.All {
+		doc = []*goyaml.Node{&root}
+	}
+
+	var buf bytes.Buffer
+	enc := goyaml.NewEncoder(&buf)
+	if opts.Indent > 0 {
+		enc.SetIndent(opts.Indent)
+	}
+	if err := enc.Encode(doc); err != nil {
+		return nil, outputError{err: err}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, outputError{err: err}
+	}
+
+	output := buf.Bytes()
+	if opts.NoTrailingNewline {
+		output = bytes.TrimSuffix(output, []byte("\n"))
+	}
+
+	return output, nil
+}
+
+// runKeepGoing processes every path in order, writing outputs to stdout and
+// per-file errors to stderr, and returns the number of failed files.
+func runKeepGoing(paths []string, opts cliOptions, outputFormat yaml.Format, unmarshalOptions jamle.UnmarshalOptions, stdout, stderr io.Writer) int {
+	// Files are expanded in argument order with one resolver, so
+	// ${VAR:=default} in a file is visible to the files after it. The
+	// process environment already behaves that way; an isolated Env would
+	// get a fresh overlay per file.
+	if unmarshalOptions.Env != nil {
+		unmarshalOptions.Resolver = sharedEnv(maps.Clone(unmarshalOptions.Env))
+		unmarshalOptions.Env = nil
+	}
+
+	failed := 0
+	total := 0
+	for _, arg := range paths {
+		if arg == "" {
+			continue
+		}
+		total++
+
+		output, err := processFile(arg, opts, outputFormat, unmarshalOptions)
+		if err != nil {
+			writeError(stderr, opts.JSONErrors, "processing "+arg, err)
+			failed++
+			continue
+		}
+
+		if _, err := stdout.Write(output); err != nil {
+			writeError(stderr, opts.JSONErrors, "writing output", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		writeError(stderr, opts.JSONErrors, "", fmt.Errorf("%d of %d files failed", failed, total))
+	}
+
+	return failed
+}
+
+// sharedEnv resolves from an --env-fd environment and records
+// ${VAR:=default} assignments in it, so they carry over between the files
+// of one --keep-going run.
+type sharedEnv map[string]string
+
+// Lookup resolves a variable from the environment and earlier assignments.
+func (e sharedEnv) Lookup(name string) (string, bool) {
+	v, ok := e[name]
+	return v, ok
+}
+
+// Set records an assignment for later lookups of the same run.
+func (e sharedEnv) Set(name, value string) error {
+	e[name] = value
+	return nil
+}
+
+// processFile reads and renders one --keep-going input file.
+func processFile(arg string, opts cliOptions, outputFormat yaml.Format, unmarshalOptions jamle.UnmarshalOptions) ([]byte, error) {
+	path, err := expandPathArg(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := loadInput(path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(input) == 0 {
+		return nil, errors.New("input is empty")
 	}
+
+	return renderInput(opts, path, input, outputFormat, unmarshalOptions)
+}
+
+// loadInput reads input from a URL with --allow-remote, a file or stdin.
+func loadInput(path string, opts cliOptions) ([]byte, error) {
+	if !isRemoteInput(path) {
+		return readInput(path, opts.MaxBytes)
+	}
+
+	if !opts.AllowRemote {
+		return nil, errRemoteDisabled
+	}
+
+	return fetchInput(path, opts.Timeout, opts.MaxBytes)
 }
 
 // readInput reads input from path or stdin.
@@ -192,6 +588,75 @@ func readInput(path string, maxBytes int64) ([]byte, error) {
 	return data, nil
 }
 
+// expandPathArg expands a leading ~ and $VAR or ${VAR} references in a
+// path argument. Empty paths and "-" (stdin/stdout) are returned unchanged.
+func expandPathArg(path string) (string, error) {
+	if path == "" || path == "-" {
+		return path, nil
+	}
+
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(os.PathSeparator)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+
+		path = home + path[1:]
+	}
+
+	return jamle.ExpandString(bracePathVars(path))
+}
+
+// bracePathVars rewrites bare $NAME references to ${NAME}, so they are
+// expanded by the regular engine. $$ and ${...} are kept as is.
+func bracePathVars(path string) string {
+	if !strings.Contains(path, "$") {
+		return path
+	}
+
+	var out strings.Builder
+	out.Grow(len(path) + 4)
+
+	for i := 0; i < len(path); i++ {
+		if path[i] != '$' || i+1 >= len(path) {
+			out.WriteByte(path[i])
+			continue
+		}
+
+		if path[i+1] == '$' {
+			out.WriteString("$$")
+			i++
+			continue
+		}
+
+		end := i + 1
+		for end < len(path) && isPathVarByte(path[end], end == i+1) {
+			end++
+		}
+		if end == i+1 {
+			out.WriteByte(path[i])
+			continue
+		}
+
+		out.WriteString("${" + path[i+1:end] + "}")
+		i = end - 1
+	}
+
+	return out.String()
+}
+
+// isPathVarByte reports whether c may appear in a bare $NAME reference.
+func isPathVarByte(c byte, first bool) bool {
+	switch {
+	case c == '_', c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	default:
+		return false
+	}
+}
+
 // writeOutput writes output to given path or stdout.
 func writeOutput(path string, data []byte) error {
 	if path == "-" || path == "" {
@@ -199,9 +664,46 @@ func writeOutput(path string, data []byte) error {
 		return err
 	}
 
+	return writeFileAtomic(filepath.Clean(path), data)
+}
+
+// writeOutputFile writes output to file path, creating parent directories.
+func writeOutputFile(path string, data []byte) error {
 	filePath := filepath.Clean(path)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0o750); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filePath, data)
+}
+
+// writeFileAtomic writes data to a temporary file in the destination
+// directory and renames it into place, so no partial file is left on error.
+func writeFileAtomic(path string, data []byte) error {
 	// #nosec G304 -- CLI intentionally writes to a user-provided local file path.
-	return os.WriteFile(filePath, data, 0o600)
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+
+	return nil
 }
 
 // decodeInput decodes input into a Go object.
@@ -223,6 +725,120 @@ func decodeInput(input []byte, all bool, unmarshalOptions jamle.UnmarshalOptions
 	return out, nil
 }
 
+// decodeInputAs decodes input using the parser selected by --format
+// or, in auto mode, by the input path extension and content.
+func decodeInputAs(format, path string, input []byte, all bool, unmarshalOptions jamle.UnmarshalOptions) (any, error) {
+	if err := jamle.CheckText(input); err != nil {
+		return nil, err
+	}
+
+	switch detectInputFormat(format, path, input) {
+	case "toml":
+		return decodeTOMLInput(input, all, unmarshalOptions)
+	case "json":
+		if !json.Valid(input) {
+			return nil, errors.New("input is not valid JSON")
+		}
+
+		return decodeJSONInput(input, all, unmarshalOptions)
+	case "yaml":
+		return decodeInput(input, all, unmarshalOptions)
+	default:
+		return nil, fmt.Errorf("invalid --format value: %q", format)
+	}
+}
+
+// expandPreservingStyle expands YAML input in place and re-encodes it,
+// keeping scalar quoting styles, comments, key order and the document
+// header of the source.
+func expandPreservingStyle(format, path string, input []byte, outputFormat yaml.Format, unmarshalOptions jamle.UnmarshalOptions) ([]byte, error) {
+	if outputFormat != yaml.FormatYAML {
+		return nil, errors.New("--preserve-style requires YAML output")
+	}
+
+	if err := jamle.CheckText(input); err != nil {
+		return nil, err
+	}
+
+	if inputFormat := detectInputFormat(format, path, input); inputFormat != "yaml" {
+		return nil, fmt.Errorf("--preserve-style requires YAML input, got %s", inputFormat)
+	}
+
+	unmarshalOptions.PreserveDocumentHeader = true
+	return jamle.ExpandPathWithOptions(input, "", unmarshalOptions)
+}
+
+// detectInputFormat resolves auto input format from the input file
+// extension (.json, .yaml, .yml, .toml; of the URL path for remote input).
+// Without a known extension, such as on stdin, it sniffs the first
+// significant byte: JSON-looking input that is not valid JSON (for example,
+// YAML flow mappings or unquoted placeholders) falls back to YAML.
+func detectInputFormat(format, path string, input []byte) string {
+	format = strings.ToLower(format)
+	if format != "auto" && format != "" {
+		return format
+	}
+
+	if isRemoteInput(path) {
+		path = remoteFormatPath(path)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	}
+
+	trimmed := bytes.TrimSpace(input)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return "json"
+	}
+
+	return "yaml"
+}
+
+// decodeTOMLInput decodes TOML input into a Go object.
+// TOML has no document streams, so --all yields a single-element slice.
+func decodeTOMLInput(input []byte, all bool, unmarshalOptions jamle.UnmarshalOptions) (any, error) {
+	var out map[string]any
+	if err := jamle.UnmarshalTOMLWithOptions(input, &out, unmarshalOptions); err != nil {
+		return nil, err
+	}
+
+	if all {
+		return []any{out}, nil
+	}
+
+	return out, nil
+}
+
+// decodeJSONInput expands JSON strings natively and decodes the result with
+// json.Number values, so large integers survive the round-trip unchanged.
+// JSON input holds one value, so --all yields a single-element slice.
+func decodeJSONInput(input []byte, all bool, unmarshalOptions jamle.UnmarshalOptions) (any, error) {
+	expanded, err := jamle.ExpandJSONWithOptions(input, unmarshalOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(expanded))
+	dec.UseNumber()
+
+	var out any
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+
+	if all {
+		return []any{out}, nil
+	}
+
+	return out, nil
+}
+
 // resolveOutputFormat resolves output format from --to and output path.
 func resolveOutputFormat(to, outputPath string) (yaml.Format, error) {
 	switch strings.ToLower(to) {
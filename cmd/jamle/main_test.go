@@ -2,12 +2,19 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/woozymasta/jamle"
@@ -33,6 +40,7 @@ func TestCLIOptions_CoreFlags(t *testing.T) {
 		"--all",
 		"--disable-assignment",
 		"--disable-required-errors",
+		"--error-on-unset",
 		"in.yaml",
 		"out.yaml",
 	}
@@ -46,7 +54,7 @@ func TestCLIOptions_CoreFlags(t *testing.T) {
 	if opts.To != "yaml" || opts.Indent != 4 || opts.MaxBytes != 1024 || opts.MaxPasses != 15 {
 		t.Fatalf("unexpected parsed scalar flags: %#v", opts)
 	}
-	if !opts.All || !opts.DisableAssignment || !opts.DisableRequiredErrors {
+	if !opts.All || !opts.DisableAssignment || !opts.DisableRequiredErrors || !opts.ErrorOnUnset {
 		t.Fatalf("unexpected parsed bool flags: %#v", opts)
 	}
 	if opts.Args.Input != "in.yaml" || opts.Args.Output != "out.yaml" {
@@ -485,3 +493,1106 @@ func extractSpecAndFirstHookStep(t *testing.T, doc any) (map[string]any, map[str
 
 	return spec, step
 }
+
+func TestDecodeInputAs_TOML(t *testing.T) {
+	t.Setenv("JAMLE_CLI_TOML_HOST", "toml.local")
+	input := []byte("host = \"${JAMLE_CLI_TOML_HOST}\"\nport = 8080\n")
+
+	single, err := decodeInputAs("toml", "", input, false, jamle.UnmarshalOptions{})
+	if err != nil {
+		t.Fatalf("decodeInputAs returned error: %v", err)
+	}
+
+	got, ok := single.(map[string]any)
+	if !ok || got["host"] != "toml.local" || got["port"] != 8080 {
+		t.Fatalf("unexpected TOML decode result: %#v", single)
+	}
+
+	all, err := decodeInputAs("toml", "", input, true, jamle.UnmarshalOptions{})
+	if err != nil {
+		t.Fatalf("decodeInputAs --all returned error: %v", err)
+	}
+	if docs, ok := all.([]any); !ok || len(docs) != 1 {
+		t.Fatalf("expected one TOML document in --all mode, got %#v", all)
+	}
+
+	if _, err := decodeInputAs("toml", "", []byte("host: yaml\n"), false, jamle.UnmarshalOptions{}); err == nil {
+		t.Fatal("expected TOML parse error for YAML input")
+	}
+}
+
+func TestRenderInput_TOMLAsYAML(t *testing.T) {
+	input := []byte("zeta = \"${V}\"\nalpha = 1\n\n[server]\nport = \"${PORT}\"\nhost = \"h\"\n")
+	unmarshalOptions := jamle.UnmarshalOptions{Env: map[string]string{"V": "1.10", "PORT": "8080"}}
+
+	tests := []struct {
+		name string
+		opts cliOptions
+		want string
+	}{
+		{
+			name: "order and strings kept",
+			opts: cliOptions{Format: "auto", Indent: 2},
+			want: "zeta: \"1.10\"\nalpha: 1\nserver:\n  port: \"8080\"\n  host: h\n",
+		},
+		{
+			name: "retyped",
+			opts: cliOptions{Format: "auto", Indent: 2, RetypeTOMLStrings: true},
+			want: "zeta: 1.10\nalpha: 1\nserver:\n  port: 8080\n  host: h\n",
+		},
+		{
+			name: "all",
+			opts: cliOptions{Format: "toml", Indent: 2, All: true, NoTrailingNewline: true},
+			want: "- zeta: \"1.10\"\n  alpha: 1\n  server:\n    port: \"8080\"\n    host: h",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := unmarshalOptions
+			options.RetypeTOMLStrings = tt.opts.RetypeTOMLStrings
+			got, err := renderInput(tt.opts, "config.toml", input, yaml.FormatYAML, options)
+			if err != nil {
+				t.Fatalf("renderInput returned error: %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Fatalf("output mismatch:\ngot  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectInputFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		path   string
+		input  string
+		want   string
+	}{
+		{name: "explicit yaml", format: "yaml", input: `{"a": 1}`, want: "yaml"},
+		{name: "explicit format wins over extension", format: "yaml", path: "in.toml", input: "a = 1", want: "yaml"},
+		{name: "extension json", format: "auto", path: "in.json", input: `{"a": 1}`, want: "json"},
+		{name: "extension json invalid stays json", format: "auto", path: "in.json", input: `{"a": 1,}`, want: "json"},
+		{name: "extension json upper case", format: "auto", path: "conf/IN.JSON", input: "a: 1\n", want: "json"},
+		{name: "extension yaml", format: "auto", path: "conf/in.yaml", input: `{"a": 1}`, want: "yaml"},
+		{name: "extension yml upper case", format: "auto", path: "IN.YML", input: `[1]`, want: "yaml"},
+		{name: "extension toml", format: "auto", path: "in.toml", input: "a = 1", want: "toml"},
+		{name: "unknown extension sniffs", format: "auto", path: "in.conf", input: `{"a": 1}`, want: "json"},
+		{name: "stdin sniffs", format: "auto", path: "-", input: `{"a": 1}`, want: "json"},
+		{name: "explicit json", format: "json", input: "a: 1", want: "json"},
+		{name: "explicit toml", format: "TOML", input: "a = 1", want: "toml"},
+		{name: "auto json object", format: "auto", input: "  \n{\"a\": \"${A}\"}\n", want: "json"},
+		{name: "auto json array", format: "auto", input: `[1, 2]`, want: "json"},
+		{name: "auto yaml mapping", format: "auto", input: "a: 1\n", want: "yaml"},
+		{name: "auto yaml flow mapping", format: "auto", input: "{a: 1}", want: "yaml"},
+		{name: "auto unquoted placeholder", format: "auto", input: `{"a": ${A}}`, want: "yaml"},
+		{name: "auto empty input", format: "", input: "", want: "yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectInputFormat(tt.format, tt.path, []byte(tt.input)); got != tt.want {
+				t.Fatalf("detectInputFormat(%q, %q) = %q, want %q", tt.path, tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeInputAs_JSONExtension(t *testing.T) {
+	for _, input := range []string{`{"a": 1,}`, "// comment\n{\"a\": 1}"} {
+		_, err := decodeInputAs("auto", "config.json", []byte(input), false, jamle.UnmarshalOptions{})
+		if err == nil || !strings.Contains(err.Error(), "not valid JSON") {
+			t.Fatalf("expected JSON error for %q, got %v", input, err)
+		}
+	}
+}
+
+func TestDecodeInputAs_JSON(t *testing.T) {
+	t.Setenv("JAMLE_CLI_JSON_HOST", "json.local")
+
+	got, err := decodeInputAs("json", "", []byte(`{"host": "${JAMLE_CLI_JSON_HOST}", "on": "no"}`), false, jamle.UnmarshalOptions{})
+	if err != nil {
+		t.Fatalf("decodeInputAs returned error: %v", err)
+	}
+
+	doc, ok := got.(map[string]any)
+	if !ok || doc["host"] != "json.local" || doc["on"] != "no" {
+		t.Fatalf("unexpected JSON decode result: %#v", got)
+	}
+
+	if _, err := decodeInputAs("json", "", []byte("host: yaml\n"), false, jamle.UnmarshalOptions{}); err == nil {
+		t.Fatal("expected error for YAML input with --format json")
+	}
+}
+
+func TestDecodeInputAs_JSONBigIntegerRoundTrip(t *testing.T) {
+	input := []byte(`{"id": 98765432109876543210, "name": "${JAMLE_CLI_JSON_NAME:-svc}"}`)
+
+	for _, format := range []string{"json", "auto"} {
+		got, err := decodeInputAs(format, "", input, false, jamle.UnmarshalOptions{})
+		if err != nil {
+			t.Fatalf("decodeInputAs(%s) returned error: %v", format, err)
+		}
+
+		output, err := yaml.MarshalWith(got, yaml.WriteOptions{Format: yaml.FormatJSON})
+		if err != nil {
+			t.Fatalf("MarshalWith returned error: %v", err)
+		}
+
+		if want := `{"id":98765432109876543210,"name":"svc"}`; string(output) != want {
+			t.Fatalf("round-trip mismatch for %s:\ngot  %s\nwant %s", format, output, want)
+		}
+	}
+}
+
+func TestWriteOutputFile(t *testing.T) {
+	t.Run("creates parent directories", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nested", "dir", "out.json")
+		want := []byte(`{"a":1}` + "\n")
+		if err := writeOutputFile(path, want); err != nil {
+			t.Fatalf("writeOutputFile returned error: %v", err)
+		}
+
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile failed: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("file output mismatch: got %q, want %q", got, want)
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Fatalf("unexpected file mode: %v", info.Mode().Perm())
+		}
+	})
+
+	t.Run("replaces existing file without leftovers", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.yaml")
+		if err := os.WriteFile(path, []byte("old: 1\n"), 0o600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		if err := writeOutputFile(path, []byte("new: 1\n")); err != nil {
+			t.Fatalf("writeOutputFile returned error: %v", err)
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("ReadDir failed: %v", err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("expected only output file in dir, got %d entries", len(entries))
+		}
+	})
+
+	t.Run("parent is a file", func(t *testing.T) {
+		parent := filepath.Join(t.TempDir(), "file")
+		if err := os.WriteFile(parent, nil, 0o600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		if err := writeOutputFile(filepath.Join(parent, "out.json"), []byte("{}")); err == nil {
+			t.Fatal("expected writeOutputFile to fail when parent is a file")
+		}
+	})
+}
+
+func TestCLIOptions_OutputFile(t *testing.T) {
+	var opts cliOptions
+	parser := flags.NewParser(&opts, flags.None)
+	if _, err := parser.ParseArgs([]string{"-O", "build/out.yaml", "in.yaml"}); err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+
+	if opts.OutputFile != "build/out.yaml" || opts.Args.Input != "in.yaml" || opts.Args.Output != "" {
+		t.Fatalf("unexpected parsed options: %#v", opts)
+	}
+}
+
+func TestParseDefaults(t *testing.T) {
+	input := []byte(`
+# comment
+HOST=defaults.local
+export PORT = 8080
+QUOTED="a b"
+SINGLE='${LITERAL}'
+EMPTY=
+`)
+
+	got, err := parseDefaults(input)
+	if err != nil {
+		t.Fatalf("parseDefaults returned error: %v", err)
+	}
+
+	want := map[string]string{
+		"HOST":   "defaults.local",
+		"PORT":   "8080",
+		"QUOTED": "a b",
+		"SINGLE": "${LITERAL}",
+		"EMPTY":  "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("defaults mismatch:\ngot  %#v\nwant %#v", got, want)
+	}
+
+	for _, bad := range []string{"NO_EQUALS\n", "MY-VAR=x\n", "1VAR=x\n", "=x\n"} {
+		if _, err := parseDefaults([]byte(bad)); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestDecodeInput_DefaultsPrecedence(t *testing.T) {
+	t.Setenv("JAMLE_CLI_DEFAULTS_ENV", "from-env")
+
+	path := filepath.Join(t.TempDir(), "defaults.env")
+	content := "JAMLE_CLI_DEFAULTS_ENV=from-defaults\nJAMLE_CLI_DEFAULTS_ONLY=from-defaults\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write defaults: %v", err)
+	}
+
+	defaults, err := loadDefaultsFile(path)
+	if err != nil {
+		t.Fatalf("loadDefaultsFile returned error: %v", err)
+	}
+
+	input := []byte(`
+env: ${JAMLE_CLI_DEFAULTS_ENV}
+defaults: ${JAMLE_CLI_DEFAULTS_ONLY}
+inline: ${JAMLE_CLI_DEFAULTS_MISSING:-inline}
+`)
+	decoded, err := decodeInput(input, false, jamle.UnmarshalOptions{
+		Defaults: defaults,
+	})
+	if err != nil {
+		t.Fatalf("decodeInput returned error: %v", err)
+	}
+
+	want := map[string]any{
+		"env":      "from-env",
+		"defaults": "from-defaults",
+		"inline":   "inline",
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Fatalf("decoded mismatch:\ngot  %#v\nwant %#v", decoded, want)
+	}
+
+	if _, err := loadDefaultsFile(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Fatal("expected error for missing defaults file")
+	}
+}
+
+func TestExpandPathArg(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("JAMLE_CLI_PATH_ENV", "prod")
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "stdin marker", path: "-", want: "-"},
+		{name: "empty", path: "", want: ""},
+		{name: "plain path", path: "configs/app.yaml", want: "configs/app.yaml"},
+		{name: "home dir", path: "~", want: home},
+		{name: "home prefix", path: "~/configs/app.yaml", want: home + "/configs/app.yaml"},
+		{name: "tilde inside path", path: "configs/~app.yaml", want: "configs/~app.yaml"},
+		{name: "braced variable", path: "configs/${JAMLE_CLI_PATH_ENV}.yaml", want: "configs/prod.yaml"},
+		{name: "bare variable", path: "configs/$JAMLE_CLI_PATH_ENV.yaml", want: "configs/prod.yaml"},
+		{name: "default operator", path: "${JAMLE_CLI_PATH_MISSING:-dev}.yaml", want: "dev.yaml"},
+		{name: "home and variable", path: "~/$JAMLE_CLI_PATH_ENV/app.yaml", want: home + "/prod/app.yaml"},
+		{name: "lone dollar", path: "price$.yaml", want: "price$.yaml"},
+		{name: "dollar before digit", path: "a$1.yaml", want: "a$1.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandPathArg(tt.path)
+			if err != nil {
+				t.Fatalf("expandPathArg returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("path mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := expandPathArg("${JAMLE_CLI_PATH_MISSING:?path env required}"); err == nil {
+		t.Fatal("expected error for required path variable")
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	_, expandErr := decodeInput([]byte("a: ${JAMLE_CLI_ERR_MISSING:?is required}\n"), false, jamle.UnmarshalOptions{})
+	if expandErr == nil {
+		t.Fatal("expected expansion error")
+	}
+
+	tests := []struct {
+		err        error
+		name       string
+		context    string
+		want       string
+		jsonErrors bool
+	}{
+		{
+			name: "text without context",
+			err:  errors.New("--max-bytes must be greater than zero"),
+			want: "Error: --max-bytes must be greater than zero\n",
+		},
+		{
+			name:    "text with context",
+			err:     errors.New("boom"),
+			context: "reading input",
+			want:    "Error reading input: boom\n",
+		},
+		{
+			name:       "json plain error",
+			err:        errors.New("boom"),
+			context:    "reading input",
+			jsonErrors: true,
+			want:       `{"error":"reading input: boom"}` + "\n",
+		},
+		{
+			name:       "json expand error",
+			err:        expandErr,
+			context:    "processing file",
+			jsonErrors: true,
+			want: `{"error":"processing file: ` + strings.ReplaceAll(expandErr.Error(), `"`, `\"`) +
+				`","variable":"JAMLE_CLI_ERR_MISSING","scalar":"${JAMLE_CLI_ERR_MISSING:?is required}","line":1,"column":4}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writeError(&buf, tt.jsonErrors, tt.context, tt.err)
+			if buf.String() != tt.want {
+				t.Fatalf("output mismatch:\ngot  %q\nwant %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCLIOptions_QuietAndJSONErrors(t *testing.T) {
+	var opts cliOptions
+	parser := flags.NewParser(&opts, flags.None)
+
+	if _, err := parser.ParseArgs([]string{"-q", "--json-errors", "config.yaml"}); err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+	if !opts.Quiet || !opts.JSONErrors {
+		t.Fatalf("flags not parsed: quiet=%v json-errors=%v", opts.Quiet, opts.JSONErrors)
+	}
+}
+
+func TestTraceLogger_Redacts(t *testing.T) {
+	var buf bytes.Buffer
+	logger := &traceLogger{w: &buf}
+
+	input := []byte("dsn: ${DB_USER}:${DB_PASSWORD}@${DB_HOST:-localhost}\n")
+	_, err := decodeInput(input, false, jamle.UnmarshalOptions{
+		Env:   map[string]string{"DB_USER": "app", "DB_PASSWORD": "hunter2"},
+		Trace: logger.event,
+	})
+	if err != nil {
+		t.Fatalf("decodeInput returned error: %v", err)
+	}
+
+	got := buf.String()
+	if strings.Contains(got, "hunter2") {
+		t.Fatalf("trace leaks secret value:\n%s", got)
+	}
+
+	for _, want := range []string{
+		`pass 1: "${DB_USER}:${DB_PASSWORD}@${DB_HOST:-localhost}" -> "app:<redacted>@localhost"`,
+		`${DB_USER} = "app" (env)`,
+		`${DB_PASSWORD} = "<redacted>" (env)`,
+		`${DB_HOST:-} = "localhost" (default)`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("trace output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestIsSensitiveName(t *testing.T) {
+	tests := map[string]bool{
+		"DB_PASSWORD":   true,
+		"github_token":  true,
+		"AWS_SECRET":    true,
+		"API_KEY":       true,
+		"SSH_PRIVATE":   true,
+		"HOST":          false,
+		"PORT":          false,
+		"LOG_LEVEL":     false,
+		"DATABASE_NAME": false,
+	}
+
+	for name, want := range tests {
+		if got := isSensitiveName(name); got != want {
+			t.Errorf("isSensitiveName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestDecodeInputAs_BinaryInput(t *testing.T) {
+	input := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	for _, format := range []string{"auto", "json", "yaml", "toml"} {
+		if _, err := decodeInputAs(format, "", input, false, jamle.UnmarshalOptions{}); !errors.Is(err, jamle.ErrBinaryInput) {
+			t.Fatalf("decodeInputAs(%s): expected ErrBinaryInput, got %v", format, err)
+		}
+	}
+}
+
+func TestExpandPreservingStyle(t *testing.T) {
+	t.Setenv("JAMLE_STYLE_HOST", "db.local")
+
+	input := []byte("host: \"${JAMLE_STYLE_HOST}\" # primary\nname: 'svc'\n")
+	got, err := expandPreservingStyle("auto", "config.yaml", input, yaml.FormatYAML, jamle.UnmarshalOptions{})
+	if err != nil {
+		t.Fatalf("expandPreservingStyle returned error: %v", err)
+	}
+	if want := "host: \"db.local\" # primary\nname: 'svc'\n"; string(got) != want {
+		t.Fatalf("output mismatch:\ngot  %q\nwant %q", got, want)
+	}
+
+	header := []byte("%YAML 1.2\n---\nhost: ${JAMLE_STYLE_HOST}\n")
+	got, err = expandPreservingStyle("auto", "config.yaml", header, yaml.FormatYAML, jamle.UnmarshalOptions{})
+	if err != nil {
+		t.Fatalf("expandPreservingStyle returned error: %v", err)
+	}
+	if want := "%YAML 1.2\n---\nhost: db.local\n"; string(got) != want {
+		t.Fatalf("header output mismatch:\ngot  %q\nwant %q", got, want)
+	}
+
+	nested := []byte("# head\nserver:\n  # head of host\n  host: ${JAMLE_STYLE_HOST} # line\n  tags:\n    - a # item\n    # foot of tags\n")
+	got, err = expandPreservingStyle("auto", "config.yaml", nested, yaml.FormatYAML, jamle.UnmarshalOptions{})
+	if err != nil {
+		t.Fatalf("expandPreservingStyle returned error: %v", err)
+	}
+	if want := "# head\nserver:\n  # head of host\n  host: db.local # line\n  tags:\n    - a # item\n    # foot of tags\n"; string(got) != want {
+		t.Fatalf("nested comments mismatch:\ngot  %q\nwant %q", got, want)
+	}
+
+	// Without --preserve-style the document is decoded, so comments are gone.
+	got, err = renderInput(cliOptions{Format: "auto", Indent: 2}, "config.yaml", nested, yaml.FormatYAML, jamle.UnmarshalOptions{})
+	if err != nil {
+		t.Fatalf("renderInput returned error: %v", err)
+	}
+	if bytes.Contains(got, []byte("#")) {
+		t.Fatalf("expected comments to be dropped without --preserve-style, got %q", got)
+	}
+
+	if _, err := expandPreservingStyle("auto", "config.yaml", input, yaml.FormatJSON, jamle.UnmarshalOptions{}); err == nil {
+		t.Fatal("expected error for JSON output")
+	}
+	if _, err := expandPreservingStyle("toml", "config.toml", []byte("a = 1\n"), yaml.FormatYAML, jamle.UnmarshalOptions{}); err == nil {
+		t.Fatal("expected error for TOML input")
+	}
+}
+
+func TestApplyBuildInfo(t *testing.T) {
+	oldVersion, oldCommit, oldBuildTime := Version, Commit, BuildTime
+	t.Cleanup(func() { Version, Commit, BuildTime = oldVersion, oldCommit, oldBuildTime })
+
+	Version, Commit, BuildTime = "dev", "unknown", time.Unix(0, 0).UTC()
+	applyBuildInfo(&debug.BuildInfo{
+		Main: debug.Module{Version: "v1.2.3"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.time", Value: "2026-05-06T07:08:09Z"},
+		},
+	})
+	if Version != "v1.2.3" || Commit != "abc123" || !BuildTime.Equal(time.Date(2026, 5, 6, 7, 8, 9, 0, time.UTC)) {
+		t.Fatalf("build info not applied: %s %s %s", Version, Commit, BuildTime)
+	}
+
+	Version, Commit = "v9.9.9", "injected"
+	applyBuildInfo(&debug.BuildInfo{
+		Main:     debug.Module{Version: "(devel)"},
+		Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "other"}},
+	})
+	if Version != "v9.9.9" || Commit != "injected" {
+		t.Fatalf("ldflags values must win: %s %s", Version, Commit)
+	}
+}
+
+func TestRunKeepGoing(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.yaml": "name: ${JAMLE_KG_NAME:-a}\n",
+		"b.yaml": "token: ${JAMLE_KG_TOKEN:?token is required}\n",
+		"c.json": `{"name":"${JAMLE_KG_NAME:-c}"}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var opts cliOptions
+	parser := flags.NewParser(&opts, flags.None)
+	args := []string{"-k", "--to", "yaml", "--indent", "2"}
+	for _, name := range []string{"a.yaml", "b.yaml", "missing.yaml", "c.json"} {
+		args = append(args, filepath.Join(dir, name))
+	}
+	if _, err := parser.ParseArgs(args); err != nil {
+		t.Fatalf("ParseArgs returned error: %v", err)
+	}
+	if !opts.KeepGoing || len(opts.Args.Files) != 2 {
+		t.Fatalf("unexpected parsed args: keep-going=%v files=%v", opts.KeepGoing, opts.Args.Files)
+	}
+
+	var stdout, stderr bytes.Buffer
+	paths := append([]string{opts.Args.Input, opts.Args.Output}, opts.Args.Files...)
+	failed := runKeepGoing(paths, opts, yaml.FormatYAML, jamle.UnmarshalOptions{}, &stdout, &stderr)
+	if failed != 2 {
+		t.Fatalf("failed count mismatch: got %d, want 2\nstderr: %s", failed, stderr.String())
+	}
+
+	if want := "name: a\nname: c\n"; stdout.String() != want {
+		t.Fatalf("stdout mismatch:\ngot  %q\nwant %q", stdout.String(), want)
+	}
+
+	errText := stderr.String()
+	for _, want := range []string{
+		"Error processing " + filepath.Join(dir, "b.yaml") + ": ",
+		"token is required",
+		"Error processing " + filepath.Join(dir, "missing.yaml") + ": ",
+		"Error: 2 of 4 files failed\n",
+	} {
+		if !strings.Contains(errText, want) {
+			t.Fatalf("stderr lacks %q:\n%s", want, errText)
+		}
+	}
+}
+
+func TestRunKeepGoing_SharedAssignments(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.yaml")
+	b := filepath.Join(dir, "b.yaml")
+	if err := os.WriteFile(a, []byte("region: ${JAMLE_KG_REGION:=us-east-1}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("bucket: data-${JAMLE_KG_REGION}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := cliOptions{Format: "auto", Indent: 2, MaxBytes: 1024}
+	want := "region: us-east-1\nbucket: data-us-east-1\n"
+
+	t.Run("env-fd environment", func(t *testing.T) {
+		env := map[string]string{"OTHER": "x"}
+		var stdout, stderr bytes.Buffer
+		unmarshalOptions := jamle.UnmarshalOptions{Env: env}
+		if failed := runKeepGoing([]string{a, b}, opts, yaml.FormatYAML, unmarshalOptions, &stdout, &stderr); failed != 0 {
+			t.Fatalf("failed %d: %s", failed, stderr.String())
+		}
+		if stdout.String() != want {
+			t.Fatalf("got %q, want %q", stdout.String(), want)
+		}
+		if _, ok := env["JAMLE_KG_REGION"]; ok {
+			t.Fatal("assignment leaked into the caller's environment map")
+		}
+	})
+
+	t.Run("process environment", func(t *testing.T) {
+		t.Setenv("JAMLE_KG_REGION", "")
+		var stdout, stderr bytes.Buffer
+		if failed := runKeepGoing([]string{a, b}, opts, yaml.FormatYAML, jamle.UnmarshalOptions{}, &stdout, &stderr); failed != 0 {
+			t.Fatalf("failed %d: %s", failed, stderr.String())
+		}
+		if stdout.String() != want {
+			t.Fatalf("got %q, want %q", stdout.String(), want)
+		}
+	})
+
+	t.Run("reverse order", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		unmarshalOptions := jamle.UnmarshalOptions{Env: map[string]string{}}
+		if failed := runKeepGoing([]string{b, a}, opts, yaml.FormatYAML, unmarshalOptions, &stdout, &stderr); failed != 0 {
+			t.Fatalf("failed %d: %s", failed, stderr.String())
+		}
+		if want := "bucket: data-\nregion: us-east-1\n"; stdout.String() != want {
+			t.Fatalf("got %q, want %q", stdout.String(), want)
+		}
+	})
+}
+
+func TestRenderInput_MatchesToJSON(t *testing.T) {
+	unmarshalOptions := jamle.UnmarshalOptions{Env: map[string]string{"PORT": "8080"}}
+	noNewline := false
+
+	tests := []struct {
+		name   string
+		opts   cliOptions
+		path   string
+		input  string
+		indent string
+		format yaml.Format
+		lib    jamle.UnmarshalOptions
+	}{
+		{
+			name:   "yaml file",
+			opts:   cliOptions{Format: "auto", Indent: 2},
+			path:   "config.yaml",
+			input:  "server:\n  port: ${PORT}\n  name: api\n  tags: [a, b]\n",
+			indent: "  ",
+		},
+		{
+			name:   "json file",
+			opts:   cliOptions{Format: "auto", Indent: 2},
+			path:   "config.json",
+			input:  `{"id": 12345678901234567890, "port": "${PORT}"}`,
+			indent: "  ",
+		},
+		{
+			name:  "stdin json compact",
+			opts:  cliOptions{Format: "auto"},
+			path:  "-",
+			input: `{"id": 12345678901234567890, "port": "${PORT}"}`,
+		},
+		{
+			name:   "json content in yaml file",
+			opts:   cliOptions{Format: "auto", Indent: 4},
+			path:   "config.yml",
+			input:  `{"id": 12345678901234567890}`,
+			indent: "    ",
+			format: yaml.FormatYAML,
+		},
+		{
+			name:   "explicit yaml format on json content",
+			opts:   cliOptions{Format: "yaml", Indent: 2},
+			path:   "-",
+			input:  `{"n": 1.0, "port": "${PORT}"}`,
+			indent: "  ",
+			format: yaml.FormatYAML,
+		},
+		{
+			name:   "no trailing newline",
+			opts:   cliOptions{Format: "yaml", Indent: 2, NoTrailingNewline: true},
+			path:   "-",
+			input:  "port: ${PORT}\n",
+			indent: "  ",
+			lib:    jamle.UnmarshalOptions{TrailingNewline: &noNewline},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, err := renderInput(tt.opts, tt.path, []byte(tt.input), yaml.FormatJSON, unmarshalOptions)
+			if err != nil {
+				t.Fatalf("renderInput returned error: %v", err)
+			}
+
+			libOptions := tt.lib
+			libOptions.Env = unmarshalOptions.Env
+			format := tt.format
+			if format == "" {
+				format = yaml.FormatAuto
+			}
+			lib, err := jamle.ToJSONAs([]byte(tt.input), format, tt.indent, libOptions)
+			if err != nil {
+				t.Fatalf("ToJSONAs returned error: %v", err)
+			}
+
+			if !bytes.Equal(cli, lib) {
+				t.Fatalf("output mismatch:\ncli %q\nlib %q", cli, lib)
+			}
+		})
+	}
+}
+
+func TestRenderInput_ExplicitFormatWins(t *testing.T) {
+	input := []byte(`{"n": 1.0}`)
+
+	got, err := renderInput(cliOptions{Format: "yaml"}, "-", input, yaml.FormatJSON, jamle.UnmarshalOptions{})
+	if err != nil {
+		t.Fatalf("renderInput returned error: %v", err)
+	}
+	if want := `{"n":1}`; string(got) != want {
+		t.Fatalf("got %q, want %q from YAML decoding", got, want)
+	}
+}
+
+func TestRenderInput_KeyExpansion(t *testing.T) {
+	input := []byte("${REGION}_host: db.${REGION}.local\n")
+	env := map[string]string{"REGION": "eu"}
+
+	tests := []struct {
+		name   string
+		args   []string
+		format yaml.Format
+		want   string
+	}{
+		{name: "yaml default", args: []string{"--to", "yaml"}, format: yaml.FormatYAML, want: "eu_host: db.eu.local\n"},
+		{name: "json default", args: []string{"--to", "json"}, format: yaml.FormatJSON, want: "{\n  \"eu_host\": \"db.eu.local\"\n}\n"},
+		{name: "yaml literal keys", args: []string{"--to", "yaml", "--disable-key-expansion"}, format: yaml.FormatYAML, want: "${REGION}_host: db.eu.local\n"},
+		{name: "json literal keys", args: []string{"--to", "json", "--disable-key-expansion"}, format: yaml.FormatJSON, want: "{\n  \"${REGION}_host\": \"db.eu.local\"\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := cliOptions{}
+			parser := flags.NewParser(&opts, flags.None)
+			if _, err := parser.ParseArgs(append(tt.args, "config.yaml")); err != nil {
+				t.Fatalf("ParseArgs returned error: %v", err)
+			}
+
+			unmarshalOptions := newUnmarshalOptions(opts)
+			unmarshalOptions.Env = env
+			got, err := renderInput(opts, opts.Args.Input, input, tt.format, unmarshalOptions)
+			if err != nil {
+				t.Fatalf("renderInput returned error: %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManifestRecorder(t *testing.T) {
+	input := []byte(`host: ${HOST}
+port: ${PORT}
+password: ${DB_PASSWORD}
+mode: ${MODE:-dev}
+url: http://${HOST}:${PORT}
+extra: ${MISSING}
+`)
+	env := map[string]string{"HOST": "db.local", "DB_PASSWORD": "s3cret"}
+	defaults := map[string]string{"PORT": "5432", "HOST": "localhost"}
+
+	recorder := &manifestRecorder{env: env, defaults: defaults}
+	unmarshalOptions := jamle.UnmarshalOptions{Trace: recorder.event, Env: env, Defaults: defaults}
+	if _, err := renderInput(cliOptions{Format: "auto", Indent: 2}, "config.yaml", input, yaml.FormatYAML, unmarshalOptions); err != nil {
+		t.Fatalf("renderInput returned error: %v", err)
+	}
+
+	got, err := recorder.encode("config.yaml")
+	if err != nil {
+		t.Fatalf("encode returned error: %v", err)
+	}
+
+	want := `{
+  "input": "config.yaml",
+  "variables": [
+    {
+      "name": "DB_PASSWORD",
+      "value": "<redacted>",
+      "source": "environment"
+    },
+    {
+      "name": "HOST",
+      "value": "db.local",
+      "source": "environment"
+    },
+    {
+      "name": "MISSING",
+      "value": "",
+      "source": "unset"
+    },
+    {
+      "name": "MODE",
+      "value": "dev",
+      "source": "inline-default"
+    },
+    {
+      "name": "PORT",
+      "value": "5432",
+      "source": "defaults-file"
+    }
+  ]
+}
+`
+	if string(got) != want {
+		t.Fatalf("manifest mismatch:\ngot  %s\nwant %s", got, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "out", "subs.json")
+	if err := writeManifest(path, "config.yaml", &manifestRecorder{}); err != nil {
+		t.Fatalf("writeManifest returned error: %v", err)
+	}
+	empty, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n  \"input\": \"config.yaml\",\n  \"variables\": []\n}\n"; string(empty) != want {
+		t.Fatalf("empty manifest: got %q, want %q", empty, want)
+	}
+}
+
+func TestManifestRecorder_NestedSecret(t *testing.T) {
+	env := map[string]string{"DB_PASSWORD": "hunter2", "DSN": "a:${DB_PASSWORD}", "ECHO": "hunter2"}
+
+	for _, input := range []string{"dsn: ${DSN}\n", "echo: ${ECHO}\ndsn: ${DSN}\n"} {
+		recorder := &manifestRecorder{env: env}
+		unmarshalOptions := jamle.UnmarshalOptions{Trace: recorder.event, Env: env}
+		if _, err := renderInput(cliOptions{Format: "auto", Indent: 2}, "config.yaml", []byte(input), yaml.FormatYAML, unmarshalOptions); err != nil {
+			t.Fatalf("renderInput returned error: %v", err)
+		}
+
+		got, err := recorder.encode("config.yaml")
+		if err != nil {
+			t.Fatalf("encode returned error: %v", err)
+		}
+
+		if strings.Contains(string(got), "hunter2") {
+			t.Fatalf("manifest leaks a nested secret for %q:\n%s", input, got)
+		}
+		if !strings.Contains(string(got), `"value": "a:<redacted>"`) {
+			t.Fatalf("manifest should keep the non-secret part for %q:\n%s", input, got)
+		}
+	}
+}
+
+func TestRenderInput_NoTrailingNewline(t *testing.T) {
+	unmarshalOptions := jamle.UnmarshalOptions{Env: map[string]string{"HOST": "db"}}
+	input := []byte("host: ${HOST}\n")
+
+	for _, preserve := range []bool{false, true} {
+		opts := cliOptions{Format: "auto", Indent: 2, PreserveStyle: preserve, NoTrailingNewline: true}
+		out, err := renderInput(opts, "config.yaml", input, yaml.FormatYAML, unmarshalOptions)
+		if err != nil {
+			t.Fatalf("renderInput(preserve=%t) returned error: %v", preserve, err)
+		}
+
+		if string(out) != "host: db" {
+			t.Fatalf("renderInput(preserve=%t) = %q, want %q", preserve, out, "host: db")
+		}
+	}
+}
+
+func TestLoadInput_Remote(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/config.yaml":
+			_, _ = io.WriteString(w, "host: ${HOST}\n")
+		case "/slow.yaml":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	opts := cliOptions{AllowRemote: true, Timeout: time.Second, MaxBytes: 1024}
+
+	data, err := loadInput(server.URL+"/config.yaml", opts)
+	if err != nil || string(data) != "host: ${HOST}\n" {
+		t.Fatalf("loadInput = %q, %v", data, err)
+	}
+
+	if format := detectInputFormat("auto", server.URL+"/config.yaml?ref=main", []byte("{}")); format != "yaml" {
+		t.Fatalf("detectInputFormat = %q, want yaml", format)
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		opts     cliOptions
+		wantCode int
+		context  string
+	}{
+		{name: "not allowed", url: server.URL + "/config.yaml", opts: cliOptions{MaxBytes: 1024}, wantCode: 2, context: "reading input"},
+		{name: "not found", url: server.URL + "/missing.yaml", opts: opts, wantCode: 1, context: "fetching input"},
+		{name: "timeout", url: server.URL + "/slow.yaml", opts: cliOptions{AllowRemote: true, Timeout: 50 * time.Millisecond, MaxBytes: 1024}, wantCode: 1, context: "fetching input"},
+		{name: "too large", url: server.URL + "/config.yaml", opts: cliOptions{AllowRemote: true, Timeout: time.Second, MaxBytes: 4}, wantCode: 1, context: "fetching input"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadInput(tt.url, tt.opts)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+
+			if code, context := inputErrorExit(err); code != tt.wantCode || context != tt.context {
+				t.Fatalf("got %d %q, want %d %q (%v)", code, context, tt.wantCode, tt.context, err)
+			}
+		})
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for a writer and a concurrent reader.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRunWatch(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "config.yaml")
+	defaults := filepath.Join(dir, "defaults.env")
+	if err := os.WriteFile(input, []byte("host: ${HOST}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(defaults, []byte("HOST=one\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := cliOptions{Format: "auto", Indent: 2, MaxBytes: 1024}
+	render := func() ([]byte, error) {
+		values, err := loadDefaultsFile(defaults)
+		if err != nil {
+			return nil, err
+		}
+
+		unmarshalOptions := jamle.UnmarshalOptions{Env: map[string]string{}, Defaults: values}
+		return renderWatched(input, opts, yaml.FormatYAML, unmarshalOptions)
+	}
+
+	var stdout, stderr syncBuffer
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		runWatch(ctx, []string{input, defaults}, 5*time.Millisecond, render, &stdout, &stderr, false)
+		close(done)
+	}()
+
+	waitFor := func(want string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !strings.Contains(stdout.String(), want) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %q; stdout %q, stderr %q", want, stdout.String(), stderr.String())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitFor("host: one\n")
+	if err := os.WriteFile(defaults, []byte("HOST=second\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("host: second\n")
+	if err := os.WriteFile(input, []byte("host: ${HOST}\nport: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("port: 1\n")
+
+	cancel()
+	<-done
+
+	if got := strings.Count(stdout.String(), "==> "); got != 3 {
+		t.Fatalf("got %d separators, want 3:\n%s", got, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), " "+input+" <==\n") {
+		t.Fatalf("separator lacks input path: %q", stdout.String())
+	}
+}
+
+func TestErrorExitCodes(t *testing.T) {
+	_, err := readInput(filepath.Join(t.TempDir(), "missing.yaml"), 1024)
+	if code, context := inputErrorExit(err); code != 2 || context != "input file not found" {
+		t.Fatalf("missing file: got %d %q", code, context)
+	}
+
+	denied := &os.PathError{Op: "open", Path: "secret.yaml", Err: os.ErrPermission}
+	if code, context := inputErrorExit(denied); code != 2 || context != "input file not readable" {
+		t.Fatalf("permission denied: got %d %q", code, context)
+	}
+
+	if code, context := inputErrorExit(errors.New("input exceeds --max-bytes (1 bytes)")); code != 1 || context != "reading input" {
+		t.Fatalf("other read error: got %d %q", code, context)
+	}
+
+	opts := cliOptions{Format: "auto"}
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "parse error", input: "a: [\n", want: "parsing input"},
+		{name: "variable error", input: "a: ${JAMLE_EXIT_MISSING:?required}\n", want: "resolving variables"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := renderInput(opts, "config.yaml", []byte(tt.input), yaml.FormatJSON, jamle.UnmarshalOptions{})
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if got := processErrorContext(err); got != tt.want {
+				t.Fatalf("context mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := processErrorContext(outputError{err: errors.New("bad value")}); got != "encoding output" {
+		t.Fatalf("encode context mismatch: got %q", got)
+	}
+}
+
+func TestParseEnvRecords(t *testing.T) {
+	got, err := parseEnvRecords([]byte("HOST=db.local\x00EMPTY=\x00MULTI=a\nb=c\x00\x00HOST=override\x00" +
+		"BASH_FUNC_x%%=() {  echo $1\n}\x00"))
+	if err != nil {
+		t.Fatalf("parseEnvRecords returned error: %v", err)
+	}
+
+	want := map[string]string{
+		"HOST":          "override",
+		"EMPTY":         "",
+		"MULTI":         "a\nb=c",
+		"BASH_FUNC_x%%": "() {  echo $1\n}",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("records mismatch:\ngot  %#v\nwant %#v", got, want)
+	}
+
+	for _, input := range []string{"NOVALUE\x00", "HOST=x\x00BASH_FUNC_x%%\x00"} {
+		if _, err := parseEnvRecords([]byte(input)); err == nil {
+			t.Fatalf("expected error for %q", input)
+		}
+	}
+}
+
+func TestReadEnvFD(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.WriteString("JAMLE_FD_HOST=fd.local\x00"); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+
+	env, err := readEnvFD(int(r.Fd()), 1024)
+	_ = r.Close()
+	if err != nil {
+		t.Fatalf("readEnvFD returned error: %v", err)
+	}
+
+	var got map[string]string
+	err = jamle.UnmarshalWithOptions([]byte("host: ${JAMLE_FD_HOST}\nhome: ${HOME}\n"), &got, jamle.UnmarshalOptions{Env: env})
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+	if got["host"] != "fd.local" || got["home"] != "" {
+		t.Fatalf("only the fd environment should be visible, got %v", got)
+	}
+
+	if _, err := readEnvFD(-1, 1024); err == nil {
+		t.Fatal("expected error for negative descriptor")
+	}
+}
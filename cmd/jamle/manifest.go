@@ -0,0 +1,124 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"os"
+	"slices"
+
+	"github.com/woozymasta/jamle"
+)
+
+// Sources reported for variables in a --manifest file.
+const (
+	sourceEnvironment = "environment"
+	sourceDefaults    = "defaults-file"
+	sourceInline      = "inline-default"
+	sourceUnset       = "unset"
+)
+
+// manifestEntry is one resolved variable of a --manifest file.
+type manifestEntry struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// manifest is the --manifest document written next to the main output.
+type manifest struct {
+	Input     string          `json:"input"`
+	Variables []manifestEntry `json:"variables"`
+}
+
+// manifestRecorder collects substitutions from trace events for --manifest.
+// env is the --env-fd environment, or nil for the process environment.
+// Values are redacted like --trace output when the manifest is encoded, so
+// a secret seen late in the run is still hidden in earlier entries.
+type manifestRecorder struct {
+	env      map[string]string
+	defaults map[string]string
+	entries  []manifestEntry
+	secretRedactor
+}
+
+// event records the substitutions of one trace event, once per distinct
+// variable, value and source.
+func (r *manifestRecorder) event(e jamle.TraceEvent) {
+	r.remember(e.Substitutions)
+	for _, sub := range e.Substitutions {
+		entry := manifestEntry{Name: sub.Var, Value: sub.Value, Source: r.source(sub)}
+		if !slices.Contains(r.entries, entry) {
+			r.entries = append(r.entries, entry)
+		}
+	}
+}
+
+// source reports where the value of a substitution came from.
+func (r *manifestRecorder) source(sub jamle.TraceSubstitution) string {
+	if sub.Default {
+		return sourceInline
+	}
+
+	var found bool
+	if r.env != nil {
+		_, found = r.env[sub.Var]
+	} else {
+		_, found = os.LookupEnv(sub.Var)
+	}
+
+	if found {
+		return sourceEnvironment
+	}
+
+	if _, ok := r.defaults[sub.Var]; ok {
+		return sourceDefaults
+	}
+
+	return sourceUnset
+}
+
+// encode returns the manifest as indented JSON, variables sorted by name,
+// with sensitive values redacted.
+func (r *manifestRecorder) encode(input string) ([]byte, error) {
+	variables := make([]manifestEntry, 0, len(r.entries))
+	for _, entry := range r.entries {
+		entry.Value = r.redactValue(entry.Name, entry.Value)
+		if !slices.Contains(variables, entry) {
+			variables = append(variables, entry)
+		}
+	}
+
+	slices.SortStableFunc(variables, func(a, b manifestEntry) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest{Input: input, Variables: variables}); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// writeManifest writes the --manifest file for input.
+func writeManifest(path, input string, recorder *manifestRecorder) error {
+	path, err := expandPathArg(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := recorder.encode(input)
+	if err != nil {
+		return err
+	}
+
+	return writeOutputFile(path, data)
+}
@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// errRemoteDisabled reports a URL input given without --allow-remote.
+var errRemoteDisabled = errors.New("remote input requires --allow-remote")
+
+// isRemoteInput reports whether path is an http:// or https:// URL.
+func isRemoteInput(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// remoteFormatPath returns the URL path of rawURL for input format
+// detection, so a query string does not hide the file extension.
+func remoteFormatPath(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	return parsed.Path
+}
+
+// fetchInput downloads rawURL within timeout, honoring HTTP_PROXY,
+// HTTPS_PROXY and NO_PROXY. Network failures, non-2xx responses and
+// oversized bodies are returned as fetchError.
+func fetchInput(rawURL string, timeout time.Duration, maxBytes int64) ([]byte, error) {
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+	}
+
+	resp, err := client.Get(rawURL) // #nosec G107 -- CLI fetches a user-provided URL behind --allow-remote.
+	if err != nil {
+		return nil, fetchError{err: err}
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fetchError{err: fmt.Errorf("unexpected status %s", resp.Status)}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fetchError{err: err}
+	}
+
+	if int64(len(data)) > maxBytes {
+		return nil, fetchError{err: fmt.Errorf("input exceeds --max-bytes (%d bytes)", maxBytes)}
+	}
+
+	return data, nil
+}
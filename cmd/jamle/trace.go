@@ -0,0 +1,94 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/woozymasta/jamle"
+)
+
+// redactedValue replaces values of sensitive variables in trace output.
+const redactedValue = "<redacted>"
+
+// sensitiveNameParts mark variable names whose values are redacted.
+var sensitiveNameParts = []string{
+	"PASSWORD", "PASSWD", "PASSPHRASE", "SECRET", "TOKEN",
+	"CREDENTIAL", "PRIVATE", "AUTH", "KEY",
+}
+
+// secretRedactor remembers values of sensitive variables, so they are also
+// hidden in other text that contains them, such as the value of a variable
+// defined as a:${DB_PASSWORD}.
+type secretRedactor struct {
+	secrets []string
+}
+
+// traceLogger writes --trace events with sensitive values redacted.
+type traceLogger struct {
+	w io.Writer
+	secretRedactor
+}
+
+// event writes one trace event with sensitive values redacted.
+func (l *traceLogger) event(e jamle.TraceEvent) {
+	l.remember(e.Substitutions)
+
+	_, _ = fmt.Fprintf(l.w, "trace: %q pass %d: %q -> %q\n",
+		l.redact(e.Scalar), e.Pass, l.redact(e.Before), l.redact(e.After))
+
+	for _, sub := range e.Substitutions {
+		value := l.redactValue(sub.Var, sub.Value)
+		source := "env"
+		if sub.Default {
+			source = "default"
+		}
+
+		_, _ = fmt.Fprintf(l.w, "trace:   ${%s%s} = %q (%s)\n", sub.Var, sub.Operator, value, source)
+	}
+}
+
+// remember records the non-empty values of sensitive variables in subs.
+func (r *secretRedactor) remember(subs []jamle.TraceSubstitution) {
+	for _, sub := range subs {
+		if sub.Value != "" && isSensitiveName(sub.Var) && !slices.Contains(r.secrets, sub.Value) {
+			r.secrets = append(r.secrets, sub.Value)
+		}
+	}
+}
+
+// redact replaces all remembered sensitive values in s.
+func (r *secretRedactor) redact(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redactedValue)
+	}
+
+	return s
+}
+
+// redactValue hides the whole value of a sensitive variable and remembered
+// sensitive values inside any other value.
+func (r *secretRedactor) redactValue(name, value string) string {
+	if value != "" && isSensitiveName(name) {
+		return redactedValue
+	}
+
+	return r.redact(value)
+}
+
+// isSensitiveName reports whether a variable name looks like it holds a secret.
+func isSensitiveName(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, part := range sensitiveNameParts {
+		if strings.Contains(upper, part) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/woozymasta/jamle"
+	"github.com/woozymasta/jamle/yaml"
+)
+
+// watchInterval is how often --watch polls the watched files.
+const watchInterval = 500 * time.Millisecond
+
+// fileStamp identifies one version of a watched file.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+// statFiles returns the current stamps of paths; missing files get a
+// zero stamp, so their creation counts as a change.
+func statFiles(paths []string) []fileStamp {
+	stamps := make([]fileStamp, len(paths))
+	for i, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			stamps[i] = fileStamp{modTime: info.ModTime(), size: info.Size(), exists: true}
+		}
+	}
+
+	return stamps
+}
+
+// runWatch renders once and again whenever a file of paths changes, until
+// ctx is done. Each output follows a timestamped separator line; failed
+// renders are reported to stderr and watching goes on.
+func runWatch(ctx context.Context, paths []string, interval time.Duration, render func() ([]byte, error), stdout, stderr io.Writer, jsonErrors bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last []fileStamp
+	for {
+		if stamps := statFiles(paths); !slices.Equal(stamps, last) {
+			last = stamps
+
+			output, err := render()
+			if err != nil {
+				writeError(stderr, jsonErrors, "", err)
+			} else {
+				_, _ = fmt.Fprintf(stdout, "==> %s %s <==\n", time.Now().Format(time.RFC3339), paths[0])
+				_, _ = stdout.Write(output)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// renderWatched reads and renders the --watch input. Assignments made by
+// ${VAR:=default} are undone afterwards, so every render starts from the
+// same environment.
+func renderWatched(path string, opts cliOptions, outputFormat yaml.Format, unmarshalOptions jamle.UnmarshalOptions) ([]byte, error) {
+	input, err := loadInput(path, opts)
+	if err != nil {
+		_, context := inputErrorExit(err)
+		return nil, fmt.Errorf("%s: %w", context, err)
+	}
+
+	if len(input) == 0 {
+		return nil, errors.New("reading input: input is empty")
+	}
+
+	var output []byte
+	jamle.WithTempEnv(func() {
+		output, err = renderInput(opts, path, input, outputFormat, unmarshalOptions)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", processErrorContext(err), err)
+	}
+
+	return output, nil
+}
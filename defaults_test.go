@@ -0,0 +1,82 @@
+package jamle
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestExpandStringWithOptions_Defaults(t *testing.T) {
+	env := map[string]string{"HOST": "db.prod", "EMPTY": ""}
+	defaults := map[string]string{"HOST": "localhost", "PORT": "5432", "EMPTY": "unused"}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "environment wins", input: "${HOST:-inline}", want: "db.prod"},
+		{name: "defaults win over inline", input: "${PORT:-1}", want: "5432"},
+		{name: "inline wins when neither has it", input: "${MODE:-dev}", want: "dev"},
+		{name: "unset without inline default", input: "${MODE}", want: ""},
+		{name: "defaults without inline default", input: "${PORT}", want: "5432"},
+		{name: "empty value is not replaced", input: "${EMPTY-x}", want: ""},
+		{name: "colon default still treats empty as unset", input: "${EMPTY:-x}", want: "x"},
+		{name: "required satisfied by defaults", input: "${PORT:?}", want: "5432"},
+		{name: "assignment keeps defaults value", input: "${PORT:=1}/${PORT}", want: "5432/5432"},
+		{name: "assignment beats defaults for later reads", input: "${NEW:=v}/${NEW}", want: "v/v"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandStringWithOptions(tt.input, UnmarshalOptions{Env: env, Defaults: defaults})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if len(defaults) != 3 || defaults["PORT"] != "5432" {
+		t.Fatalf("defaults map was modified: %v", defaults)
+	}
+}
+
+func TestUnmarshalWithOptions_Defaults(t *testing.T) {
+	type config struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+		Mode string `json:"mode"`
+	}
+
+	input := []byte("host: ${HOST:-inline-host}\nport: ${PORT:-1}\nmode: ${MODE:-inline-mode}\n")
+	defaults := map[string]string{"HOST": "default-host", "PORT": "2", "MODE": "default-mode"}
+
+	t.Setenv("JAMLE_DEFAULTS_HOST", "env-host")
+	var got config
+	err := UnmarshalWithOptions(input, &got, UnmarshalOptions{
+		Prefix:   "JAMLE_DEFAULTS_",
+		Defaults: map[string]string{"HOST": "default-host", "PORT": "2"},
+	})
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+
+	// Each layer wins for one field: environment, Defaults, inline.
+	want := config{Host: "env-host", Port: 2, Mode: "inline-mode"}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	got = config{}
+	opts := UnmarshalOptions{Env: map[string]string{}, Defaults: maps.Clone(defaults)}
+	if err := UnmarshalWithOptions(input, &got, opts); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+
+	want = config{Host: "default-host", Port: 2, Mode: "default-mode"}
+	if got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
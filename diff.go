@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Diff expands document a with envA and document b with envB and returns a
+// human-readable diff of the resulting structures, one line per change,
+// sorted by path. Lines are "+ path: value" for keys or items only in b,
+// "- path: value" for those only in a and "~ path: old -> new" for
+// changed values.
+// Paths use dotted keys with [N] sequence indices, values are JSON encoded.
+// Identical documents yield an empty string. Expansion is isolated like
+// UnmarshalOptions.Env, so the process environment is not read or modified.
+func Diff(a, b []byte, envA, envB map[string]string) (string, error) {
+	docA, err := decodeForDiff(a, envA)
+	if err != nil {
+		return "", fmt.Errorf("expanding first document: %w", err)
+	}
+
+	docB, err := decodeForDiff(b, envB)
+	if err != nil {
+		return "", fmt.Errorf("expanding second document: %w", err)
+	}
+
+	var lines []string
+	diffValues("", docA, docB, &lines)
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// decodeForDiff expands and decodes one document with an isolated env.
+func decodeForDiff(data []byte, env map[string]string) (any, error) {
+	if env == nil {
+		env = map[string]string{}
+	}
+
+	var doc any
+	if err := UnmarshalWithOptions(data, &doc, UnmarshalOptions{Env: env}); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+// diffValues appends diff lines for a and b at path.
+func diffValues(path string, a, b any, lines *[]string) {
+	mapA, okA := a.(map[string]any)
+	mapB, okB := b.(map[string]any)
+	if okA && okB {
+		keys := make([]string, 0, len(mapA)+len(mapB))
+		for key := range mapA {
+			keys = append(keys, key)
+		}
+		for key := range mapB {
+			if _, ok := mapA[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			next := key
+			if path != "" {
+				next = path + "." + key
+			}
+
+			valueA, inA := mapA[key]
+			valueB, inB := mapB[key]
+			switch {
+			case !inA:
+				*lines = append(*lines, "+ "+next+": "+diffValueString(valueB))
+			case !inB:
+				*lines = append(*lines, "- "+next+": "+diffValueString(valueA))
+			default:
+				diffValues(next, valueA, valueB, lines)
+			}
+		}
+
+		return
+	}
+
+	listA, okA := a.([]any)
+	listB, okB := b.([]any)
+	if okA && okB {
+		for i := range max(len(listA), len(listB)) {
+			next := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case i >= len(listA):
+				*lines = append(*lines, "+ "+next+": "+diffValueString(listB[i]))
+			case i >= len(listB):
+				*lines = append(*lines, "- "+next+": "+diffValueString(listA[i]))
+			default:
+				diffValues(next, listA[i], listB[i], lines)
+			}
+		}
+
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		if path == "" {
+			path = "."
+		}
+
+		*lines = append(*lines, "~ "+path+": "+diffValueString(a)+" -> "+diffValueString(b))
+	}
+}
+
+// diffValueString formats a decoded value for diff output.
+func diffValueString(v any) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(raw)
+}
@@ -0,0 +1,70 @@
+package jamle
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	template := []byte(`
+server:
+  host: ${HOST}
+  port: ${PORT:-8080}
+  debug: ${DEBUG:-false}
+replicas: ${REPLICAS:-1}
+hosts:
+  - ${HOST}
+  - ${BACKUP_HOST:-}
+`)
+
+	staging := map[string]string{"HOST": "stage.local", "DEBUG": "true", "BACKUP_HOST": "b.local"}
+	production := map[string]string{"HOST": "prod.local", "REPLICAS": "3", "BACKUP_HOST": "b.local"}
+
+	got, err := Diff(template, template, staging, production)
+	if err != nil {
+		t.Fatalf("Diff returned error: %v", err)
+	}
+
+	want := `~ hosts[0]: "stage.local" -> "prod.local"
+~ replicas: 1 -> 3
+~ server.debug: true -> false
+~ server.host: "stage.local" -> "prod.local"
+`
+	if got != want {
+		t.Fatalf("diff mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	t.Run("added and removed", func(t *testing.T) {
+		a := []byte("a: 1\nlist: [x, y]\nnested: {k: v}\n")
+		b := []byte("b: ${B}\nlist: [x]\nnested: {k: v, extra: [1]}\n")
+
+		got, err := Diff(a, b, nil, map[string]string{"B": "two"})
+		if err != nil {
+			t.Fatalf("Diff returned error: %v", err)
+		}
+
+		want := `- a: 1
++ b: "two"
+- list[1]: "y"
++ nested.extra: [1]
+`
+		if got != want {
+			t.Fatalf("diff mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("identical and type change", func(t *testing.T) {
+		got, err := Diff([]byte("a: ${A}\n"), []byte("a: x\n"), map[string]string{"A": "x"}, nil)
+		if err != nil || got != "" {
+			t.Fatalf("expected empty diff, got %q (err %v)", got, err)
+		}
+
+		got, err = Diff([]byte("a: [1]\n"), []byte("a: {b: 1}\n"), nil, nil)
+		if err != nil || got != "~ a: [1] -> {\"b\":1}\n" {
+			t.Fatalf("type change mismatch: got %q (err %v)", got, err)
+		}
+	})
+
+	t.Run("expansion error", func(t *testing.T) {
+		if _, err := Diff([]byte("a: 1\n"), []byte("a: ${A:?required}\n"), nil, nil); err == nil {
+			t.Fatal("expected error for missing required variable")
+		}
+	})
+}
@@ -15,6 +15,28 @@ Core APIs:
   - UnmarshalWithOptions: decode with configurable resolver and behavior.
   - UnmarshalAll: decode all YAML documents from a stream into a slice.
   - UnmarshalAllWithOptions: decode all YAML documents with options.
+  - UnmarshalVerbose: decode with options and return non-fatal warnings.
+  - UnmarshalRaw: decode and also return the expanded document as YAML.
+  - UnmarshalStats: decode with options and return expansion statistics.
+  - Compile: parse a document once and Render it with many environments.
+  - UnmarshalOrdered: decode a mapping into an order-preserving *OrderedMap.
+  - UnmarshalYAMLTags: decode with yaml.v3, honoring yaml tags and UnmarshalYAML.
+  - UnmarshalTOML: decode TOML with the same expansion over string values.
+  - ExpandJSON: expand JSON strings and re-emit JSON with exact numbers.
+  - ToJSON: expand YAML or JSON and encode it as (indented) JSON like the CLI.
+  - ToJSONAs: ToJSON with an explicit input format instead of sniffing.
+  - ToCanonicalJSON: sorted, two-space indented JSON for golden-file tests.
+  - UnmarshalJSONStrict: expand JSON strings and decode with encoding/json.
+  - Diff: compare two documents after expansion with separate environments.
+  - ExpandPath: expand only the subtree at a dotted path and re-emit YAML;
+    UnmarshalOptions.PreserveDocumentHeader keeps directives and "---".
+  - ExpandFiles: expand many YAML and JSON files with a shared resolver.
+  - ExpandString: expand placeholders in one string without YAML parsing.
+  - ExtractVariables: list variable names referenced by a document.
+  - DumpEnv: write effective values of referenced variables as dotenv lines.
+  - UnusedVars: list provided variables that a document never references.
+  - MissingRequired: list ${VAR:?msg} variables a map leaves unset or empty.
+  - WithTempEnv: restore the process environment after ${VAR:=default} runs.
 
 Supported variable expansion syntax:
 
@@ -22,7 +44,24 @@ Supported variable expansion syntax:
   - ${VAR:-default}  Value of VAR, or "default" if VAR is unset or empty.
   - ${VAR:=default}  Value of VAR, or "default" if unset/empty. Also sets VAR to "default" in the current environment.
   - ${VAR:?error}    Value of VAR, or returns an error with "error" message if VAR is unset or empty.
+  - ${VAR:?int}      Like :?, and fails with ErrValidation unless the value parses as
+    int, float, bool, url or duration; ${VAR:?int:message} adds a message.
+  - ${VAR:+alt}      "alt" if VAR is set and not empty, otherwise empty string.
+  - ${VAR-default}   No-colon forms -, =, ? and + act like their colon forms,
+    but test only whether VAR is unset; an empty value counts as set.
+  - ${VAR@U}         Value of VAR converted to upper case.
+  - ${VAR@u}         Value of VAR with the first character converted to upper case.
+  - ${VAR@L}         Value of VAR converted to lower case.
+  - ${VAR@Q}         Value of VAR single-quoted for safe reuse as shell input.
+  - ${VAR|split:,}   Value of VAR split by "," into a sequence; |split
+    splits on whitespace. Must be the whole scalar; empty items are dropped.
   - $${VAR}          Escaping. Evaluates to the literal string ${VAR} without expansion.
+  - $$               Escaping. A $$ not followed by { evaluates to a single $.
+
+Defaults of -, :-, = and := may use bare references: ${A:-$B} works like
+${A:-${B}}. Bare $B is not expanded outside defaults; inside a default,
+$$B yields a literal $B. UnmarshalOptions.EscapePrefix replaces $$ with
+another escape ending in $, e.g. \$ for \${VAR}.
 
 Example (default behavior with process environment):
 
@@ -108,12 +147,116 @@ Behavior notes:
     as JSON strings.
   - Use UnmarshalOptions.DisableAssignment to make ${VAR:=default}
     behave like ${VAR:-default} without mutating resolver state.
+  - Use UnmarshalOptions.DisallowAssign to reject every ${VAR:=default}
+    with ErrAssignmentDisabled, guaranteeing the environment is never set.
+  - Use UnmarshalOptions.ErrorOnDefaultUsed to fail with ErrDefaultUsed
+    whenever ${VAR:-default} or ${VAR:=default} falls back to its default.
+  - Use UnmarshalOptions.ErrorOnUnset to fail with ErrUnsetVariable for a
+    plain ${VAR} of an unset variable; ${VAR:-} still yields empty.
+  - Use UnmarshalOptions.ExpandTags to expand ${...} in node tags such as
+    !type-${VARIANT}. Tags are expanded in the source text before parsing,
+    because YAML does not allow braces in tags.
+  - Use UnmarshalOptions.EmptyIsSet to keep variables set to an empty
+    string for :-, := and :? instead of falling back to the default,
+    mirroring Bash's ${VAR-x} forms.
+  - Use UnmarshalOptions.Sources to layer lookups such as overrides, then
+    os.LookupEnv, then defaults; the first source that has a name wins.
+  - Use UnmarshalOptions.Defaults to supply fallback values from Go; they
+    apply after the environment and before inline ${VAR:-default} values.
+  - Use UnmarshalOptions.Prefix to namespace lookups: with "MYAPP_",
+    ${HOST} reads MYAPP_HOST and falls back to HOST when it is unset.
+  - Use UnmarshalOptions.CaseInsensitive to match ${host} to HOST.
+    Names that fold to several variables return ErrAmbiguousVariable.
+  - Use UnmarshalOptions.SuggestVariables to add "did you mean DB_HOST?"
+    to errors for missing variables. Leave it off where error messages
+    must not reveal other variable names.
+  - Use RegisterResolver to add named backends: ${vault:secret/db#password}
+    calls the "vault" backend with key "secret/db#password". Unprefixed
+    placeholders keep resolving through the environment.
+  - Use UnmarshalOptions.InterpretEscapes to turn \n, \t and \\ in
+    default values into newline, tab and backslash.
   - Use UnmarshalOptions.DisableRequiredErrors to make ${VAR:?error}
     behave like ${VAR}.
+  - Use UnmarshalOptions.WindowsVars to expand %NAME% references too.
+    They are substituted before ${...}, and unset ones stay literal.
+  - Use UnmarshalOptions.Arithmetic to evaluate integer $((EXPR))
+    expressions, e.g. $((${PORT:-8080}+1)), after ${...} expansion.
+  - Use UnmarshalOptions.StrictDollar to reject a stray $ or an
+    unterminated ${ with ErrStrictDollar instead of keeping it verbatim.
+  - Use UnmarshalOptions.Validators to check resolved values by variable
+    name; ValidateIntRange and ValidateOneOf cover numeric and enum values.
+    Failures return ErrValidation naming the variable and the constraint.
+  - Use UnmarshalOptions.Transform to rewrite resolved values, e.g. to
+    decode base64 secrets, before they are validated and used.
+  - Use UnmarshalOptions.UnsetErrorFormat to word the error of ${VAR:?}
+    without a message, e.g. "%s is required, see <runbook URL>".
+  - Use UnmarshalOptions.Trace to observe every expansion pass as a
+    TraceEvent. Events carry resolved values, so redact secrets before
+    logging them.
+  - Use UnmarshalOptions.Logger to log resolutions and assignments through
+    log/slog. Values are left out unless UnmarshalOptions.LogValues is set.
   - Use UnmarshalOptions.IgnoreExpandPaths or struct tag
     `jamle:"noexpand"` when YAML contains shell `${...}` fragments
-    that must stay literal.
+    that must stay literal. A `# jamle:literal` head or line comment
+    does the same for one node and its children in the document itself.
   - For literal `${...}` in expandable fields, use `$${...}` escaping.
+  - Transforms ${VAR@X} support only U, u, L and Q; other operators
+    return ErrUnsupportedTransform. ${VAR@Q} of an unset VAR is empty.
+  - Input containing NUL bytes or invalid UTF-8 is rejected with
+    ErrBinaryInput before parsing; CheckText exposes the same check.
+    Scalars or variable values with control characters U+0000 to U+0002,
+    which jamle uses internally for escaping, fail with ErrBinaryInput too.
+  - Variable names must match [A-Za-z_][A-Za-z0-9_]*. Malformed names
+    such as ${MY/VAR} or ${1VAR} return ErrInvalidVariableName.
+  - Names may be composed: ${PREFIX_${REGION}} resolves ${REGION} first,
+    then looks up the composed name, e.g. PREFIX_US. Values that would
+    put other characters into a name return ErrInvalidVariableName.
+  - Expansion failures are returned as *ExpandError carrying the variable
+    name, the original scalar and its line:column in the document; use
+    errors.As to inspect them.
+  - Use UnmarshalOptions.ParseExpandedYAML to inject a whole mapping or
+    sequence via a plain scalar that is exactly one ${...} placeholder.
+    The value of a merge key (<<: ${DEFAULTS}) is always parsed this way
+    and must yield a mapping, or ErrInvalidMerge is returned.
+  - time.Duration fields accept Go duration strings ("30s", "1m30s") and
+    time.Time fields accept RFC 3339 timestamps after expansion. Bare
+    integers are rejected for durations to avoid unit ambiguity.
+  - Variable values containing ${...} are expanded when looked up (unless
+    UnmarshalOptions.DisableResolvedExpansion keeps them literal); a value
+    that refers back to itself returns ErrExpansionCycle. Nested defaults
+    written in the document take one pass per level, so chains deeper
+    than UnmarshalOptions.MaxPasses stay partially expanded. Operands are
+    resolved only when taken, so ${A:-${B:?msg}} does not fail while A is
+    set and ${A:+${B}} ignores B while A is unset.
+  - Mapping keys are expanded like values, whether plain or quoted.
+    Keys that collide after expansion return ErrDuplicateKey.
+    UnmarshalOptions.DisableKeyExpansion keeps keys literal.
+  - Fields typed json.RawMessage receive the expanded subtree re-encoded
+    as JSON, which is handy for plugin sections decoded later.
+  - TOML strings containing ${...} are re-typed after expansion like
+    plain YAML scalars, so "${PORT:-8080}" decodes into an int field.
+  - Plain (unquoted) scalars changed by expansion are re-typed, so
+    `port: ${PORT:-8080}` decodes as an integer. Use
+    UnmarshalOptions.KeepStrings to keep all expanded values as strings.
+  - Braces are flow indicators, so inside flow collections placeholders
+    must be quoted: `ports: ["${P1:-80}", "${P2:-443}"]`. Quoted values
+    stay strings; use block style when they must be re-typed. Flow style
+    is kept by ExpandPath.
+  - Re-typing follows YAML 1.2 booleans: `flag: ${FLAG:-yes}` decodes
+    as the string "yes" into generic targets. Set UnmarshalOptions.YAMLBooleans
+    to YAMLBooleans11 to treat y/n, yes/no and on/off as booleans. Typed
+    bool fields accept the YAML 1.1 tokens in both modes (go-yaml behavior).
+  - Use UnmarshalOptions.OnlyVars for multi-stage expansion: only listed
+    variables are resolved, other placeholders are kept verbatim.
+    UnmarshalOptions.SetOnly does the same for variables that are unset,
+    deferring their defaults to the later stage.
+  - Use UnmarshalOptions.Env to resolve variables from an isolated map.
+    With Env set, calls never read or modify the process environment
+    and are safe to run concurrently. ${VAR:=default} then assigns into a
+    per-call overlay shared by later scalars in document order.
+  - Use UnmarshalOptions.Environ to pass a captured os.Environ snapshot
+    instead. It is isolated like Env; Env entries override Environ ones,
+    and neither consults Resolver or the process environment.
   - ${VAR:=default} requires assignment support from resolver.
     With UnmarshalWithOptions/UnmarshalAllWithOptions, this operator returns
     an error unless the resolver also supports setting values.
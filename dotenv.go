@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	goyaml "go.yaml.in/yaml/v3"
+)
+
+// dotenvEscaper escapes a value inside a double-quoted dotenv value.
+var dotenvEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	`$`, `\$`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+)
+
+// DumpEnv expands all documents of data and returns the effective values of
+// every variable they reference as sorted dotenv KEY=value lines, including
+// values assigned by ${VAR:=default}. Variables that stay unset are omitted.
+// Values with characters outside [A-Za-z0-9_./:@%+,-] are double-quoted,
+// with \, ", $, newline, CR and tab backslash-escaped.
+// With the process environment as source, := assignments are applied to it
+// as in Unmarshal; use Env or Environ to keep it untouched.
+func DumpEnv(data []byte, opts UnmarshalOptions) ([]byte, error) {
+	if err := CheckText(data); err != nil {
+		return nil, err
+	}
+
+	names, err := ExtractVariables(data)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime := resolveOptions(opts, nil)
+
+	dec := goyaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var root goyaml.Node
+		err := dec.Decode(&root)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if err := expandEnvInNode(&root, runtime); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	for _, name := range names {
+		_, ok, err := runtime.lookupFolded(name)
+		if err != nil {
+			return nil, &ExpandError{Var: name, Err: err}
+		}
+		if !ok {
+			continue
+		}
+
+		value, err := expandEnvInScalar("${"+name+"}", runtime)
+		if err != nil {
+			return nil, err
+		}
+
+		out.WriteString(name)
+		out.WriteByte('=')
+		out.WriteString(dotenvValue(value))
+		out.WriteByte('\n')
+	}
+
+	return out.Bytes(), nil
+}
+
+// dotenvValue returns value as is when it is safe unquoted, or double-quoted
+// with escapes otherwise.
+func dotenvValue(value string) string {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("_./:@%+,-", c) >= 0:
+		default:
+			return `"` + dotenvEscaper.Replace(value) + `"`
+		}
+	}
+
+	return value
+}
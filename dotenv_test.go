@@ -0,0 +1,47 @@
+package jamle
+
+import "testing"
+
+func TestDumpEnv(t *testing.T) {
+	input := []byte(`
+host: ${HOST}
+port: ${PORT:=8080}
+greeting: ${GREETING}
+url: ${URL}
+missing: ${MISSING:-fallback}
+---
+path: ${WIN_PATH:-$HOME}
+empty: ${EMPTY}
+`)
+
+	env := map[string]string{
+		"HOST":     "db.local",
+		"GREETING": "hello \"world\"\nbye $USER",
+		"URL":      "${HOST}:5432/app",
+		"HOME":     "/home/app",
+		"EMPTY":    "",
+	}
+
+	got, err := DumpEnv(input, UnmarshalOptions{Env: env})
+	if err != nil {
+		t.Fatalf("DumpEnv returned error: %v", err)
+	}
+
+	want := `EMPTY=
+GREETING="hello \"world\"\nbye \$USER"
+HOME=/home/app
+HOST=db.local
+PORT=8080
+URL=db.local:5432/app
+`
+	if string(got) != want {
+		t.Fatalf("dump mismatch:\ngot\n%s\nwant\n%s", got, want)
+	}
+	if _, ok := env["PORT"]; ok {
+		t.Fatal("Env map must not be modified")
+	}
+
+	if _, err := DumpEnv([]byte("v: ${REQUIRED:?}\n"), UnmarshalOptions{Env: env}); err == nil {
+		t.Fatal("expected error for missing required variable")
+	}
+}
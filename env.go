@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"os"
+	"strings"
+)
+
+// WithTempEnv snapshots the process environment, runs fn, and restores the
+// snapshot afterwards, even if fn panics. It undoes `${VAR:=default}`
+// assignments made by Unmarshal calls inside fn, which keeps test cases
+// isolated. The process environment is global, so WithTempEnv must not be
+// used concurrently with code that reads or writes it.
+func WithTempEnv(fn func()) {
+	snapshot := os.Environ()
+	defer restoreEnv(snapshot)
+
+	fn()
+}
+
+// restoreEnv resets the process environment to snapshot entries.
+func restoreEnv(snapshot []string) {
+	want := make(map[string]string, len(snapshot))
+	for _, kv := range snapshot {
+		if name, value, ok := cutEnvEntry(kv); ok {
+			want[name] = value
+		}
+	}
+
+	for _, kv := range os.Environ() {
+		name, _, ok := cutEnvEntry(kv)
+		if !ok {
+			continue
+		}
+		if _, keep := want[name]; !keep {
+			_ = os.Unsetenv(name)
+		}
+	}
+
+	for name, value := range want {
+		if current, ok := os.LookupEnv(name); !ok || current != value {
+			_ = os.Setenv(name, value)
+		}
+	}
+}
+
+// cutEnvEntry splits a NAME=VALUE entry. Windows keeps per-drive entries
+// like "=C:=C:\dir", so the separator search starts after the first byte.
+func cutEnvEntry(kv string) (string, string, bool) {
+	if kv == "" {
+		return "", "", false
+	}
+
+	i := strings.IndexByte(kv[1:], '=')
+	if i < 0 {
+		return "", "", false
+	}
+
+	return kv[:i+1], kv[i+2:], true
+}
+
+// mergeEnviron parses NAME=VALUE entries into a map and applies env on top.
+// Later duplicates in environ win, like in the process environment; entries
+// without '=' are skipped.
+func mergeEnviron(environ []string, env map[string]string) map[string]string {
+	merged := make(map[string]string, len(environ)+len(env))
+	for _, kv := range environ {
+		if name, value, ok := cutEnvEntry(kv); ok {
+			merged[name] = value
+		}
+	}
+
+	for name, value := range env {
+		merged[name] = value
+	}
+
+	return merged
+}
@@ -0,0 +1,134 @@
+package jamle
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestWithTempEnv(t *testing.T) {
+	t.Setenv("JAMLE_TEMP_ENV_KEEP", "original")
+	t.Setenv("JAMLE_TEMP_ENV_REMOVED", "present")
+	_ = os.Unsetenv("JAMLE_TEMP_ENV_ASSIGNED")
+
+	WithTempEnv(func() {
+		var got map[string]string
+		if err := Unmarshal([]byte("v: ${JAMLE_TEMP_ENV_ASSIGNED:=assigned}\n"), &got); err != nil {
+			t.Fatalf("Unmarshal returned error: %v", err)
+		}
+		if os.Getenv("JAMLE_TEMP_ENV_ASSIGNED") != "assigned" {
+			t.Fatal("expected assignment inside WithTempEnv")
+		}
+
+		_ = os.Setenv("JAMLE_TEMP_ENV_KEEP", "changed")
+		_ = os.Unsetenv("JAMLE_TEMP_ENV_REMOVED")
+	})
+
+	if _, ok := os.LookupEnv("JAMLE_TEMP_ENV_ASSIGNED"); ok {
+		t.Fatal("assigned variable was not removed")
+	}
+	if got := os.Getenv("JAMLE_TEMP_ENV_KEEP"); got != "original" {
+		t.Fatalf("changed variable not restored: got %q", got)
+	}
+	if got := os.Getenv("JAMLE_TEMP_ENV_REMOVED"); got != "present" {
+		t.Fatalf("removed variable not restored: got %q", got)
+	}
+
+	t.Run("restores after panic", func(t *testing.T) {
+		func() {
+			defer func() { _ = recover() }()
+
+			WithTempEnv(func() {
+				_ = os.Setenv("JAMLE_TEMP_ENV_KEEP", "panicked")
+				panic("boom")
+			})
+		}()
+
+		if got := os.Getenv("JAMLE_TEMP_ENV_KEEP"); got != "original" {
+			t.Fatalf("variable not restored after panic: got %q", got)
+		}
+	})
+}
+
+func TestUnmarshalWithOptions_Environ(t *testing.T) {
+	t.Setenv("JAMLE_ENVIRON_LIVE", "from-process")
+
+	input := []byte(`
+host: ${HOST}
+port: ${PORT}
+path: ${WIN_PATH}
+live: ${JAMLE_ENVIRON_LIVE:-not-consulted}
+dup: ${DUP}
+`)
+
+	var got map[string]string
+	err := UnmarshalWithOptions(input, &got, UnmarshalOptions{
+		Environ: []string{
+			"HOST=svc.local",
+			"PORT=8080",
+			"WIN_PATH=C:\\bin;D:\\bin",
+			"=C:=C:\\dir",
+			"MALFORMED",
+			"DUP=first",
+			"DUP=second",
+		},
+		Env:      map[string]string{"PORT": "9000"},
+		Resolver: ResolveFunc(func(string) (string, bool) { return "from-resolver", true }),
+	})
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+
+	want := map[string]string{
+		"host": "svc.local",
+		"port": "9000",
+		"path": "C:\\bin;D:\\bin",
+		"live": "not-consulted",
+		"dup":  "second",
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s: got %q, want %q", key, got[key], value)
+		}
+	}
+}
+
+func TestUnmarshalWithOptions_Prefix(t *testing.T) {
+	env := map[string]string{
+		"MYAPP_HOST": "tenant.local",
+		"HOST":       "shared.local",
+		"PORT":       "8080",
+	}
+
+	input := []byte(`
+host: ${HOST}
+port: ${PORT}
+user: ${USER_NAME:-guest}
+mode: ${MODE:=prod}
+again: ${MODE}
+`)
+
+	var got map[string]string
+	if err := UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: env, Prefix: "MYAPP_"}); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+
+	want := map[string]string{
+		"host":  "tenant.local",
+		"port":  "8080",
+		"user":  "guest",
+		"mode":  "prod",
+		"again": "prod",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("value mismatch:\ngot  %#v\nwant %#v", got, want)
+	}
+
+	t.Setenv("JAMLE_PREFIX_ASSIGNED_MODE", "")
+	if _, err := ExpandStringWithOptions("${ASSIGNED_MODE:=dev}", UnmarshalOptions{Prefix: "JAMLE_PREFIX_"}); err != nil {
+		t.Fatalf("ExpandStringWithOptions returned error: %v", err)
+	}
+	if got := os.Getenv("JAMLE_PREFIX_ASSIGNED_MODE"); got != "dev" {
+		t.Fatalf("assignment should set the prefixed name, got %q", got)
+	}
+}
@@ -4,7 +4,10 @@
 
 package jamle
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrAssignmentUnsupported is returned when resolver does not support Set.
@@ -12,4 +15,104 @@ var (
 
 	// ErrOutMustBePointerToSlice reports invalid out parameter for UnmarshalAll* APIs.
 	ErrOutMustBePointerToSlice = errors.New("out must be a pointer to slice")
+
+	// ErrOrderedRootNotMapping reports a non-mapping document for UnmarshalOrdered* APIs.
+	ErrOrderedRootNotMapping = errors.New("document root must be a mapping")
+
+	// ErrUnsupportedTransform reports an unknown ${VAR@X} transform operator.
+	ErrUnsupportedTransform = errors.New("unsupported transform operator")
+
+	// ErrInvalidVariableName reports a variable name outside [A-Za-z_][A-Za-z0-9_]*.
+	ErrInvalidVariableName = errors.New("invalid variable name")
+
+	// ErrAssignmentDisabled reports ${VAR:=default} while DisallowAssign is set.
+	ErrAssignmentDisabled = errors.New("environment assignment is disabled")
+
+	// ErrUnsetVariable reports a plain ${VAR} of an unset variable while ErrorOnUnset is set.
+	ErrUnsetVariable = errors.New("variable is not set")
+
+	// ErrAmbiguousVariable reports a CaseInsensitive name matching several variables.
+	ErrAmbiguousVariable = errors.New("ambiguous case-insensitive variable name")
+
+	// ErrDefaultUsed reports a default value taken while ErrorOnDefaultUsed is set.
+	ErrDefaultUsed = errors.New("default value used")
+
+	// ErrValidation reports a resolved value rejected by UnmarshalOptions.Validators.
+	ErrValidation = errors.New("variable validation failed")
+
+	// ErrValueTransform reports an error returned by UnmarshalOptions.Transform.
+	ErrValueTransform = errors.New("value transform failed")
+
+	// ErrArithmetic reports a $((EXPR)) that is not a valid integer expression.
+	ErrArithmetic = errors.New("invalid arithmetic expression")
+
+	// ErrInvalidEscapePrefix reports an EscapePrefix that clashes with ${...}.
+	ErrInvalidEscapePrefix = errors.New("invalid escape prefix")
+
+	// ErrStrictDollar reports a $ that StrictDollar does not recognize.
+	ErrStrictDollar = errors.New("unrecognized $ sequence")
+
+	// ErrUnterminatedVariable reports a "${" without a closing brace under
+	// StrictDollar; such errors also match ErrStrictDollar.
+	ErrUnterminatedVariable = errors.New(`unterminated "${"`)
+
+	// ErrInvalidMerge reports a `<<: ${VAR}` merge whose value is not a mapping.
+	ErrInvalidMerge = errors.New("merge value is not a mapping")
+
+	// ErrDuplicateKey reports mapping keys that collide after expansion.
+	ErrDuplicateKey = errors.New("duplicate mapping key after expansion")
+
+	// ErrExpansionCycle reports variables whose values expand back into themselves.
+	ErrExpansionCycle = errors.New("variable expansion cycle")
+
+	// ErrBinaryInput reports input that contains NUL bytes or invalid UTF-8.
+	ErrBinaryInput = errors.New("input does not appear to be text")
+
+	// ErrInvalidTag reports a node tag that is not valid after expansion.
+	ErrInvalidTag = errors.New("invalid tag after expansion")
+
+	// ErrInvalidPath reports a malformed ExpandPath path.
+	ErrInvalidPath = errors.New("invalid node path")
+
+	// ErrResolverBackend reports a failed lookup in a RegisterResolver backend.
+	ErrResolverBackend = errors.New("resolver backend")
+
+	// ErrPathNotFound reports an ExpandPath path missing from every document.
+	ErrPathNotFound = errors.New("node path not found")
 )
+
+// ExpandError describes a failed ${...} expansion with variable and scalar context.
+// Use errors.As to inspect it; errors.Is still matches the wrapped sentinel.
+type ExpandError struct {
+	// Err is the underlying expansion error.
+	Err error
+
+	// Var is the variable name of the failing placeholder.
+	Var string
+
+	// Scalar is the original scalar value that contained the placeholder.
+	Scalar string
+
+	// Line and Column locate the scalar in the YAML document (1-based).
+	// They are zero when the position is unknown, e.g. for ExpandString.
+	Line   int
+	Column int
+}
+
+// Error returns the underlying error message with position and scalar context.
+func (e *ExpandError) Error() string {
+	msg := e.Err.Error()
+	if e.Scalar != "" {
+		msg = fmt.Sprintf("%s (in %q)", msg, e.Scalar)
+	}
+	if e.Line > 0 {
+		msg = fmt.Sprintf("line %d:%d: %s", e.Line, e.Column, msg)
+	}
+
+	return msg
+}
+
+// Unwrap returns the underlying expansion error.
+func (e *ExpandError) Unwrap() error {
+	return e.Err
+}
@@ -5,8 +5,13 @@
 package jamle
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	goyaml "go.yaml.in/yaml/v3"
 )
@@ -33,18 +38,163 @@ func expandEnvInNode(root *goyaml.Node, opts runtimeOptions) error {
 }
 
 // expandEnvInNodeFast applies scalar env expansion without path tracking.
-func expandEnvInNodeFast(root *goyaml.Node, opts runtimeOptions) error {
-	var resolveErr error
+func expandEnvInNodeFast(n *goyaml.Node, opts runtimeOptions) error {
+	if n == nil || isLiteralNode(n) {
+		return nil
+	}
+
+	switch n.Kind {
+	case goyaml.ScalarNode:
+		return expandScalarNodeValue(n, opts)
+
+	case goyaml.MappingNode:
+		keysChanged := false
+		for i, child := range n.Content {
+			if isLiteralNode(n.Content[i-i%2]) {
+				continue
+			}
+
+			if i%2 == 1 && isMergePlaceholder(n.Content[i-1], child) {
+				if err := expandMergeValue(child, opts); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if i%2 == 1 || child.Kind != goyaml.ScalarNode {
+				if err := expandEnvInNodeFast(child, opts); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if opts.keepKeys {
+				continue
+			}
+
+			oldKey := child.Value
+			if err := expandScalarNodeValue(child, opts); err != nil {
+				return err
+			}
+
+			keysChanged = keysChanged || child.Value != oldKey
+		}
+
+		if keysChanged {
+			return checkDuplicateKeys(n)
+		}
+
+		return nil
+
+	default:
+		for _, child := range n.Content {
+			if err := expandEnvInNodeFast(child, opts); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
+
+// literalDirective is the comment that excludes a node from expansion.
+const literalDirective = "jamle:literal"
+
+// isLiteralNode reports whether n carries a `# jamle:literal` head or line
+// comment. A directive on a mapping key covers its value too.
+func isLiteralNode(n *goyaml.Node) bool {
+	return hasLiteralDirective(n.LineComment) || hasLiteralDirective(n.HeadComment)
+}
+
+// hasLiteralDirective reports whether a comment has a line that is exactly
+// the literal directive.
+func hasLiteralDirective(comment string) bool {
+	if comment == "" {
+		return false
+	}
+
+	for line := range strings.SplitSeq(comment, "\n") {
+		text, ok := strings.CutPrefix(strings.TrimSpace(line), "#")
+		if ok && strings.TrimSpace(text) == literalDirective {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isMergePlaceholder reports whether value of a `<<` merge key is a plain
+// scalar that consists of exactly one ${...} placeholder.
+func isMergePlaceholder(key, value *goyaml.Node) bool {
+	return key.Kind == goyaml.ScalarNode && key.ShortTag() == "!!merge" &&
+		value.Kind == goyaml.ScalarNode && value.Style == 0 && isSingleVarPlaceholder(value.Value)
+}
+
+// expandMergeValue expands the placeholder value n of a `<<` merge key and
+// parses the result as YAML, which must be a mapping or a sequence of
+// mappings, so `<<: ${DEFAULTS}` merges a block held in one variable.
+func expandMergeValue(n *goyaml.Node, opts runtimeOptions) error {
+	placeholder := n.Value
+	line, column := n.Line, n.Column
 
-	walkScalarNodes(root, func(n *goyaml.Node) {
-		if resolveErr != nil {
-			return
+	opts.parseYAML = true
+	if err := expandScalarNodeValue(n, opts); err != nil {
+		return err
+	}
+
+	valid := n.Kind == goyaml.MappingNode
+	if n.Kind == goyaml.SequenceNode {
+		valid = true
+		for _, item := range n.Content {
+			valid = valid && item.Kind == goyaml.MappingNode
+		}
+	}
+	if valid {
+		return nil
+	}
+
+	err := fmt.Errorf("%w: %s expanded to a sequence of non-mappings", ErrInvalidMerge, placeholder)
+	if n.Kind == goyaml.ScalarNode {
+		err = fmt.Errorf("%w: %s expanded to %q", ErrInvalidMerge, placeholder, n.Value)
+	}
+
+	return &ExpandError{
+		Var:    placeholderVarName(placeholder[2 : len(placeholder)-1]),
+		Scalar: placeholder,
+		Line:   line,
+		Column: column,
+		Err:    err,
+	}
+}
+
+// checkDuplicateKeys reports scalar keys that collide in mapping n,
+// e.g. when expansion turns "${PREFIX}" into an existing key.
+func checkDuplicateKeys(n *goyaml.Node) error {
+	seen := make(map[string]*goyaml.Node, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		keyNode := n.Content[i]
+		if keyNode.Kind != goyaml.ScalarNode || keyNode.ShortTag() == "!!merge" {
+			continue
 		}
 
-		resolveErr = expandScalarNodeValue(n, opts)
-	})
+		if first, ok := seen[keyNode.Value]; ok {
+			return fmt.Errorf(
+				"%w %q at line %d:%d, first defined at line %d:%d",
+				ErrDuplicateKey,
+				keyNode.Value,
+				keyNode.Line,
+				keyNode.Column,
+				first.Line,
+				first.Column,
+			)
+		}
 
-	return resolveErr
+		seen[keyNode.Value] = keyNode
+	}
+
+	return nil
 }
 
 // walkScalarNodes walks YAML AST and calls fn for scalar nodes only.
@@ -55,6 +205,7 @@ func walkScalarNodes(n *goyaml.Node, fn func(*goyaml.Node)) {
 
 	if n.Kind == goyaml.ScalarNode {
 		fn(n)
+		return
 	}
 
 	for _, c := range n.Content {
@@ -64,7 +215,7 @@ func walkScalarNodes(n *goyaml.Node, fn func(*goyaml.Node)) {
 
 // expandEnvInNodeWithPath applies env expansion recursively with path tracking.
 func expandEnvInNodeWithPath(n *goyaml.Node, path []string, opts runtimeOptions) error {
-	if n == nil {
+	if n == nil || isLiteralNode(n) {
 		return nil
 	}
 
@@ -79,25 +230,46 @@ func expandEnvInNodeWithPath(n *goyaml.Node, path []string, opts runtimeOptions)
 		return nil
 
 	case goyaml.MappingNode:
+		keysChanged := false
 		for i := 0; i+1 < len(n.Content); i += 2 {
 			keyNode := n.Content[i]
 			valueNode := n.Content[i+1]
+			if isLiteralNode(keyNode) {
+				continue
+			}
 
 			nextPath := appendPathSegment(path, pathSegmentFromKeyNode(keyNode))
 
-			if keyNode.Kind == goyaml.ScalarNode {
+			if keyNode.Kind == goyaml.ScalarNode && !opts.keepKeys {
+				oldKey := keyNode.Value
 				if err := expandEnvInScalarNode(keyNode, nextPath, opts); err != nil {
 					return err
 				}
 
+				keysChanged = keysChanged || keyNode.Value != oldKey
 				nextPath[len(nextPath)-1] = keyNode.Value
 			}
 
+			if isMergePlaceholder(keyNode, valueNode) {
+				if shouldIgnorePath(nextPath, opts.ignorePathRules) {
+					continue
+				}
+				if err := expandMergeValue(valueNode, opts); err != nil {
+					return err
+				}
+
+				continue
+			}
+
 			if err := expandEnvInNodeWithPath(valueNode, nextPath, opts); err != nil {
 				return err
 			}
 		}
 
+		if keysChanged {
+			return checkDuplicateKeys(n)
+		}
+
 		return nil
 
 	case goyaml.SequenceNode:
@@ -139,45 +311,265 @@ func expandScalarNodeValue(n *goyaml.Node, opts runtimeOptions) error {
 	oldTag := n.Tag
 	oldValue := n.Value
 
-	out, err := expandEnvInScalar(n.Value, opts)
+	input := n.Value
+	placeholder, sep, split := splitDirective(n.Value, opts)
+	if split {
+		input = placeholder
+	}
+
+	out, err := expandEnvInScalar(input, opts)
 	if err != nil {
+		var expandErr *ExpandError
+		if errors.As(err, &expandErr) {
+			expandErr.Line, expandErr.Column = n.Line, n.Column
+		}
+
 		return err
 	}
 
+	if split {
+		replaceWithSplitSequence(n, out, sep, opts)
+		return nil
+	}
+
 	n.Value = out
 
+	if opts.parseYAML && oldStyle == 0 && isSingleVarPlaceholder(oldValue) && replaceWithParsedYAML(n) {
+		return nil
+	}
+
 	// If scalar was plain and implicitly !!str due to ${...},
 	// clear the tag so YAML can re-resolve native scalar types.
-	if oldStyle == 0 && oldTag == "!!str" && oldValue != n.Value {
-		n.Tag = ""
+	if !opts.keepStrings && oldStyle == 0 && oldTag == "!!str" && oldValue != n.Value {
+		retypeScalar(n, opts)
 	}
 
 	return nil
 }
 
+// retypeScalar clears the tag of scalar n so YAML re-resolves its type,
+// mapping YAML 1.1 booleans when enabled.
+func retypeScalar(n *goyaml.Node, opts runtimeOptions) {
+	n.Tag = ""
+
+	if opts.yaml11Bools {
+		if b, ok := yaml11Bool(n.Value); ok {
+			n.Tag = "!!bool"
+			n.Value = b
+		}
+	}
+}
+
+// splitDirective reports whether s is one ${...|split} or ${...|split:SEP}
+// placeholder and returns the placeholder without the directive and SEP.
+// A placeholder deferred by OnlyVars keeps its directive.
+func splitDirective(s string, opts runtimeOptions) (string, string, bool) {
+	if !isSingleVarPlaceholder(s) {
+		return "", "", false
+	}
+
+	content := s[2 : len(s)-1]
+	i := strings.LastIndex(content, "|split")
+	if i < 0 {
+		return "", "", false
+	}
+
+	sep := content[i+len("|split"):]
+	if sep != "" {
+		if sep[0] != ':' || len(sep) == 1 {
+			return "", "", false
+		}
+		sep = sep[1:]
+	}
+
+	if opts.deferVar(leadingVarName(content)) {
+		return "", "", false
+	}
+
+	return "${" + content[:i] + "}", sep, true
+}
+
+// replaceWithSplitSequence replaces scalar n with a sequence of the parts of
+// value split by sep, or by whitespace when sep is empty. Parts are trimmed,
+// empty parts are dropped, and each part is typed like a plain scalar.
+func replaceWithSplitSequence(n *goyaml.Node, value, sep string, opts runtimeOptions) {
+	var parts []string
+	if sep == "" {
+		parts = strings.Fields(value)
+	} else {
+		for part := range strings.SplitSeq(value, sep) {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+	}
+
+	items := make([]*goyaml.Node, 0, len(parts))
+	for _, part := range parts {
+		item := &goyaml.Node{Kind: goyaml.ScalarNode, Tag: "!!str", Value: part, Line: n.Line, Column: n.Column}
+		if !opts.keepStrings {
+			retypeScalar(item, opts)
+		}
+
+		items = append(items, item)
+	}
+
+	*n = goyaml.Node{
+		Kind:        goyaml.SequenceNode,
+		Tag:         "!!seq",
+		Style:       goyaml.FlowStyle,
+		Content:     items,
+		HeadComment: n.HeadComment,
+		LineComment: n.LineComment,
+		FootComment: n.FootComment,
+		Line:        n.Line,
+		Column:      n.Column,
+	}
+}
+
+// yaml11Bool maps YAML 1.1 boolean tokens to canonical "true"/"false".
+func yaml11Bool(value string) (string, bool) {
+	switch value {
+	case "y", "Y", "yes", "Yes", "YES", "on", "On", "ON":
+		return "true", true
+	case "n", "N", "no", "No", "NO", "off", "Off", "OFF":
+		return "false", true
+	}
+
+	return "", false
+}
+
+// hasExpansionSyntax reports whether data contains ${...} or $$ markers,
+// a '%' when %NAME% references are expanded too, or $(( with Arithmetic.
+func hasExpansionSyntax(data []byte, opts UnmarshalOptions) bool {
+	return bytes.Contains(data, []byte("${")) || bytes.Contains(data, []byte("$$")) ||
+		(opts.EscapePrefix != "" && (bytes.Contains(data, []byte(opts.EscapePrefix)) || checkEscapePrefix(opts.EscapePrefix) != nil)) ||
+		(opts.WindowsVars && bytes.IndexByte(data, '%') >= 0) ||
+		(opts.Arithmetic && bytes.Contains(data, []byte("$(("))) ||
+		(opts.StrictDollar && bytes.IndexByte(data, '$') >= 0)
+}
+
+// isSingleVarPlaceholder reports whether s is exactly one ${...} placeholder.
+func isSingleVarPlaceholder(s string) bool {
+	if !strings.HasPrefix(s, "${") {
+		return false
+	}
+
+	end, ok := findClosingBraceIndex(s, 1)
+	return ok && end == len(s)-1
+}
+
+// replaceWithParsedYAML replaces scalar n with its value parsed as YAML when
+// the value is a mapping or sequence. It reports whether n was replaced.
+func replaceWithParsedYAML(n *goyaml.Node) bool {
+	var doc goyaml.Node
+	if err := goyaml.Unmarshal([]byte(n.Value), &doc); err != nil || len(doc.Content) == 0 {
+		return false
+	}
+
+	parsed := doc.Content[0]
+	if parsed.Kind != goyaml.MappingNode && parsed.Kind != goyaml.SequenceNode {
+		return false
+	}
+
+	parsed.Line, parsed.Column = n.Line, n.Column
+	parsed.HeadComment, parsed.LineComment, parsed.FootComment = n.HeadComment, n.LineComment, n.FootComment
+	*n = *parsed
+	return true
+}
+
+// scalarState holds expansion state shared by all passes over one scalar.
+type scalarState struct {
+	envCache map[string]envLookup
+	setter   Setter
+	// active lists variables whose looked-up values are being expanded,
+	// so a value that refers back to one of them is reported as a cycle.
+	active map[string]struct{}
+	opts   runtimeOptions
+	// trace collects substitutions of the current pass for opts.trace,
+	// scalar is the original input reported with them, and usedDefault
+	// and usedBackend mark that the last resolved placeholder took a
+	// default or was read from a resolver backend.
+	trace       []TraceSubstitution
+	scalar      string
+	usedDefault bool
+	usedBackend bool
+}
+
 // expandEnvInScalar expands ${...} variables using resolved options.
 func expandEnvInScalar(in string, opts runtimeOptions) (string, error) {
-	if !strings.Contains(in, "${") {
+	if opts.escapeErr != nil {
+		return "", opts.escapeErr
+	}
+
+	if !strings.Contains(in, "${") && !strings.Contains(in, opts.escapePrefix) &&
+		(!opts.windowsVars || !strings.Contains(in, "%")) &&
+		(!opts.arithmetic || !strings.Contains(in, "$((")) &&
+		(!opts.strictDollar || !strings.Contains(in, "$")) {
 		return in, nil
 	}
 
-	str := maskEscapedVars(in)
-	envCache := make(map[string]envLookup)
-	setter, _ := opts.resolver.(Setter)
-	if !opts.allowAssignment {
-		setter = nil
+	if strings.ContainsAny(in, maskStart+maskEnd+maskDollar) {
+		return "", &ExpandError{Scalar: in, Err: fmt.Errorf("%w: control character in scalar", ErrBinaryInput)}
+	}
+
+	if opts.strictDollar {
+		if err := checkStrictDollar(in, &opts); err != nil {
+			return "", &ExpandError{Scalar: in, Err: err}
+		}
+	}
+
+	if opts.stats != nil {
+		opts.stats.Scalars++
+	}
+
+	st := &scalarState{
+		envCache: make(map[string]envLookup),
+		opts:     opts,
+		scalar:   in,
+	}
+	if opts.allowAssignment {
+		st.setter, _ = opts.resolver.(Setter)
+	}
+
+	str := maskEscapedVars(in, opts.escapePrefix)
+	var err error
+	if opts.windowsVars {
+		str, err = st.expandWindowsVars(str)
+	}
+	if err == nil {
+		str, err = st.expandPasses(str)
+	}
+	if err == nil && opts.arithmetic {
+		str, err = expandArithmetic(str)
+	}
+	if err != nil {
+		var expandErr *ExpandError
+		if errors.As(err, &expandErr) {
+			expandErr.Scalar = in
+		}
+
+		return "", err
 	}
 
-	// Main expansion loop.
-	for range opts.maxPasses {
-		replacement, changed, err := replaceInnermostVars(
-			str,
-			envCache,
-			opts.resolver,
-			setter,
-			opts.allowAssignment,
-			opts.enforceRequired,
-		)
+	if strings.IndexAny(str, maskStart+maskDollar) < 0 {
+		return str, nil
+	}
+
+	// Unmask \x00VAR\x01 -> ${VAR} and \x02 -> $
+	return unmaskReplacer.Replace(str), nil
+}
+
+// expandPasses runs the pass loop over a masked string until nothing changes
+// or opts.maxPasses is reached. The result is still masked.
+func (st *scalarState) expandPasses(str string) (string, error) {
+	for pass := range st.opts.maxPasses {
+		if st.opts.onlyVars != nil || st.opts.setOnly {
+			str = maskDeferredVars(str, &st.opts)
+		}
+
+		st.trace = nil
+		replacement, changed, err := replaceInnermostVars(str, st)
 		if err != nil {
 			return "", err
 		}
@@ -186,21 +578,83 @@ func expandEnvInScalar(in string, opts runtimeOptions) (string, error) {
 			break
 		}
 
+		if st.opts.stats != nil {
+			st.opts.stats.MaxPasses = max(st.opts.stats.MaxPasses, pass+1)
+		}
+
+		if st.opts.trace != nil {
+			st.opts.trace(TraceEvent{
+				Scalar:        st.scalar,
+				Before:        unmaskReplacer.Replace(str),
+				After:         unmaskReplacer.Replace(replacement),
+				Substitutions: st.trace,
+				Pass:          pass + 1,
+			})
+		}
+
 		str = replacement
 	}
 
-	if strings.IndexByte(str, maskStart[0]) < 0 {
-		return str, nil
+	return str, nil
+}
+
+// expandWindowsVars replaces %NAME% references of set variables before
+// ${...} expansion. Values are resolved like ${NAME} and not rescanned;
+// unset references and variables deferred by OnlyVars stay as written.
+func (st *scalarState) expandWindowsVars(in string) (string, error) {
+	if strings.Count(in, "%") < 2 {
+		return in, nil
 	}
 
-	// Unmask \x00VAR\x01 -> ${VAR}
-	return unmaskReplacer.Replace(str), nil
+	var out strings.Builder
+	out.Grow(len(in))
+
+	for i := 0; i < len(in); i++ {
+		if in[i] != '%' {
+			out.WriteByte(in[i])
+			continue
+		}
+
+		end := strings.IndexByte(in[i+1:], '%')
+		if end < 0 {
+			out.WriteString(in[i:])
+			break
+		}
+
+		name := in[i+1 : i+1+end]
+		if !isValidVarName(name) {
+			out.WriteByte('%')
+			continue
+		}
+
+		ref := in[i : i+end+2]
+		i += end + 1
+		if st.opts.deferVar(name) {
+			out.WriteString(ref)
+			continue
+		}
+
+		value, exists, err := st.lookup(name)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			out.WriteString(ref)
+			continue
+		}
+
+		out.WriteString(strings.ReplaceAll(value, "${", maskStart))
+	}
+
+	return out.String(), nil
 }
 
-// maskEscapedVars replaces $${...} segments (with balanced braces) by masked
-// markers, so later expansion ignores them and unmasking restores literal ${...}.
-func maskEscapedVars(in string) string {
-	if !strings.Contains(in, "$${") {
+// maskEscapedVars replaces prefix{...} segments (with balanced braces, e.g.
+// $${...} for the default prefix) by masked markers, so later expansion
+// ignores them and unmasking restores literal ${...}. A standalone prefix
+// not followed by '{' is masked as a single literal $.
+func maskEscapedVars(in, prefix string) string {
+	if !strings.Contains(in, prefix) {
 		return in
 	}
 
@@ -208,13 +662,20 @@ func maskEscapedVars(in string) string {
 	out.Grow(len(in))
 
 	for i := 0; i < len(in); {
-		if i+2 >= len(in) || in[i] != '$' || in[i+1] != '$' || in[i+2] != '{' {
+		if !strings.HasPrefix(in[i:], prefix) {
 			out.WriteByte(in[i])
 			i++
 			continue
 		}
 
-		j, ok := findClosingBraceIndex(in, i+2)
+		open := i + len(prefix)
+		if open >= len(in) || in[open] != '{' {
+			out.WriteString(maskDollar)
+			i = open
+			continue
+		}
+
+		j, ok := findClosingBraceIndex(in, open)
 		if !ok {
 			out.WriteByte(in[i])
 			i++
@@ -222,7 +683,7 @@ func maskEscapedVars(in string) string {
 		}
 
 		out.WriteString(maskStart)
-		escapedContent := strings.ReplaceAll(in[i+3:j], "${", maskStart)
+		escapedContent := strings.ReplaceAll(in[open+1:j], "${", maskStart)
 		out.WriteString(escapedContent)
 		out.WriteString(maskEnd)
 		i = j + 1
@@ -231,6 +692,88 @@ func maskEscapedVars(in string) string {
 	return out.String()
 }
 
+// maskDeferredVars masks ${NAME...} expressions whose variable is deferred
+// by OnlyVars or SetOnly, so they are emitted verbatim after unmasking.
+func maskDeferredVars(in string, opts *runtimeOptions) string {
+	if !strings.Contains(in, "${") {
+		return in
+	}
+
+	var out strings.Builder
+	out.Grow(len(in))
+
+	for i := 0; i < len(in); {
+		if i+1 >= len(in) || in[i] != '$' || in[i+1] != '{' {
+			out.WriteByte(in[i])
+			i++
+			continue
+		}
+
+		name := leadingVarName(in[i+2:])
+		// With SetOnly alone, composed names like ${PREFIX_${REGION}} are
+		// checked in a later pass, once the inner placeholder is resolved.
+		composed := opts.onlyVars == nil && strings.HasPrefix(in[i+2+len(name):], "${")
+		if name == "" || composed || !opts.deferVar(name) {
+			out.WriteString("${")
+			i += 2
+			continue
+		}
+
+		j, closed := findClosingBraceIndex(in, i+1)
+		if !closed {
+			out.WriteByte(in[i])
+			i++
+			continue
+		}
+
+		out.WriteString(maskStart)
+		out.WriteString(strings.ReplaceAll(in[i+2:j], "${", maskStart))
+		out.WriteString(maskEnd)
+		i = j + 1
+	}
+
+	return out.String()
+}
+
+// deferVar reports whether placeholders of name are kept verbatim, because
+// name is not in OnlyVars or, with SetOnly, is unset.
+func (opts *runtimeOptions) deferVar(name string) bool {
+	if opts.onlyVars != nil {
+		if _, ok := opts.onlyVars[name]; !ok {
+			return true
+		}
+	}
+
+	if opts.setOnly {
+		// An ambiguous name is not deferred, so its lookup reports the error.
+		_, ok, err := opts.lookupFolded(name)
+		return !ok && err == nil
+	}
+
+	return false
+}
+
+// isValidVarName reports whether name matches [A-Za-z_][A-Za-z0-9_]*.
+func isValidVarName(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+
+	return leadingVarName(name) == name
+}
+
+// leadingVarName returns the variable name prefix ([A-Za-z0-9_]*) of s.
+func leadingVarName(s string) string {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '_' && (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return s[:i]
+		}
+	}
+
+	return s
+}
+
 // findClosingBraceIndex finds matching '}' for an opening '{' at openPos.
 func findClosingBraceIndex(in string, openPos int) (int, bool) {
 	if openPos < 0 || openPos >= len(in) || in[openPos] != '{' {
@@ -255,17 +798,10 @@ func findClosingBraceIndex(in string, openPos int) (int, bool) {
 }
 
 // replaceInnermostVars resolves non-escaped innermost ${...} expressions.
-func replaceInnermostVars(
-	in string,
-	envCache map[string]envLookup,
-	resolver Resolver,
-	setter Setter,
-	allowAssignment bool,
-	enforceRequired bool,
-) (string, bool, error) {
-	ranges := findInnermostVarRanges(in)
-	if len(ranges) == 0 {
-		return in, false, nil
+func replaceInnermostVars(in string, st *scalarState) (string, bool, error) {
+	ranges, err := st.findResolvableRanges(in)
+	if err != nil || len(ranges) == 0 {
+		return in, false, err
 	}
 
 	var out strings.Builder
@@ -279,18 +815,46 @@ func replaceInnermostVars(
 		}
 
 		content := in[r.start+2 : r.end]
-		resolved, err := resolveVariable(
-			content,
-			envCache,
-			resolver,
-			setter,
-			allowAssignment,
-			enforceRequired,
-		)
+		st.usedDefault, st.usedBackend = false, false
+		resolved, err := resolveVariable(content, st)
+		if err != nil {
+			var expandErr *ExpandError
+			if errors.As(err, &expandErr) {
+				return "", false, err
+			}
+
+			return "", false, &ExpandError{Var: placeholderVarName(content), Err: err}
+		}
+
+		resolved, err = st.transform(content, resolved)
 		if err != nil {
 			return "", false, err
 		}
 
+		if err := st.validate(content, resolved); err != nil {
+			return "", false, err
+		}
+
+		if inVarName(in, r.start, ranges) && leadingVarName(resolved) != resolved {
+			return "", false, &ExpandError{
+				Var: placeholderVarName(content),
+				Err: fmt.Errorf("%w: value %q of ${%s} composes a variable name", ErrInvalidVariableName, resolved, content),
+			}
+		}
+
+		if st.opts.trace != nil {
+			st.trace = append(st.trace, TraceSubstitution{
+				Var:      placeholderVarName(content),
+				Operator: placeholderOperator(content),
+				Value:    unmaskReplacer.Replace(resolved),
+				Default:  st.usedDefault,
+			})
+		}
+		st.logResolved(content, resolved)
+		if st.opts.stats != nil {
+			st.opts.stats.Variables++
+		}
+
 		out.WriteString(in[cursor:r.start])
 		out.WriteString(resolved)
 		cursor = r.end + 1
@@ -305,20 +869,70 @@ func replaceInnermostVars(
 	return out.String(), true, nil
 }
 
-// findInnermostVarRanges finds innermost ${...} sections in the input string.
-func findInnermostVarRanges(in string) []scalarRange {
-	stack := make([]int, 0, 8)
-	ranges := make([]scalarRange, 0, 8)
-	hasChild := make([]bool, 0, 8)
+// inVarName reports whether the placeholder starting at pos is part of the
+// name of an enclosing placeholder, as ${REGION} in ${PREFIX_${REGION}}.
+// Name characters and sibling placeholders from ranges may precede it.
+func inVarName(in string, pos int, ranges []scalarRange) bool {
+	i := pos - 1
+	for i >= 0 {
+		c := in[i]
+		if c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			i--
+			continue
+		}
 
-	for i := 0; i < len(in); i++ {
-		if i+1 < len(in) && in[i] == '$' && in[i+1] == '{' {
-			if len(hasChild) > 0 {
-				hasChild[len(hasChild)-1] = true
+		if c != '}' {
+			break
+		}
+
+		sibling := -1
+		for _, r := range ranges {
+			if r.end == i {
+				sibling = r.start
+				break
 			}
+		}
+		if sibling < 0 {
+			return false
+		}
+		i = sibling - 1
+	}
+
+	return i >= 1 && in[i] == '{' && in[i-1] == '$'
+}
+
+// placeholderVarName returns the variable name part of ${...} content.
+func placeholderVarName(content string) string {
+	if i := strings.IndexAny(content, ":@-=?+"); i >= 0 {
+		return content[:i]
+	}
+
+	return content
+}
+
+// varTreeNode is one closed ${...} section with its nested sections.
+type varTreeNode struct {
+	children []int
+	scalarRange
+}
 
-			stack = append(stack, i)
-			hasChild = append(hasChild, false)
+// findResolvableRanges finds the ${...} sections to resolve in this pass:
+// innermost sections, and outer sections whose operand (default,
+// alternate value or message) is not used, so placeholders nested in an
+// unused branch such as ${A:-${B:?required}} with A set never run.
+func (st *scalarState) findResolvableRanges(in string) ([]scalarRange, error) {
+	type openVar struct {
+		children []int
+		start    int
+	}
+
+	nodes := make([]varTreeNode, 0, 8)
+	stack := make([]openVar, 0, 8)
+	var roots []int
+
+	for i := 0; i < len(in); i++ {
+		if i+1 < len(in) && in[i] == '$' && in[i+1] == '{' {
+			stack = append(stack, openVar{start: i})
 			i++
 			continue
 		}
@@ -327,136 +941,387 @@ func findInnermostVarRanges(in string) []scalarRange {
 			continue
 		}
 
-		start := stack[len(stack)-1]
-		child := hasChild[len(hasChild)-1]
+		open := stack[len(stack)-1]
 		stack = stack[:len(stack)-1]
-		hasChild = hasChild[:len(hasChild)-1]
+		nodes = append(nodes, varTreeNode{children: open.children, scalarRange: scalarRange{start: open.start, end: i}})
 
-		if child {
-			continue
+		if len(stack) > 0 {
+			stack[len(stack)-1].children = append(stack[len(stack)-1].children, len(nodes)-1)
+		} else {
+			roots = append(roots, len(nodes)-1)
 		}
+	}
 
-		ranges = append(ranges, scalarRange{start: start, end: i})
+	// Sections nested in an unclosed ${ are still resolved.
+	for _, open := range stack {
+		roots = append(roots, open.children...)
 	}
 
-	return ranges
-}
+	ranges := make([]scalarRange, 0, len(nodes))
+	var visit func(idx int) error
+	visit = func(idx int) error {
+		node := nodes[idx]
+		if len(node.children) > 0 {
+			unused, err := st.operandUnused(in[node.start+2 : node.end])
+			if err != nil {
+				return err
+			}
 
-// resolveVariable parses the content inside ${...} and applies Bash-style logic.
-// It handles default values, assignments, and error enforcement.
-func resolveVariable(
-	content string,
-	envCache map[string]envLookup,
-	resolver Resolver,
-	setter Setter,
-	allowAssignment bool,
-	enforceRequired bool,
-) (string, error) {
-	name, val, hasColon := strings.Cut(content, ":")
-	envVal, exists := lookupEnvWithCache(name, envCache, resolver)
-
-	// Case 1: Simple variable ${VAR}
-	if !hasColon {
-		if exists {
-			return envVal, nil
+			if !unused {
+				for _, child := range node.children {
+					if err := visit(child); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			}
 		}
 
-		return "", nil
+		ranges = append(ranges, node.scalarRange)
+		return nil
 	}
 
-	// Case 2: Variable with empty default ${VAR:}
-	if val == "" {
-		if exists {
-			return envVal, nil
+	for _, root := range roots {
+		if err := visit(root); err != nil {
+			return nil, err
 		}
+	}
 
-		return "", nil
+	slices.SortFunc(ranges, func(a, b scalarRange) int { return a.start - b.start })
+	return ranges, nil
+}
+
+// operandUnused reports whether the operand of ${...} content can be
+// skipped because the variable alone decides the result. It is false
+// when the name is itself still composed of placeholders.
+func (st *scalarState) operandUnused(content string) (bool, error) {
+	name := leadingVarName(content)
+	rest := content[len(name):]
+	if !isValidVarName(name) || rest == "" || rest[0] == '@' || strings.HasPrefix(rest, "${") {
+		return false, nil
+	}
+
+	colon := rest[0] == ':'
+	if colon {
+		rest = rest[1:]
+		if _, ok := backendFor(name, rest); ok {
+			return false, nil
+		}
 	}
 
-	// Case 3: Variable with operator and value
-	var operator byte
-	var defaultVal string
+	if rest == "" || !isExpansionOperator(rest[0]) {
+		// ${VAR:x} ignores x; a bad character without a colon errors later.
+		return colon, nil
+	}
 
-	// Check the first character after the colon
-	switch val[0] {
-	case '-', '=', '?':
-		operator = val[0]
-		defaultVal = val[1:]
+	envVal, exists, err := st.lookup(name)
+	if err != nil {
+		return false, err
+	}
 
+	isSet := exists && (!colon || envVal != "" || st.opts.emptyIsSet)
+	switch rest[0] {
+	case '+':
+		return !isSet, nil
+	case '?':
+		return isSet || !st.opts.enforceRequired, nil
 	default:
+		return isSet, nil
+	}
+}
+
+// resolveVariable parses the content inside ${...} and applies Bash-style logic.
+// It handles default values, assignments, alternate values and error
+// enforcement. Colon forms (${VAR:-x}) treat an empty value as unset,
+// no-colon forms (${VAR-x}) trigger only when VAR is unset.
+// ${name:key} with a name registered by RegisterResolver is resolved by
+// that backend.
+func resolveVariable(content string, st *scalarState) (string, error) {
+	name := leadingVarName(content)
+	rest := content[len(name):]
+	if strings.HasPrefix(rest, "@") {
+		return resolveTransform(content, st)
+	}
+
+	colon := strings.HasPrefix(rest, ":")
+	if colon {
+		rest = rest[1:]
+		if fn, ok := backendFor(name, rest); ok {
+			return st.resolveBackend(name, rest, fn)
+		}
+	}
+
+	if !isValidVarName(name) || (!colon && rest != "" && !isExpansionOperator(rest[0])) {
+		return "", fmt.Errorf("%w %q in ${%s}", ErrInvalidVariableName, placeholderVarName(content), content)
+	}
+
+	envVal, exists, err := st.lookup(name)
+	if err != nil {
+		return "", err
+	}
+
+	// Case 1: Simple variable ${VAR} or ${VAR:} with empty default
+	if rest == "" || !isExpansionOperator(rest[0]) {
 		// Bash-style note: ${VAR:default} is not a default-value operator.
 		// It should not behave like ${VAR:-default}. Treat it as plain ${VAR}
 		// in this simplified expansion model (no default substitution).
-		if exists {
-			return envVal, nil
+		if !exists {
+			if st.opts.errorOnUnset {
+				return "", fmt.Errorf("%w: %q%s", ErrUnsetVariable, name, st.opts.suggestionSuffix(name))
+			}
+
+			st.warnf(name, "is not set, expanded to empty string")
+			return "", nil
 		}
 
-		return "", nil
+		if envVal == "" {
+			st.warnf(name, "is set but empty")
+		}
+
+		return envVal, nil
 	}
 
+	// Case 2: Variable with operator and value
+	operator, defaultVal := rest[0], rest[1:]
+
+	// Colon forms treat empty as unset unless EmptyIsSet is on.
+	isSet := exists && (!colon || envVal != "" || st.opts.emptyIsSet)
+
 	switch operator {
+	case '+': // ${VAR:+alt} -> alt if set, otherwise empty
+		if isSet {
+			return defaultVal, nil
+		}
+
+		return "", nil
+
 	case '-': // ${VAR:-default} -> use default if unset or empty
-		if exists && envVal != "" {
+		if isSet {
 			return envVal, nil
 		}
-		return defaultVal, nil
+
+		if st.opts.errorOnDefault {
+			return "", fmt.Errorf("%w for %q", ErrDefaultUsed, name)
+		}
+		value, err := st.expandBareVars(st.defaultText(defaultVal))
+		if err == nil {
+			st.usedDefault = true
+			st.warnf(name, "default value used")
+		}
+
+		return value, err
 
 	case '=': // ${VAR:=default} -> use default if unset and set env
-		if exists && envVal != "" {
+		if st.opts.rejectAssign {
+			return "", fmt.Errorf("%w for %q", ErrAssignmentDisabled, name)
+		}
+
+		if isSet {
 			return envVal, nil
 		}
 
-		if !allowAssignment {
+		if st.opts.errorOnDefault {
+			return "", fmt.Errorf("%w for %q", ErrDefaultUsed, name)
+		}
+
+		defaultVal, err = st.expandBareVars(st.defaultText(defaultVal))
+		if err != nil {
+			return "", err
+		}
+
+		st.usedDefault = true
+		st.warnf(name, "default value used")
+
+		if !st.opts.allowAssignment {
 			return defaultVal, nil
 		}
 
-		if setter == nil {
+		if st.setter == nil {
 			return "", fmt.Errorf("%w for %q", ErrAssignmentUnsupported, name)
 		}
 
-		if err := setter.Set(name, defaultVal); err != nil {
+		if err := st.setter.Set(name, defaultVal); err != nil {
 			return "", fmt.Errorf("failed to set variable %s: %w", name, err)
 		}
 
-		envCache[name] = envLookup{value: defaultVal, exists: true}
+		st.envCache[name] = envLookup{value: defaultVal, exists: true}
+		if st.opts.foldedNames != nil {
+			addFoldedName(st.opts.foldedNames, name)
+		}
+		st.warnf(name, "default value assigned to environment")
+		st.logAssigned(name, defaultVal)
 
 		return defaultVal, nil
 
-	case '?': // ${VAR:?message} -> error
-		if !enforceRequired {
-			if exists {
-				return envVal, nil
-			}
+	default: // ${VAR:?message} -> error; ${VAR:?int:message} also checks the type
+		if !st.opts.enforceRequired {
+			return envVal, nil
+		}
 
+		hint, msg := requiredTypeHint(defaultVal)
+		if isSet {
+			return envVal, checkTypeHint(name, hint, msg, unmaskReplacer.Replace(envVal))
+		}
+
+		switch {
+		case msg != "":
+		case st.opts.unsetFormat != "":
+			return "", errors.New(strings.ReplaceAll(st.opts.unsetFormat, "%s", name) + st.opts.suggestionSuffix(name))
+		case colon:
+			msg = "is not set or empty"
+		default:
+			msg = "is not set"
+		}
+
+		return "", fmt.Errorf("environment variable %q %s%s", name, msg, st.opts.suggestionSuffix(name))
+	}
+}
+
+// isExpansionOperator reports whether c is a -, =, ? or + operator.
+func isExpansionOperator(c byte) bool {
+	return c == '-' || c == '=' || c == '?' || c == '+'
+}
+
+// resolveTransform applies Bash-style ${VAR@X} transform operators.
+func resolveTransform(content string, st *scalarState) (string, error) {
+	name, op, _ := strings.Cut(content, "@")
+	if !isValidVarName(name) {
+		return "", fmt.Errorf("%w %q in ${%s}", ErrInvalidVariableName, name, content)
+	}
+
+	envVal, exists, err := st.lookup(name)
+	if err != nil {
+		return "", err
+	}
+
+	switch op {
+	case "Q": // ${VAR@Q} -> value quoted for safe reuse as shell input
+		if !exists {
 			return "", nil
 		}
+		return shellQuote(envVal), nil
 
-		if exists && envVal != "" {
-			return envVal, nil
+	case "U": // ${VAR@U} -> value converted to upper case
+		return strings.ToUpper(envVal), nil
+
+	case "u": // ${VAR@u} -> value with first character converted to upper case
+		if envVal == "" {
+			return "", nil
 		}
+		r, size := utf8.DecodeRuneInString(envVal)
+		return string(unicode.ToUpper(r)) + envVal[size:], nil
 
-		msg := defaultVal
-		if msg == "" {
-			msg = "is not set or empty"
+	case "L": // ${VAR@L} -> value converted to lower case
+		return strings.ToLower(envVal), nil
+	}
+
+	return "", fmt.Errorf("%w @%s for %q", ErrUnsupportedTransform, op, name)
+}
+
+// escapeReplacer interprets backslash escapes for InterpretEscapes.
+var escapeReplacer = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t")
+
+// defaultText returns a default value with backslash escapes interpreted
+// when InterpretEscapes is set.
+func (st *scalarState) defaultText(value string) string {
+	if !st.opts.escapes || strings.IndexByte(value, '\\') < 0 {
+		return value
+	}
+
+	return escapeReplacer.Replace(value)
+}
+
+// expandBareVars replaces bare $NAME references in a default value with
+// their values, so ${A:-$B} works like ${A:-${B}}. A $ that does not start
+// a valid name is kept, and $$NAME was already masked as a literal $NAME.
+func (st *scalarState) expandBareVars(s string) (string, error) {
+	if strings.IndexByte(s, '$') < 0 {
+		return s, nil
+	}
+
+	var out strings.Builder
+	out.Grow(len(s))
+
+	for i := 0; i < len(s); i++ {
+		name := bareVarName(s, i)
+		if name == "" {
+			out.WriteByte(s[i])
+			continue
 		}
 
-		return "", fmt.Errorf("environment variable %q %s", name, msg)
+		value, _, err := st.lookup(name)
+		if err != nil {
+			return "", err
+		}
+
+		out.WriteString(value)
+		i += len(name)
 	}
 
-	return "", nil
+	return out.String(), nil
 }
 
-// lookupEnvWithCache reads env variable once per scalar expansion.
-func lookupEnvWithCache(
-	name string,
-	cache map[string]envLookup,
-	resolver Resolver,
-) (string, bool) {
-	if got, ok := cache[name]; ok {
-		return got.value, got.exists
+// bareVarName returns the name of a bare $NAME reference at s[i], if any.
+func bareVarName(s string, i int) string {
+	if s[i] != '$' {
+		return ""
+	}
+
+	if name := leadingVarName(s[i+1:]); isValidVarName(name) {
+		return name
+	}
+
+	return ""
+}
+
+// shellQuote wraps value in single quotes, escaping embedded single quotes.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// lookup reads env variable once per scalar expansion. Values that contain
+// ${...} are expanded before use; a value that refers back to a variable
+// being expanded returns ErrExpansionCycle.
+func (st *scalarState) lookup(name string) (string, bool, error) {
+	if got, ok := st.envCache[name]; ok {
+		return got.value, got.exists, nil
+	}
+
+	if _, ok := st.active[name]; ok {
+		return "", false, &ExpandError{Var: name, Err: fmt.Errorf("%w for %q", ErrExpansionCycle, name)}
+	}
+
+	value, exists, err := st.opts.lookupFolded(name)
+	if err != nil {
+		return "", false, &ExpandError{Var: name, Err: err}
+	}
+
+	if strings.ContainsAny(value, maskStart+maskEnd+maskDollar) {
+		return "", false, &ExpandError{Var: name, Err: fmt.Errorf("%w: control character in value of %q", ErrBinaryInput, name)}
+	}
+
+	if exists && strings.Contains(value, "${") && st.opts.literalValues {
+		value = strings.ReplaceAll(value, "${", maskStart)
+	} else if exists && strings.Contains(value, "${") {
+		if st.active == nil {
+			st.active = make(map[string]struct{})
+		}
+
+		// Nested passes report their own trace events; the outer pass
+		// keeps collecting its substitutions after them.
+		outerTrace := st.trace
+		st.active[name] = struct{}{}
+		expanded, err := st.expandPasses(value)
+		delete(st.active, name)
+		st.trace = outerTrace
+		if err != nil {
+			return "", false, err
+		}
+
+		value = expanded
 	}
 
-	value, exists := resolver.Lookup(name)
-	cache[name] = envLookup{value: value, exists: exists}
-	return value, exists
+	st.envCache[name] = envLookup{value: value, exists: exists}
+	return value, exists, nil
 }
@@ -0,0 +1,262 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	goyaml "go.yaml.in/yaml/v3"
+)
+
+// nodePathStep is one mapping key or sequence index of an ExpandPath path.
+type nodePathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// ExpandPath expands ${...} placeholders only in the subtree of data at path
+// and returns the re-encoded YAML. Scalars outside the subtree are kept as
+// written, including their placeholders.
+func ExpandPath(data []byte, path string) ([]byte, error) {
+	return ExpandPathWithOptions(data, path, UnmarshalOptions{})
+}
+
+// ExpandPathWithOptions expands the subtree at path using configured options.
+// Path is dot-separated mapping keys with [N] sequence indices, e.g.
+// "spec.containers[0].env"; an empty path selects the whole document.
+// Every document of a stream is processed, and ErrPathNotFound is returned
+// when no document contains path.
+func ExpandPathWithOptions(data []byte, path string, opts UnmarshalOptions) ([]byte, error) {
+	if err := CheckText(data); err != nil {
+		return nil, err
+	}
+
+	steps, err := parseNodePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime := resolveOptions(opts, nil)
+	out, found, err := expandNodePath(data, steps, runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w: %q", ErrPathNotFound, path)
+	}
+
+	return applyTrailingNewline(out, runtime.trailingNewline), nil
+}
+
+// applyTrailingNewline adds or strips the final newline of non-empty out
+// as requested by want; nil leaves out unchanged.
+func applyTrailingNewline(out []byte, want *bool) []byte {
+	if want == nil || len(out) == 0 {
+		return out
+	}
+
+	hasNewline := out[len(out)-1] == '\n'
+	switch {
+	case *want && !hasNewline:
+		return append(out, '\n')
+	case !*want && hasNewline:
+		return out[:len(out)-1]
+	}
+
+	return out
+}
+
+// expandNodePath expands the subtree at steps in every document of data and
+// re-encodes the stream. It reports whether any document contains steps.
+func expandNodePath(data []byte, steps []nodePathStep, runtime runtimeOptions) ([]byte, bool, error) {
+	var header []byte
+	if runtime.keepHeader {
+		header, data = splitDocumentHeader(data)
+	}
+
+	var out bytes.Buffer
+	out.Write(header)
+	enc := goyaml.NewEncoder(&out)
+	enc.SetIndent(2)
+
+	found := false
+	docs := 0
+	dec := goyaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var root goyaml.Node
+		err := dec.Decode(&root)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		if target := findNodePath(&root, steps); target != nil {
+			found = true
+			if err := expandEnvInSubtree(target, steps, runtime); err != nil {
+				return nil, false, err
+			}
+		}
+
+		if err := enc.Encode(&root); err != nil {
+			return nil, false, err
+		}
+		docs++
+	}
+
+	// The encoder cannot close an empty stream.
+	if docs == 0 {
+		return nil, false, nil
+	}
+
+	if err := enc.Close(); err != nil {
+		return nil, false, err
+	}
+
+	return out.Bytes(), found, nil
+}
+
+// expandEnvInSubtree expands target found at steps. Ignore rules are matched
+// against the full document path, as they are for whole-document expansion.
+func expandEnvInSubtree(target *goyaml.Node, steps []nodePathStep, runtime runtimeOptions) error {
+	if len(runtime.ignorePathRules) == 0 {
+		return expandEnvInNode(target, runtime)
+	}
+
+	path := make([]string, 0, len(steps))
+	for _, step := range steps {
+		if step.isIndex {
+			path = append(path, "*")
+			continue
+		}
+
+		path = append(path, step.key)
+	}
+
+	return expandEnvInNodeWithPath(target, path, runtime)
+}
+
+// splitDocumentHeader returns the leading %-directives and `---` marker of
+// data as a header to re-emit before the encoded stream, which the encoder
+// would drop. %YAML lines are blanked in the returned data, as the parser
+// rejects version 1.2; other directives stay for parsing.
+func splitDocumentHeader(data []byte) ([]byte, []byte) {
+	var header []byte
+	body := bytes.Clone(data)
+	offset := 0
+	for offset < len(body) {
+		line, _, _ := bytes.Cut(body[offset:], []byte("\n"))
+		trimmed := bytes.TrimRight(line, " \t\r")
+
+		switch {
+		case len(trimmed) == 0:
+
+		case bytes.HasPrefix(trimmed, []byte("%")):
+			header = append(append(header, trimmed...), '\n')
+			if bytes.HasPrefix(trimmed, []byte("%YAML")) {
+				for i := range line {
+					line[i] = ' '
+				}
+			}
+
+		case bytes.Equal(trimmed, []byte("---")) ||
+			bytes.HasPrefix(trimmed, []byte("--- ")) || bytes.HasPrefix(trimmed, []byte("---\t")):
+			return append(header, "---\n"...), body
+
+		default:
+			return nil, data
+		}
+
+		offset += len(line) + 1
+	}
+
+	return nil, data
+}
+
+// parseNodePath splits a dotted path with [N] indices into steps.
+func parseNodePath(path string) ([]nodePathStep, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	var steps []nodePathStep
+	for segment := range strings.SplitSeq(path, ".") {
+		key, rest, _ := strings.Cut(segment, "[")
+		if key == "" && rest == "" {
+			return nil, fmt.Errorf("%w: empty segment in %q", ErrInvalidPath, path)
+		}
+		if key != "" {
+			steps = append(steps, nodePathStep{key: key})
+		}
+
+		for rest != "" {
+			digits, tail, ok := strings.Cut(rest, "]")
+			index, err := strconv.Atoi(digits)
+			if !ok || err != nil || index < 0 {
+				return nil, fmt.Errorf("%w: bad index in %q", ErrInvalidPath, path)
+			}
+
+			steps = append(steps, nodePathStep{index: index, isIndex: true})
+			if tail == "" {
+				break
+			}
+			if tail[0] != '[' {
+				return nil, fmt.Errorf("%w: unexpected %q in %q", ErrInvalidPath, tail, path)
+			}
+
+			rest = tail[1:]
+		}
+	}
+
+	return steps, nil
+}
+
+// findNodePath returns the node at steps below a document root, or nil.
+func findNodePath(root *goyaml.Node, steps []nodePathStep) *goyaml.Node {
+	n := root
+	if n.Kind == goyaml.DocumentNode && len(n.Content) > 0 {
+		n = n.Content[0]
+	}
+
+	for _, step := range steps {
+		for n.Kind == goyaml.AliasNode && n.Alias != nil {
+			n = n.Alias
+		}
+
+		switch {
+		case step.isIndex && n.Kind == goyaml.SequenceNode:
+			if step.index >= len(n.Content) {
+				return nil
+			}
+			n = n.Content[step.index]
+
+		case !step.isIndex && n.Kind == goyaml.MappingNode:
+			var next *goyaml.Node
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Value == step.key {
+					next = n.Content[i+1]
+					break
+				}
+			}
+			if next == nil {
+				return nil
+			}
+			n = next
+
+		default:
+			return nil
+		}
+	}
+
+	return n
+}
@@ -0,0 +1,421 @@
+package jamle
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExpandPathWithOptions(t *testing.T) {
+	input := []byte(`# deployment
+spec:
+  replicas: ${REPLICAS:-1}
+  template:
+    image: ${IMAGE}
+    ports:
+      - ${PORT}
+      - name: ${PORT_NAME:-http}
+  script: echo ${HOME}
+`)
+	opts := UnmarshalOptions{Env: map[string]string{"IMAGE": "app:1", "PORT": "8080"}}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{
+			name: "mapping subtree",
+			path: "spec.template",
+			want: `# deployment
+spec:
+  replicas: ${REPLICAS:-1}
+  template:
+    image: app:1
+    ports:
+      - 8080
+      - name: http
+  script: echo ${HOME}
+`,
+		},
+		{
+			name: "sequence index",
+			path: "spec.template.ports[1]",
+			want: `# deployment
+spec:
+  replicas: ${REPLICAS:-1}
+  template:
+    image: ${IMAGE}
+    ports:
+      - ${PORT}
+      - name: http
+  script: echo ${HOME}
+`,
+		},
+		{
+			name: "single scalar",
+			path: "spec.replicas",
+			want: `# deployment
+spec:
+  replicas: 1
+  template:
+    image: ${IMAGE}
+    ports:
+      - ${PORT}
+      - name: ${PORT_NAME:-http}
+  script: echo ${HOME}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandPathWithOptions(input, tt.path, opts)
+			if err != nil {
+				t.Fatalf("ExpandPathWithOptions returned error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("output mismatch:\ngot:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandPathWithOptions_Errors(t *testing.T) {
+	input := []byte("spec:\n  list: [a, b]\n")
+
+	tests := []struct {
+		want error
+		name string
+		path string
+	}{
+		{name: "missing key", path: "spec.other", want: ErrPathNotFound},
+		{name: "index out of range", path: "spec.list[2]", want: ErrPathNotFound},
+		{name: "index on mapping", path: "spec[0]", want: ErrPathNotFound},
+		{name: "empty segment", path: "spec..list", want: ErrInvalidPath},
+		{name: "bad index", path: "spec.list[x]", want: ErrInvalidPath},
+		{name: "unclosed index", path: "spec.list[0", want: ErrInvalidPath},
+		{name: "text after index", path: "spec.list[0]x", want: ErrInvalidPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ExpandPath(input, tt.path); !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestExpandPathWithOptions_IgnoreExpandPaths(t *testing.T) {
+	input := []byte("spec:\n  template:\n    image: ${IMAGE}\n    secret: ${TOKEN}\n    ports:\n      - name: ${PORT_NAME}\n")
+	opts := UnmarshalOptions{
+		Env:               map[string]string{"IMAGE": "app:1", "TOKEN": "t0ken", "PORT_NAME": "http"},
+		IgnoreExpandPaths: []string{"spec.template.secret", "spec.template.ports.*.name"},
+	}
+	want := "spec:\n  template:\n    image: app:1\n    secret: ${TOKEN}\n    ports:\n      - name: ${PORT_NAME}\n"
+
+	// Rules are matched from the document root, not from the selected node.
+	for _, path := range []string{"spec.template", "spec.template.ports[0]"} {
+		got, err := ExpandPathWithOptions(input, path, opts)
+		if err != nil {
+			t.Fatalf("ExpandPathWithOptions(%q) returned error: %v", path, err)
+		}
+		if path == "spec.template" && string(got) != want {
+			t.Fatalf("output mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+		}
+		if path != "spec.template" && string(got) != string(input) {
+			t.Fatalf("ignored path %q was expanded:\n%s", path, got)
+		}
+	}
+
+	var doc struct {
+		Spec struct {
+			Template struct {
+				Secret string `json:"secret"`
+			} `json:"template"`
+		} `json:"spec"`
+	}
+	if err := UnmarshalWithOptions(input, &doc, opts); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+	if doc.Spec.Template.Secret != "${TOKEN}" {
+		t.Fatalf("UnmarshalWithOptions secret = %q, want literal", doc.Spec.Template.Secret)
+	}
+}
+
+func TestExpandPathWithOptions_PreservesStyle(t *testing.T) {
+	input := []byte(`# service
+host: "${HOST}"
+user: '${USER_NAME:-app}'
+port: ${PORT:-8080}
+label: "${PORT:-8080}"
+note: |
+  ${HOST} is primary
+flow: {a: "${HOST}", b: '${PORT:-8080}'}
+`)
+
+	want := `# service
+host: "db.local"
+user: 'app'
+port: 8080
+label: "8080"
+note: |
+  db.local is primary
+flow: {a: "db.local", b: '8080'}
+`
+
+	got, err := ExpandPathWithOptions(input, "", UnmarshalOptions{Env: map[string]string{"HOST": "db.local"}})
+	if err != nil {
+		t.Fatalf("ExpandPathWithOptions returned error: %v", err)
+	}
+	if string(got) != want {
+		t.Fatalf("output mismatch:\ngot\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExpandPathWithOptions_FlowCollections(t *testing.T) {
+	opts := UnmarshalOptions{Env: map[string]string{"P2": "8443", "H": "db"}}
+	input := []byte(`ports: ["${P1:-80}", "${P2:-443}"]
+server: {host: "${H}", tags: ['${ENV:-dev}', fixed], nested: {port: "${P2}"}}
+list:
+  - ["${H}", '${H}']
+`)
+
+	want := `ports: ["80", "8443"]
+server: {host: "db", tags: ['dev', fixed], nested: {port: "8443"}}
+list:
+  - ["db", 'db']
+`
+
+	got, err := ExpandPathWithOptions(input, "", opts)
+	if err != nil {
+		t.Fatalf("ExpandPathWithOptions returned error: %v", err)
+	}
+	if string(got) != want {
+		t.Fatalf("output mismatch:\ngot\n%s\nwant\n%s", got, want)
+	}
+
+	var cfg struct {
+		Ports  []string `json:"ports"`
+		Server struct {
+			Host   string            `json:"host"`
+			Tags   []string          `json:"tags"`
+			Nested map[string]string `json:"nested"`
+		} `json:"server"`
+	}
+	if err := UnmarshalWithOptions(input, &cfg, opts); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.Ports, []string{"80", "8443"}) || cfg.Server.Host != "db" ||
+		!reflect.DeepEqual(cfg.Server.Tags, []string{"dev", "fixed"}) || cfg.Server.Nested["port"] != "8443" {
+		t.Fatalf("got %+v", cfg)
+	}
+
+	// Braces are flow indicators, so unquoted placeholders are not valid
+	// inside flow collections.
+	if _, err := ExpandPathWithOptions([]byte("ports: [${P1:-80}]\n"), "", opts); err == nil {
+		t.Fatal("expected YAML error for unquoted placeholder in flow sequence")
+	}
+}
+
+func TestExpandPathWithOptions_PreserveDocumentHeader(t *testing.T) {
+	opts := UnmarshalOptions{Env: map[string]string{"PORT": "8080"}, PreserveDocumentHeader: true}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "yaml directive and marker",
+			input: "%YAML 1.2\n---\nport: ${PORT}\n",
+			want:  "%YAML 1.2\n---\nport: 8080\n",
+		},
+		{
+			name:  "marker only",
+			input: "---\nport: ${PORT}\n---\nname: b\n",
+			want:  "---\nport: 8080\n---\nname: b\n",
+		},
+		{
+			name:  "marker with comment",
+			input: "--- # config\nport: ${PORT}\n",
+			want:  "---\n# config\nport: 8080\n",
+		},
+		{
+			name:  "no header",
+			input: "port: ${PORT}\n",
+			want:  "port: 8080\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandPathWithOptions([]byte(tt.input), "", opts)
+			if err != nil {
+				t.Fatalf("ExpandPathWithOptions returned error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("output mismatch:\ngot  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+
+	got, err := ExpandPathWithOptions([]byte("---\nport: ${PORT}\n"), "", UnmarshalOptions{Env: opts.Env})
+	if err != nil {
+		t.Fatalf("ExpandPathWithOptions returned error: %v", err)
+	}
+	if string(got) != "port: 8080\n" {
+		t.Fatalf("marker must be dropped without the option, got %q", got)
+	}
+}
+
+func TestExpandPathWithOptions_TrailingNewline(t *testing.T) {
+	yes, no := true, false
+	env := map[string]string{"HOST": "db"}
+
+	tests := []struct {
+		name     string
+		want     *bool
+		wantLast byte
+	}{
+		{name: "default keeps newline", want: nil, wantLast: '\n'},
+		{name: "force keeps newline", want: &yes, wantLast: '\n'},
+		{name: "strip", want: &no, wantLast: '"'},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := UnmarshalOptions{Env: env, TrailingNewline: tt.want}
+			out, err := ExpandPathWithOptions([]byte("host: \"${HOST}\"\n"), "", opts)
+			if err != nil {
+				t.Fatalf("ExpandPathWithOptions returned error: %v", err)
+			}
+
+			if last := out[len(out)-1]; last != tt.wantLast {
+				t.Fatalf("last byte %q, want %q (output %q)", last, tt.wantLast, out)
+			}
+		})
+	}
+
+	t.Run("compact JSON gains newline", func(t *testing.T) {
+		out, err := ExpandJSONWithOptions([]byte(`{"host":"${HOST}"}`), UnmarshalOptions{Env: env, TrailingNewline: &yes})
+		if err != nil {
+			t.Fatalf("ExpandJSONWithOptions returned error: %v", err)
+		}
+
+		if string(out) != "{\"host\":\"db\"}\n" {
+			t.Fatalf("got %q", out)
+		}
+	})
+
+	t.Run("indented JSON loses newline", func(t *testing.T) {
+		out, err := ToJSONWithOptions([]byte("host: ${HOST}\n"), "  ", UnmarshalOptions{Env: env, TrailingNewline: &no})
+		if err != nil {
+			t.Fatalf("ToJSONWithOptions returned error: %v", err)
+		}
+
+		if out[len(out)-1] != '}' {
+			t.Fatalf("got %q", out)
+		}
+	})
+}
+
+func TestExpandPathWithOptions_Comments(t *testing.T) {
+	env := map[string]string{"HOST": "db", "TAG": "blue", "OBJ": "{a: 1}", "LIST": "a,b", "BASE": "{port: 1}"}
+
+	tests := []struct {
+		name  string
+		input string
+		opts  UnmarshalOptions
+		want  string
+	}{
+		{
+			name: "head line and foot on nested keys and items",
+			input: `# head of document
+
+# head of server
+server:
+  # head of host
+  host: ${HOST} # line on host
+  # foot of host
+
+  # head of tags
+  tags:
+    # head of first item
+    - ${TAG} # line on item
+    - static # line on static
+    # foot of tags
+# foot of server
+
+# foot of document
+`,
+			want: `# head of document
+
+# head of server
+server:
+  # head of host
+  host: db # line on host
+  # foot of host
+
+  # head of tags
+  tags:
+    # head of first item
+    - blue # line on item
+    - static # line on static
+    # foot of tags
+# foot of server
+
+# foot of document
+`,
+		},
+		{
+			name:  "comment after key of nested block",
+			input: "server: # line on key\n  host: ${HOST}\n",
+			want:  "server: # line on key\n  host: db\n",
+		},
+		{
+			name:  "flow collections and block scalars",
+			input: "list: [\"${TAG}\", b] # line on flow\nscript: | # line on block\n  echo ${HOST}\n",
+			want:  "list: [\"blue\", b] # line on flow\nscript: | # line on block\n  echo db\n",
+		},
+		{
+			name:  "scalar replaced by parsed yaml",
+			input: "# head\nobj: ${OBJ} # line\n# foot\nz: 1\n",
+			opts:  UnmarshalOptions{ParseExpandedYAML: true},
+			want:  "# head\nobj: {a: 1} # line\n# foot\nz: 1\n",
+		},
+		{
+			name:  "scalar replaced by split sequence",
+			input: "list: ${LIST|split:,} # line\n",
+			want:  "list: [a, b] # line\n",
+		},
+		{
+			name:  "merge placeholder",
+			input: "svc:\n  # head of merge\n  <<: ${BASE} # line on merge\n  k: v\n",
+			want:  "svc:\n  # head of merge\n  !!merge <<: {port: 1} # line on merge\n  k: v\n",
+		},
+		{
+			name:  "blank lines without comments are dropped",
+			input: "a: 1\n\nb: ${HOST}\n",
+			want:  "a: 1\nb: db\n",
+		},
+		{
+			name:  "comments of later documents",
+			input: "a: ${HOST} # first\n---\n# head of second\nb: 2 # second\n",
+			want:  "a: db # first\n---\n# head of second\nb: 2 # second\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Env = env
+			got, err := ExpandPathWithOptions([]byte(tt.input), "", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if string(got) != tt.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+// ExpandString expands ${...} placeholders in a single string using the
+// process environment, without any YAML parsing.
+func ExpandString(s string) (string, error) {
+	return ExpandStringWithOptions(s, UnmarshalOptions{})
+}
+
+// ExpandStringWithOptions expands ${...} placeholders in a single string
+// using configured options. Path-based options such as IgnoreExpandPaths
+// do not apply.
+func ExpandStringWithOptions(s string, opts UnmarshalOptions) (string, error) {
+	return expandEnvInScalar(s, resolveOptions(opts, nil))
+}
@@ -0,0 +1,54 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExpandFiles reads and expands each file of paths and returns the outputs
+// keyed by path. Files with a .json extension are expanded like ExpandJSON,
+// all others like ExpandPath with an empty path. All files share one
+// resolver, so a `${VAR:=default}` assignment made in one file is visible to
+// later files; with Env or Environ the assignments stay in a private overlay.
+// Set DisableAssignment or DisallowAssign to keep files independent.
+// Errors are prefixed with the failing path.
+func ExpandFiles(paths []string, opts UnmarshalOptions) (map[string][]byte, error) {
+	runtime := resolveOptions(opts, nil)
+
+	out := make(map[string][]byte, len(paths))
+	for _, path := range paths {
+		expanded, err := expandFile(path, runtime)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		out[path] = applyTrailingNewline(expanded, runtime.trailingNewline)
+	}
+
+	return out, nil
+}
+
+// expandFile reads path and expands it by its extension.
+func expandFile(path string, runtime runtimeOptions) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckText(data); err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return expandJSON(data, runtime)
+	}
+
+	expanded, _, err := expandNodePath(data, nil, runtime)
+	return expanded, err
+}
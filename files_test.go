@@ -0,0 +1,73 @@
+package jamle
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"base.yaml":  "region: ${REGION:=eu-west-1}\n",
+		"app.yml":    "# app\nendpoint: api.${REGION}.example.com\nport: ${PORT}\n",
+		"meta.json":  `{"region":"${REGION}","port":"${PORT}"}`,
+		"empty.yaml": "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	paths := []string{
+		filepath.Join(dir, "base.yaml"),
+		filepath.Join(dir, "app.yml"),
+		filepath.Join(dir, "meta.json"),
+		filepath.Join(dir, "empty.yaml"),
+	}
+	env := map[string]string{"PORT": "8080"}
+
+	got, err := ExpandFiles(paths, UnmarshalOptions{Env: env})
+	if err != nil {
+		t.Fatalf("ExpandFiles returned error: %v", err)
+	}
+
+	want := map[string]string{
+		paths[0]: "region: eu-west-1\n",
+		paths[1]: "# app\nendpoint: api.eu-west-1.example.com\nport: 8080\n",
+		paths[2]: `{"region":"eu-west-1","port":"8080"}`,
+		paths[3]: "",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("output count mismatch: got %d, want %d", len(got), len(want))
+	}
+	for path, content := range want {
+		if string(got[path]) != content {
+			t.Fatalf("output mismatch for %s:\ngot  %q\nwant %q", path, got[path], content)
+		}
+	}
+	if _, ok := env["REGION"]; ok {
+		t.Fatal("Env map must not be modified")
+	}
+
+	t.Run("error names path", func(t *testing.T) {
+		required := filepath.Join(dir, "required.yaml")
+		if err := os.WriteFile(required, []byte("token: ${TOKEN:?token is required}\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := ExpandFiles([]string{paths[0], required}, UnmarshalOptions{Env: env})
+		if err == nil || !strings.HasPrefix(err.Error(), required+": ") || !strings.Contains(err.Error(), "token is required") {
+			t.Fatalf("expected required error naming %s, got %v", required, err)
+		}
+
+		missing := filepath.Join(dir, "missing.yaml")
+		_, err = ExpandFiles([]string{paths[0], missing}, UnmarshalOptions{Env: env})
+		if !errors.Is(err, os.ErrNotExist) || !strings.HasPrefix(err.Error(), missing+": ") {
+			t.Fatalf("expected not-exist error naming %s, got %v", missing, err)
+		}
+	})
+}
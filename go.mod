@@ -3,6 +3,7 @@ module github.com/woozymasta/jamle
 go 1.25.0
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/jessevdk/go-flags v1.6.1
 	go.yaml.in/yaml/v3 v3.0.4
 )
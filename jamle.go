@@ -5,15 +5,34 @@
 package jamle
 
 import (
+	"iter"
+	"log/slog"
 	"os"
 	"strings"
 )
 
 // placeholders for masking braces in escaped variables.
 const (
-	maskStart        = "\x00"
-	maskEnd          = "\x01"
-	defaultMaxPasses = 10
+	maskStart  = "\x00"
+	maskEnd    = "\x01"
+	maskDollar = "\x02"
+)
+
+// DefaultMaxPasses is the number of expansion passes used when
+// UnmarshalOptions.MaxPasses is not positive.
+const DefaultMaxPasses = 10
+
+// DefaultEscapePrefix is the text that escapes a placeholder when
+// UnmarshalOptions.EscapePrefix is empty: $${VAR} yields ${VAR}.
+const DefaultEscapePrefix = "$$"
+
+// YAMLBooleans values for UnmarshalOptions.YAMLBooleans.
+const (
+	// YAMLBooleans11 treats y/n, yes/no and on/off as booleans after re-typing.
+	YAMLBooleans11 = "1.1"
+
+	// YAMLBooleans12 treats only true/false as booleans after re-typing.
+	YAMLBooleans12 = "1.2"
 )
 
 // Resolver provides variable lookup for ${VAR} expansions.
@@ -32,46 +51,343 @@ type UnmarshalOptions struct {
 	// When nil, process environment resolver is used.
 	Resolver Resolver `json:"resolver" yaml:"resolver" jsonschema:"-"`
 
+	// Env provides values for `${VAR}` expansion from an isolated map.
+	// When non-nil, Resolver and the process environment are not consulted,
+	// and the map is never modified, so concurrent calls with distinct (or
+	// shared read-only) maps do not affect each other.
+	// `${VAR:=default}` assignments are kept in a per-call overlay that is
+	// visible to all later scalars (and documents) of the same call.
+	// Backends added with RegisterResolver are process-global and still
+	// resolve `${name:key}` placeholders.
+	Env map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// Environ provides values from a pre-captured environment in os.Environ
+	// format (NAME=VALUE entries), e.g. relayed from a parent process.
+	// When non-nil, it is parsed into an isolated map like Env: Resolver
+	// and the process environment are not consulted. Entries of Env take
+	// precedence over Environ; among duplicate Environ entries the last wins.
+	Environ []string `json:"environ,omitempty" yaml:"environ,omitempty"`
+
+	// Sources resolves variables from layered lookups tried in order, e.g.
+	// CLI overrides, then os.LookupEnv, then a defaults file. The first
+	// source that reports found wins, even with an empty value; operators
+	// such as `:-` and `:?` then apply to that result. When non-empty,
+	// Resolver, Env, Environ and the process environment are not consulted,
+	// and `${VAR:=default}` assignments are kept in a per-call overlay.
+	Sources []Source `json:"-" yaml:"-" jsonschema:"-"`
+
+	// Defaults supplies fallback values for variables the environment (or
+	// Resolver, Sources) does not have, so applications can keep defaults
+	// in one place instead of repeating `${VAR:-default}` in every config.
+	// Precedence is environment, then Defaults, then the inline default;
+	// a variable set to an empty value is not replaced. Keys are the names
+	// as written in the document, without Prefix. The map is never modified.
+	Defaults map[string]string `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+
+	// Prefix namespaces variable lookups: with Prefix "MYAPP_", `${HOST}`
+	// reads MYAPP_HOST and falls back to HOST when MYAPP_HOST is unset.
+	// `${VAR:=default}` assigns the prefixed name.
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty" jsonschema:"example=MYAPP_"`
+
+	// CaseInsensitive matches variable names regardless of case, so
+	// `${host}` reads HOST. An exact match always wins; a name that only
+	// case-folds to several variables (Host and HOST) returns
+	// ErrAmbiguousVariable. Names come from Env, Environ or the process
+	// environment, plus Defaults keys and names assigned by `${VAR:=x}`;
+	// with Prefix "APP_", `${host}` also finds APP_HOST. It has no effect with a
+	// custom Resolver or Sources, whose names cannot be listed.
+	CaseInsensitive bool `json:"caseInsensitive,omitempty" yaml:"caseInsensitive,omitempty" jsonschema:"default=false,example=true"`
+
+	// SuggestVariables appends close matches from the environment to
+	// missing-variable errors, e.g. `"DB_HOSTT" is not set; did you mean
+	// DB_HOST?`. It is off by default because the errors then reveal other
+	// variable names. Like CaseInsensitive, it has no effect with a custom
+	// Resolver.
+	SuggestVariables bool `json:"suggestVariables,omitempty" yaml:"suggestVariables,omitempty" jsonschema:"default=false,example=true"`
+
 	// IgnoreExpandPaths skips expansion for scalar nodes whose YAML key path
 	// matches one of these glob patterns (dot-separated, `*` for one segment).
 	IgnoreExpandPaths []string `json:"ignoreExpandPaths,omitempty" yaml:"ignoreExpandPaths,omitempty"`
 
+	// OnlyVars restricts expansion to the listed variable names.
+	// Placeholders for other variables are emitted verbatim, including
+	// their operators and nested content, so a later stage can expand them.
+	// When empty, all variables are expanded.
+	OnlyVars []string `json:"onlyVars,omitempty" yaml:"onlyVars,omitempty"`
+
+	// SetOnly expands only placeholders of variables that are set.
+	// Placeholders of unset variables are emitted verbatim, including their
+	// operators, defaults and nested content, so `${VAR:-default}` survives
+	// for a later stage. Variables set to an empty string count as set.
+	SetOnly bool `json:"setOnly,omitempty" yaml:"setOnly,omitempty" jsonschema:"default=false,example=true"`
+
 	// MaxPasses limits nested expansion passes.
-	// When <= 0, default max pass count is used.
+	// When <= 0, DefaultMaxPasses is used.
 	MaxPasses int `json:"maxPasses,omitempty" yaml:"maxPasses,omitempty" jsonschema:"default=10,minimum=1,maximum=1000,example=20"`
 
 	// DisableAssignment disables side effects of `${VAR:=default}`.
 	// When true, `${VAR:=default}` behaves like `${VAR:-default}` and does not call Setter.
 	DisableAssignment bool `json:"disableAssignment,omitempty" yaml:"disableAssignment,omitempty" jsonschema:"default=false,example=true"`
 
+	// DisallowAssign rejects `${VAR:=default}` and `${VAR=default}` with
+	// ErrAssignmentDisabled, whether or not VAR is set, so a document can
+	// never mutate the environment. It takes precedence over DisableAssignment.
+	DisallowAssign bool `json:"disallowAssign,omitempty" yaml:"disallowAssign,omitempty" jsonschema:"default=false,example=true"`
+
+	// DisableResolvedExpansion treats variable values as literal text.
+	// By default, a value that contains `${...}` is expanded in turn, so
+	// A='${B}' yields the value of B. Whoever controls the environment can
+	// then reach any other variable (or trigger `:=` assignments and `:?`
+	// errors) through injected placeholders; set this when values come
+	// from a less trusted source than the document. Defaults written in
+	// the document are still expanded.
+	DisableResolvedExpansion bool `json:"disableResolvedExpansion,omitempty" yaml:"disableResolvedExpansion,omitempty" jsonschema:"default=false,example=true"`
+
+	// KeepStrings keeps every expanded scalar a string.
+	// By default, plain (unquoted) scalars changed by expansion are re-typed,
+	// so `port: ${PORT:-8080}` decodes as an integer. With KeepStrings,
+	// expanded values stay `!!str`, which gives predictable output for
+	// generic targets (map[string]any) but fails for numeric or bool fields.
+	KeepStrings bool `json:"keepStrings,omitempty" yaml:"keepStrings,omitempty" jsonschema:"default=false,example=true"`
+
+	// RetypeTOMLStrings re-types TOML strings that contain `${...}` after
+	// expansion like plain YAML scalars, so `port = "${PORT}"` decodes into
+	// an int field. By default TOML strings stay strings, and
+	// `version = "${V}"` with V=1.10 keeps "1.10".
+	RetypeTOMLStrings bool `json:"retypeTOMLStrings,omitempty" yaml:"retypeTOMLStrings,omitempty" jsonschema:"default=false,example=true"`
+
+	// ParseExpandedYAML parses expansion results as YAML for plain scalars
+	// that consist of exactly one `${...}` placeholder. When the result is a
+	// mapping or sequence, the scalar is replaced by the parsed subtree, so
+	// a whole config section can be injected via one variable. The parsed
+	// subtree is not walked again; other results keep default handling.
+	ParseExpandedYAML bool `json:"parseExpandedYAML,omitempty" yaml:"parseExpandedYAML,omitempty" jsonschema:"default=false,example=true"`
+
+	// ErrorOnDefaultUsed returns ErrDefaultUsed whenever the default branch
+	// of `${VAR:-default}` or `${VAR:=default}` is taken, so deployments
+	// that must define every variable explicitly fail fast.
+	ErrorOnDefaultUsed bool `json:"errorOnDefaultUsed,omitempty" yaml:"errorOnDefaultUsed,omitempty" jsonschema:"default=false,example=true"`
+
+	// ErrorOnUnset returns ErrUnsetVariable for a plain `${VAR}` (or
+	// `${VAR:}`) whose variable is unset, like `set -u` in shells.
+	// Placeholders that provide a fallback, including an explicitly empty
+	// one such as `${VAR:-}` or `${VAR-}`, and `${VAR:+alt}` never fail.
+	// Variables set to an empty string are not affected.
+	ErrorOnUnset bool `json:"errorOnUnset,omitempty" yaml:"errorOnUnset,omitempty" jsonschema:"default=false,example=true"`
+
+	// EmptyIsSet treats variables that are set to an empty string as set.
+	// By default, `${VAR:-x}`, `${VAR:=x}` and `${VAR:?msg}` fall back (or
+	// fail) for empty values like Bash's colon forms. With EmptyIsSet, an
+	// explicitly empty value is kept, like Bash's `${VAR-x}` forms.
+	EmptyIsSet bool `json:"emptyIsSet,omitempty" yaml:"emptyIsSet,omitempty" jsonschema:"default=false,example=true"`
+
+	// ExpandTags expands `${...}` inside node tags such as `!type-${VARIANT}`.
+	// YAML forbids braces in tags, so tags are expanded in the source text
+	// before parsing, where a node may start (line start, after `- `, `: `
+	// or a flow indicator). The leading `!` is kept and a result that is not
+	// a valid tag returns ErrInvalidTag.
+	ExpandTags bool `json:"expandTags,omitempty" yaml:"expandTags,omitempty" jsonschema:"default=false,example=true"`
+
+	// InterpretEscapes processes `\n`, `\t` and `\\` in default values of
+	// `${VAR:-default}` and `${VAR:=default}` (colon or not), so
+	// `${MSG:-line1\nline2}` yields two lines. Other backslashes are kept.
+	// Values read from variables are never changed.
+	InterpretEscapes bool `json:"interpretEscapes,omitempty" yaml:"interpretEscapes,omitempty" jsonschema:"default=false,example=true"`
+
+	// WindowsVars additionally expands Windows-style %NAME% references with
+	// the same lookup. They are substituted before ${...} placeholders, so
+	// `${A:-%B%}` works; substituted values are not scanned for %NAME%
+	// again. References to unset variables stay as written, like in cmd.exe.
+	WindowsVars bool `json:"windowsVars,omitempty" yaml:"windowsVars,omitempty" jsonschema:"default=false,example=true"`
+
+	// Arithmetic evaluates `$((EXPR))` after ${...} expansion, so
+	// `$((${PORT:-8080}+1))` yields 8081. Expressions are integer-only with
+	// + - * / %, unary signs and parentheses; other operands and division
+	// by zero return ErrArithmetic. Off by default, as scalars often embed
+	// shell code that must stay verbatim; `$$((` stays literal.
+	Arithmetic bool `json:"arithmetic,omitempty" yaml:"arithmetic,omitempty" jsonschema:"default=false,example=true"`
+
+	// EscapePrefix replaces the `$$` escape: with `\$`, `\${HOST}` yields a
+	// literal `${HOST}` and `\$` alone yields `$`, while `$${HOST}` is no
+	// longer special. It must end with `$`, be longer than `$` and contain
+	// no braces; otherwise expansion fails with ErrInvalidEscapePrefix.
+	// When empty, DefaultEscapePrefix is used.
+	EscapePrefix string `json:"escapePrefix,omitempty" yaml:"escapePrefix,omitempty" jsonschema:"default=$$,example=\\$"`
+
+	// StrictDollar rejects a `$` in the source text that does not start
+	// `${...}`, `$$` or, with Arithmetic, `$((`, catching typos such as
+	// `$HOST}` or an unterminated `${HOST`. Bare `$NAME` stays allowed
+	// inside default values. Failures wrap ErrStrictDollar and name the
+	// byte offset in the scalar. Resolved values are not checked.
+	StrictDollar bool `json:"strictDollar,omitempty" yaml:"strictDollar,omitempty" jsonschema:"default=false,example=true"`
+
+	// PreserveDocumentHeader keeps the leading %-directives (such as
+	// `%YAML 1.2`) and the `---` marker of the source in ExpandPath output,
+	// which the encoder would otherwise drop. It has no effect on decoding.
+	PreserveDocumentHeader bool `json:"preserveDocumentHeader,omitempty" yaml:"preserveDocumentHeader,omitempty" jsonschema:"default=false,example=true"`
+
+	// TrailingNewline controls the final newline of ExpandPath, ExpandJSON,
+	// ToJSON and ExpandFiles output. Nil keeps each encoder's own behavior
+	// (YAML and indented JSON end with a newline, compact JSON does not);
+	// true adds a missing newline and false strips one. Empty output stays
+	// empty.
+	TrailingNewline *bool `json:"trailingNewline,omitempty" yaml:"trailingNewline,omitempty" jsonschema:"example=false"`
+
+	// YAMLBooleans selects how re-typed plain scalars treat YAML 1.1 boolean
+	// tokens (y/n, yes/no, on/off in any case form). With YAMLBooleans12
+	// (default) only true/false are booleans and the other tokens stay
+	// strings in generic targets. With YAMLBooleans11 they decode as
+	// booleans everywhere. Other values behave like YAMLBooleans12.
+	YAMLBooleans string `json:"yamlBooleans,omitempty" yaml:"yamlBooleans,omitempty" jsonschema:"enum=1.1,enum=1.2,default=1.2"`
+
+	// Trace, when set, is called after every expansion pass that changed a
+	// scalar, with the text before and after the pass and the substitutions
+	// made. Values are reported as resolved, including secrets, so redact
+	// them before logging.
+	Trace func(TraceEvent) `json:"-" yaml:"-" jsonschema:"-"`
+
+	// Logger, when set, receives a debug record for every resolved
+	// placeholder (var, operator, source and whether a default was used)
+	// and a warning for every `${VAR:=default}` assignment made through the
+	// resolver. Values are omitted unless LogValues is set.
+	Logger *slog.Logger `json:"-" yaml:"-" jsonschema:"-"`
+
+	// LogValues adds resolved and assigned values to Logger records.
+	// Values may contain secrets.
+	LogValues bool `json:"logValues,omitempty" yaml:"logValues,omitempty" jsonschema:"default=false,example=true"`
+
 	// DisableRequiredErrors disables errors for `${VAR:?message}`.
 	// When true, `${VAR:?message}` behaves like `${VAR}` and does not return an error.
 	DisableRequiredErrors bool `json:"disableRequiredErrors,omitempty" yaml:"disableRequiredErrors,omitempty" jsonschema:"default=false,example=true"`
+
+	// DisableKeyExpansion keeps mapping keys (and JSON object keys) as
+	// written, so `${REGION}_host: x` decodes with the literal key.
+	// Values are still expanded.
+	DisableKeyExpansion bool `json:"disableKeyExpansion,omitempty" yaml:"disableKeyExpansion,omitempty" jsonschema:"default=false,example=true"`
+
+	// Validators checks resolved values by variable name, e.g.
+	// {"PORT": jamle.ValidateIntRange(1, 65535)}. A validator runs on the
+	// value a placeholder of that variable yields, including defaults, but
+	// not for `${VAR:+alt}` or `${VAR@X}`. A failure returns ErrValidation
+	// naming the variable and the validator's error.
+	Validators map[string]func(string) error `json:"-" yaml:"-" jsonschema:"-"`
+
+	// Transform, when set, rewrites the value of each placeholder before it
+	// is placed into the scalar and before Validators run, e.g. to trim
+	// whitespace or base64-decode variables ending in _B64. It receives the
+	// variable name and the resolved value, including defaults, but is not
+	// called for `${VAR:+alt}` or `${VAR@X}`. The result is used literally.
+	// An error returns ErrValueTransform naming the variable.
+	Transform func(name, value string) (string, error) `json:"-" yaml:"-" jsonschema:"-"`
+
+	// UnsetErrorFormat sets the error text of `${VAR:?}` and `${VAR?}` without
+	// a message; each %s is replaced by the variable name. Placeholders with
+	// their own message keep it. When empty, the text is
+	// `environment variable "VAR" is not set` (or `is not set or empty`).
+	UnsetErrorFormat string `json:"unsetErrorFormat,omitempty" yaml:"unsetErrorFormat,omitempty" jsonschema:"example=%s is required (see the config runbook)"`
 }
 
 // ResolveFunc adapts a function to the Resolver interface.
 type ResolveFunc func(name string) (string, bool)
 
+// Source is one layer of UnmarshalOptions.Sources. os.LookupEnv, a
+// Resolver's Lookup method and MapSource all fit.
+type Source = ResolveFunc
+
+// sourcesResolver tries each source in order until one reports found.
+type sourcesResolver []Source
+
 // envResolver resolves and assigns variables via process environment.
 type envResolver struct{}
 
+// mapEnvResolver resolves variables from an isolated read-only map.
+type mapEnvResolver map[string]string
+
+// overlayResolver records assignments in a private map on top of base resolver.
+type overlayResolver struct {
+	base     Resolver
+	assigned map[string]string
+}
+
+// defaultsResolver falls back to a read-only map when base has no value.
+type defaultsResolver struct {
+	base     Resolver
+	defaults map[string]string
+}
+
+// prefixResolver looks up prefixed names first, then the plain name.
+type prefixResolver struct {
+	base   Resolver
+	prefix string
+}
+
 // runtimeOptions stores normalized expansion options for one unmarshal call.
 type runtimeOptions struct {
 	resolver        Resolver
+	onlyVars        map[string]struct{}
+	setOnly         bool
 	ignorePathRules []pathRule
 	maxPasses       int
 	allowAssignment bool
+	literalValues   bool
+	rejectAssign    bool
 	enforceRequired bool
+	unsetFormat     string
+	validators      map[string]func(string) error
+	transform       func(name, value string) (string, error)
+	keepStrings     bool
+	parseYAML       bool
+	errorOnDefault  bool
+	errorOnUnset    bool
+	yaml11Bools     bool
+	trace           traceFunc
+	logger          *slog.Logger
+	logValues       bool
+	emptyIsSet      bool
+	warn            func(string)
+	expandTags      bool
+	escapes         bool
+	windowsVars     bool
+	arithmetic      bool
+	strictDollar    bool
+	escapePrefix    string
+	escapeErr       error
+	keepKeys        bool
+	keepHeader      bool
+	trailingNewline *bool
+	foldedNames     map[string][]string
+	suggestNames    iter.Seq[string]
+	prefix          string
+	stats           *ExpandStats
 }
 
-// unmaskReplacer restores masked escaped variables back to ${...}.
-var unmaskReplacer = strings.NewReplacer(maskStart, "${", maskEnd, "}")
+// unmaskReplacer restores masked escaped variables back to ${...} and $$ to $.
+var unmaskReplacer = strings.NewReplacer(maskStart, "${", maskEnd, "}", maskDollar, "$")
 
 // Lookup resolves a variable name via the underlying function.
 func (f ResolveFunc) Lookup(name string) (string, bool) {
 	return f(name)
 }
 
+// MapSource returns a Source reading from m, which is never modified.
+func MapSource(m map[string]string) Source {
+	return func(name string) (string, bool) {
+		v, ok := m[name]
+		return v, ok
+	}
+}
+
+// Lookup returns the value from the first source that has name.
+func (r sourcesResolver) Lookup(name string) (string, bool) {
+	for _, source := range r {
+		if v, ok := source(name); ok {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
 // Lookup resolves a variable from process environment.
 func (envResolver) Lookup(name string) (string, bool) {
 	return os.LookupEnv(name)
@@ -81,3 +397,63 @@ func (envResolver) Lookup(name string) (string, bool) {
 func (envResolver) Set(name, value string) error {
 	return os.Setenv(name, value)
 }
+
+// Lookup resolves a variable from the isolated map.
+func (m mapEnvResolver) Lookup(name string) (string, bool) {
+	v, ok := m[name]
+	return v, ok
+}
+
+// Lookup resolves a variable from assignments first, then from base resolver.
+func (r *overlayResolver) Lookup(name string) (string, bool) {
+	if v, ok := r.assigned[name]; ok {
+		return v, true
+	}
+
+	return r.base.Lookup(name)
+}
+
+// Lookup resolves prefix+name, falling back to name when it is unset.
+func (r prefixResolver) Lookup(name string) (string, bool) {
+	if v, ok := r.base.Lookup(r.prefix + name); ok {
+		return v, true
+	}
+
+	return r.base.Lookup(name)
+}
+
+// Set assigns prefix+name when the base resolver supports assignment.
+func (r prefixResolver) Set(name, value string) error {
+	setter, ok := r.base.(Setter)
+	if !ok {
+		return ErrAssignmentUnsupported
+	}
+
+	return setter.Set(r.prefix+name, value)
+}
+
+// Lookup resolves a variable from base, falling back to the defaults map.
+func (r defaultsResolver) Lookup(name string) (string, bool) {
+	if v, ok := r.base.Lookup(name); ok {
+		return v, true
+	}
+
+	v, ok := r.defaults[name]
+	return v, ok
+}
+
+// Set assigns name when the base resolver supports assignment.
+func (r defaultsResolver) Set(name, value string) error {
+	setter, ok := r.base.(Setter)
+	if !ok {
+		return ErrAssignmentUnsupported
+	}
+
+	return setter.Set(name, value)
+}
+
+// Set records an assignment without touching base resolver.
+func (r *overlayResolver) Set(name, value string) error {
+	r.assigned[name] = value
+	return nil
+}
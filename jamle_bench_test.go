@@ -95,10 +95,37 @@ func BenchmarkExpandEnvInScalar(b *testing.B) {
 	for b.Loop() {
 		if _, err := expandEnvInScalar(input, runtimeOptions{
 			resolver:        envResolver{},
-			maxPasses:       defaultMaxPasses,
+			maxPasses:       DefaultMaxPasses,
 			allowAssignment: true,
 		}); err != nil {
 			b.Fatal(err)
 		}
 	}
 }
+
+func BenchmarkTemplate_Render(b *testing.B) {
+	b.Setenv("APP_HOST", "prod.local")
+	b.Setenv("APP_PORT", "9090")
+	b.Setenv("DB_URL", "postgres://prod:prod@db:5432/prod")
+	b.Setenv("DB_POOL_MIN", "4")
+	b.Setenv("DB_POOL_MAX", "32")
+	b.Setenv("DB_ENABLED", "true")
+	b.Setenv("TRACING", "true")
+	b.Setenv("REGION", "us-east-1")
+	b.Setenv("SERVICE_NAME", "svc-prod")
+
+	tmpl, err := Compile(benchmarkYAMLWithEnv)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for b.Loop() {
+		var cfg benchConfig
+		if err := tmpl.Render(nil, &cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package jamle
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fuzzEnv holds short fixed values so output growth stays bounded.
+var fuzzEnv = map[string]string{
+	"A":     "alpha",
+	"B":     "",
+	"HOST":  "db.local",
+	"QUOTE": "it's",
+}
+
+// fuzzMaxValueLen bounds the output of one placeholder after @Q quoting.
+const fuzzMaxValueLen = 16
+
+func FuzzExpandEnvInScalar(f *testing.F) {
+	seeds := []string{
+		"",
+		"plain",
+		"${A}",
+		"$${A}",
+		"$$",
+		"$$$",
+		"${A:-${B:-x}}",
+		"${MISSING:-$A$HOST}",
+		"${A:+alt}${B-x}${B:-y}",
+		"${QUOTE@Q}${A@U}",
+		"${",
+		"}${A",
+		"${A}}",
+		"$${${A}}",
+		"\x00${A}\x01",
+		"\x02$${A}\x00",
+		"a\x00b",
+		"${A|split:,}",
+		"${:-x}${1A}${A/B}",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, in string) {
+		opts := resolveOptions(UnmarshalOptions{Env: fuzzEnv, DisableRequiredErrors: true}, nil)
+
+		out, err := expandEnvInScalar(in, opts)
+		if err == nil && len(out) > (len(in)+1)*fuzzMaxValueLen {
+			t.Fatalf("output of %d bytes for %d bytes of input", len(out), len(in))
+		}
+
+		masked := strings.ContainsAny(in, maskStart+maskEnd+maskDollar)
+		if masked && (strings.Contains(in, "${") || strings.Contains(in, "$$")) {
+			if !errors.Is(err, ErrBinaryInput) {
+				t.Fatalf("expected ErrBinaryInput for input with mask characters, got %q, %v", out, err)
+			}
+		}
+
+		literal := strings.NewReplacer("$", "", "{", "", "}", "").Replace(in)
+		if strings.ContainsAny(literal, maskStart+maskEnd+maskDollar) {
+			return
+		}
+
+		escaped, err := expandEnvInScalar("$${"+literal+"}", opts)
+		if err != nil {
+			t.Fatalf("escaped placeholder returned error: %v", err)
+		}
+		if want := "${" + literal + "}"; escaped != want {
+			t.Fatalf("escaped placeholder mismatch: got %q, want %q", escaped, want)
+		}
+	})
+}
@@ -1,9 +1,14 @@
 package jamle
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -83,6 +88,49 @@ func TestUnmarshal_SubstitutionLogic(t *testing.T) {
 			expected: map[string]interface{}{"value": "sec"},
 		},
 
+		// Transforms (@)
+		{
+			name:     "transform to upper case",
+			yaml:     `value: "${TEST_VAR@U}"`,
+			env:      map[string]string{"TEST_VAR": "Mixed"},
+			expected: map[string]interface{}{"value": "MIXED"},
+		},
+		{
+			name:     "transform first character to upper case",
+			yaml:     `value: "${TEST_VAR@u}"`,
+			env:      map[string]string{"TEST_VAR": "mixed"},
+			expected: map[string]interface{}{"value": "Mixed"},
+		},
+		{
+			name:     "transform to lower case",
+			yaml:     `value: "${TEST_VAR@L}"`,
+			env:      map[string]string{"TEST_VAR": "Mixed"},
+			expected: map[string]interface{}{"value": "mixed"},
+		},
+		{
+			name:     "transform to shell quoted",
+			yaml:     `value: "${TEST_VAR@Q}"`,
+			env:      map[string]string{"TEST_VAR": "it's here"},
+			expected: map[string]interface{}{"value": `'it'\''s here'`},
+		},
+		{
+			name:     "transform quote of empty value",
+			yaml:     `value: "${TEST_VAR@Q}"`,
+			env:      map[string]string{"TEST_VAR": ""},
+			expected: map[string]interface{}{"value": "''"},
+		},
+		{
+			name:     "transform quote of unset value",
+			yaml:     `value: "${TEST_MISSING@Q}"`,
+			expected: map[string]interface{}{"value": ""},
+		},
+		{
+			name:        "unknown transform",
+			yaml:        `value: "${TEST_VAR@Z}"`,
+			env:         map[string]string{"TEST_VAR": "val"},
+			expectError: true,
+		},
+
 		// Escaping
 		{
 			name:     "escaped variable",
@@ -100,110 +148,1059 @@ func TestUnmarshal_SubstitutionLogic(t *testing.T) {
 			yaml:     `value: "$${A:-${B}}"`,
 			expected: map[string]interface{}{"value": "${A:-${B}}"},
 		},
+		{
+			name:     "standalone dollar escape",
+			yaml:     `price: "$$5.00"`,
+			expected: map[string]interface{}{"price": "$5.00"},
+		},
+		{
+			name:     "dollar escape inside plain scalar",
+			yaml:     `value: a$$b`,
+			expected: map[string]interface{}{"value": "a$b"},
+		},
+		{
+			name:     "dollar escape before variable",
+			yaml:     `value: "$$${TEST_VAR}"`,
+			env:      map[string]string{"TEST_VAR": "val"},
+			expected: map[string]interface{}{"value": "$val"},
+		},
+		{
+			name:     "dollar escape inside default value",
+			yaml:     `value: "${TEST_MISSING:-cost $$10}"`,
+			expected: map[string]interface{}{"value": "cost $10"},
+		},
+		{
+			name:     "single dollar stays literal",
+			yaml:     `value: "$5 and $HOME"`,
+			expected: map[string]interface{}{"value": "$5 and $HOME"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			var result map[string]interface{}
+			err := Unmarshal([]byte(tt.yaml), &result)
+
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			// Simple check
+			for k, v := range tt.expected {
+				if result[k] != v {
+					t.Errorf("Key %q: expected %v, got %v", k, v, result[k])
+				}
+			}
+		})
+	}
+}
+
+func TestUnmarshal_AssignmentOperator(t *testing.T) {
+	const varName = "TEST_ASSIGN_X"
+
+	t.Run("assigns if missing", func(t *testing.T) {
+		_ = os.Unsetenv(varName)
+		t.Cleanup(func() { _ = os.Unsetenv(varName) })
+
+		yamlStr := `val: "${TEST_ASSIGN_X:=assigned}"`
+		var res map[string]string
+
+		if err := Unmarshal([]byte(yamlStr), &res); err != nil {
+			t.Fatal(err)
+		}
+
+		if res["val"] != "assigned" {
+			t.Errorf("Expected result 'assigned', got %q", res["val"])
+		}
+		if env := os.Getenv(varName); env != "assigned" {
+			t.Errorf("Expected env var to be set to 'assigned', got %q", env)
+		}
+	})
+
+	t.Run("does not assign if present", func(t *testing.T) {
+		t.Setenv(varName, "existing")
+
+		yamlStr := `val: "${TEST_ASSIGN_X:=new}"`
+		var res map[string]string
+
+		if err := Unmarshal([]byte(yamlStr), &res); err != nil {
+			t.Fatal(err)
+		}
+
+		if res["val"] != "existing" {
+			t.Errorf("Expected result 'existing', got %q", res["val"])
+		}
+		if env := os.Getenv(varName); env != "existing" {
+			t.Errorf("Env var changed unexpectedly to %q", env)
+		}
+	})
+}
+
+func TestUnmarshalWithOptions_DisallowAssign(t *testing.T) {
+	const varName = "TEST_DISALLOW_ASSIGN_X"
+	t.Setenv(varName, "")
+	_ = os.Unsetenv(varName)
+
+	tests := []struct {
+		name  string
+		input string
+		opts  UnmarshalOptions
+	}{
+		{name: "unset", input: "val: ${TEST_DISALLOW_ASSIGN_X:=new}"},
+		{name: "no colon", input: "val: ${TEST_DISALLOW_ASSIGN_X=new}"},
+		{name: "set", input: "val: ${PRESENT:=new}", opts: UnmarshalOptions{Env: map[string]string{"PRESENT": "x"}}},
+		{name: "with disable assignment", input: "val: ${TEST_DISALLOW_ASSIGN_X:=new}", opts: UnmarshalOptions{DisableAssignment: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.DisallowAssign = true
+
+			var res map[string]string
+			err := UnmarshalWithOptions([]byte(tt.input), &res, tt.opts)
+			if !errors.Is(err, ErrAssignmentDisabled) {
+				t.Fatalf("expected ErrAssignmentDisabled, got %v", err)
+			}
+			if _, ok := os.LookupEnv(varName); ok {
+				t.Fatalf("%s must not be assigned", varName)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_TypePreservation(t *testing.T) {
+	yamlStr := `
+integer: ${INT_VAL:-42}
+float: ${FLOAT_VAL:-42.5}
+bool: ${BOOL_VAL:-true}
+string_num: "${STR_VAL:-123}"
+`
+
+	type Config struct {
+		Integer   int     `json:"integer"`
+		Float     float64 `json:"float"`
+		Bool      bool    `json:"bool"`
+		StringNum string  `json:"string_num"`
+	}
+
+	var cfg Config
+	if err := Unmarshal([]byte(yamlStr), &cfg); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	if cfg.Integer != 42 {
+		t.Errorf("Expected int 42, got %d", cfg.Integer)
+	}
+	if cfg.Float != 42.5 {
+		t.Errorf("Expected float 42.5, got %f", cfg.Float)
+	}
+	if cfg.Bool != true {
+		t.Errorf("Expected bool true, got %v", cfg.Bool)
+	}
+	if cfg.StringNum != "123" {
+		t.Errorf("Expected string '123', got %q", cfg.StringNum)
+	}
+}
+
+func TestUnmarshal_SliceOfStructsDefaults(t *testing.T) {
+	type ServiceConfig struct {
+		Name    string `json:"name"`
+		Host    string `json:"host"`
+		Port    int    `json:"port"`
+		Enabled bool   `json:"enabled"`
+	}
+
+	input := []byte(`
+services:
+  - name: api
+    host: ${API_HOST:-localhost}
+    port: ${API_PORT:-8080}
+    enabled: ${API_ENABLED:-true}
+  - name: worker
+    host: ${WORKER_HOST:-worker.local}
+    port: ${WORKER_PORT:-9090}
+    enabled: ${WORKER_ENABLED:-false}
+`)
+
+	env := map[string]string{"API_HOST": "api.internal", "API_PORT": "443"}
+
+	var cfg struct {
+		Services []ServiceConfig `json:"services"`
+	}
+	if err := UnmarshalWithOptions(input, &cfg, UnmarshalOptions{Env: env}); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+
+	want := []ServiceConfig{
+		{Name: "api", Host: "api.internal", Port: 443, Enabled: true},
+		{Name: "worker", Host: "worker.local", Port: 9090, Enabled: false},
+	}
+	if !reflect.DeepEqual(cfg.Services, want) {
+		t.Fatalf("services mismatch:\ngot  %+v\nwant %+v", cfg.Services, want)
+	}
+}
+
+func TestUnmarshal_NonMappingRoot(t *testing.T) {
+	env := map[string]string{"A": "first", "B": "second", "HOST": "db.local"}
+
+	var list []string
+	if err := UnmarshalWithOptions([]byte("- ${A}\n- ${B}\n- ${C:-third}\n"), &list, UnmarshalOptions{Env: env}); err != nil {
+		t.Fatalf("sequence root returned error: %v", err)
+	}
+	if want := []string{"first", "second", "third"}; !reflect.DeepEqual(list, want) {
+		t.Fatalf("sequence root mismatch: got %#v, want %#v", list, want)
+	}
+
+	var host string
+	if err := UnmarshalWithOptions([]byte("${HOST}"), &host, UnmarshalOptions{Env: env}); err != nil {
+		t.Fatalf("scalar root returned error: %v", err)
+	}
+	if host != "db.local" {
+		t.Fatalf("scalar root mismatch: got %q", host)
+	}
+
+	var port int
+	if err := UnmarshalWithOptions([]byte("${PORT:-8080}\n"), &port, UnmarshalOptions{Env: env}); err != nil {
+		t.Fatalf("typed scalar root returned error: %v", err)
+	}
+	if port != 8080 {
+		t.Fatalf("typed scalar root mismatch: got %d", port)
+	}
+
+	var docs []string
+	if err := UnmarshalAllWithOptions([]byte("${A}\n---\n${B}\n"), &docs, UnmarshalOptions{Env: env}); err != nil {
+		t.Fatalf("scalar stream returned error: %v", err)
+	}
+	if want := []string{"first", "second"}; !reflect.DeepEqual(docs, want) {
+		t.Fatalf("scalar stream mismatch: got %#v, want %#v", docs, want)
+	}
+}
+
+func TestUnmarshal_MergeKeyAnchorExpansion(t *testing.T) {
+	type service struct {
+		Host    string `json:"host"`
+		Port    int    `json:"port"`
+		Timeout string `json:"timeout"`
+	}
+
+	input := []byte(`
+defaults: &defaults
+  host: ${DB_HOST:-localhost}
+  port: ${DB_PORT:-5432}
+  timeout: ${DB_TIMEOUT:-5s}
+primary:
+  <<: *defaults
+replica:
+  <<: *defaults
+  port: ${REPLICA_PORT:-5433}
+`)
+
+	var cfg struct {
+		Primary service `json:"primary"`
+		Replica service `json:"replica"`
+	}
+	err := UnmarshalWithOptions(input, &cfg, UnmarshalOptions{Env: map[string]string{"DB_HOST": "db.internal"}})
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+
+	if want := (service{Host: "db.internal", Port: 5432, Timeout: "5s"}); cfg.Primary != want {
+		t.Fatalf("primary mismatch: got %+v, want %+v", cfg.Primary, want)
+	}
+	if want := (service{Host: "db.internal", Port: 5433, Timeout: "5s"}); cfg.Replica != want {
+		t.Fatalf("replica mismatch: got %+v, want %+v", cfg.Replica, want)
+	}
+}
+
+func TestUnmarshal_LiteralDirective(t *testing.T) {
+	input := []byte(`
+host: ${HOST}
+script: echo ${HOST} # jamle:literal
+hooks: # jamle:literal
+  pre: ${HOOK_DIR}/pre.sh
+  args:
+    - ${HOST}
+# jamle:literal
+template:
+  name: ${NAME}
+list:
+  - ${HOST}
+  - ${HOST} # jamle:literal
+`)
+
+	want := map[string]any{
+		"host":     "db",
+		"script":   "echo ${HOST}",
+		"hooks":    map[string]any{"pre": "${HOOK_DIR}/pre.sh", "args": []any{"${HOST}"}},
+		"template": map[string]any{"name": "${NAME}"},
+		"list":     []any{"db", "${HOST}"},
+	}
+
+	env := map[string]string{"HOST": "db"}
+	for _, ignore := range [][]string{nil, {"unrelated"}} {
+		var got map[string]any
+		opts := UnmarshalOptions{Env: env, IgnoreExpandPaths: ignore}
+		if err := UnmarshalWithOptions(input, &got, opts); err != nil {
+			t.Fatalf("UnmarshalWithOptions(ignore=%v) returned error: %v", ignore, err)
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("ignore=%v: got %#v, want %#v", ignore, got, want)
+		}
+	}
+}
+
+func TestUnmarshal_MergeKeyFromVariable(t *testing.T) {
+	type service struct {
+		Host    string `json:"host"`
+		Port    int    `json:"port"`
+		Timeout string `json:"timeout"`
+	}
+
+	env := map[string]string{
+		"DEFAULTS":      "host: db.internal\nport: ${DB_PORT:-5432}\ntimeout: 5s\n",
+		"JSON_DEFAULTS": `{"host": "json.internal", "port": 6000}`,
+		"LIST":          "[a, b]",
+		"PLAIN":         "not a map",
+	}
+
+	input := []byte(`
+primary:
+  <<: ${DEFAULTS}
+replica:
+  <<: ${JSON_DEFAULTS}
+  port: 6001
+  timeout: 1s
+`)
+
+	for _, parseYAML := range []bool{false, true} {
+		var cfg struct {
+			Primary service `json:"primary"`
+			Replica service `json:"replica"`
+		}
+		err := UnmarshalWithOptions(input, &cfg, UnmarshalOptions{Env: env, ParseExpandedYAML: parseYAML})
+		if err != nil {
+			t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+		}
+
+		if want := (service{Host: "db.internal", Port: 5432, Timeout: "5s"}); cfg.Primary != want {
+			t.Fatalf("primary mismatch: got %+v, want %+v", cfg.Primary, want)
+		}
+		if want := (service{Host: "json.internal", Port: 6001, Timeout: "1s"}); cfg.Replica != want {
+			t.Fatalf("replica mismatch: got %+v, want %+v", cfg.Replica, want)
+		}
+	}
+
+	for _, name := range []string{"LIST", "PLAIN", "MISSING"} {
+		var got map[string]any
+		err := UnmarshalWithOptions([]byte("a:\n  <<: ${"+name+"}\n  b: 1\n"), &got, UnmarshalOptions{Env: env})
+		if !errors.Is(err, ErrInvalidMerge) {
+			t.Fatalf("%s: expected ErrInvalidMerge, got %v", name, err)
+		}
+
+		var expandErr *ExpandError
+		if !errors.As(err, &expandErr) || expandErr.Var != name || expandErr.Line != 2 {
+			t.Fatalf("%s: expected variable and line in error, got %v", name, err)
+		}
+	}
+}
+
+func TestExpandString_OperatorMatrix(t *testing.T) {
+	env := map[string]string{"SET": "value", "EMPTY": ""}
+
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "${SET:-x}", want: "value"},
+		{input: "${EMPTY:-x}", want: "x"},
+		{input: "${UNSET:-x}", want: "x"},
+		{input: "${SET-x}", want: "value"},
+		{input: "${EMPTY-x}", want: ""},
+		{input: "${UNSET-x}", want: "x"},
+
+		{input: "${SET:=x}", want: "value"},
+		{input: "${EMPTY:=x}", want: "x"},
+		{input: "${UNSET:=x}", want: "x"},
+		{input: "${SET=x}", want: "value"},
+		{input: "${EMPTY=x}", want: ""},
+		{input: "${UNSET=x}", want: "x"},
+
+		{input: "${SET:?msg}", want: "value"},
+		{input: "${EMPTY:?msg}", wantErr: true},
+		{input: "${UNSET:?msg}", wantErr: true},
+		{input: "${SET?msg}", want: "value"},
+		{input: "${EMPTY?msg}", want: ""},
+		{input: "${UNSET?msg}", wantErr: true},
+
+		{input: "${SET:+alt}", want: "alt"},
+		{input: "${EMPTY:+alt}", want: ""},
+		{input: "${UNSET:+alt}", want: ""},
+		{input: "${SET+alt}", want: "alt"},
+		{input: "${EMPTY+alt}", want: "alt"},
+		{input: "${UNSET+alt}", want: ""},
+
+		{input: "${UNSET-$SET}", want: "value"},
+		{input: "${UNSET-${EMPTY:-nested}}", want: "nested"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ExpandStringWithOptions(tt.input, UnmarshalOptions{Env: env})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandStringWithOptions returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("value mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("no-colon assignment keeps empty value", func(t *testing.T) {
+		env := map[string]string{"EMPTY": ""}
+		var got map[string]string
+		err := UnmarshalWithOptions([]byte("a: ${EMPTY=x}\nb: ${UNSET=y}\nc: ${UNSET}\n"), &got, UnmarshalOptions{Env: env})
+		if err != nil {
+			t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+		}
+		if got["a"] != "" || got["b"] != "y" || got["c"] != "y" {
+			t.Fatalf("assignment mismatch: %#v", got)
+		}
+	})
+}
+
+func TestUnmarshal_InvalidVariableNames(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{name: "slash in name", value: "${MY/VAR}"},
+		{name: "dot in name", value: "${HOST.NAME}"},
+		{name: "space in name", value: "${MY VAR}"},
+		{name: "leading digit", value: "${1HOST}"},
+		{name: "empty name", value: "${}"},
+		{name: "empty name with default", value: "${:-x}"},
+		{name: "invalid name with transform", value: "${MY/VAR@U}"},
+		{name: "invalid name with operator", value: "${MY/VAR:-x}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]string
+			err := UnmarshalWithOptions([]byte("a: \""+tt.value+"\"\n"), &got, UnmarshalOptions{
+				Env: map[string]string{"MY": "x", "VAR": "y"},
+			})
+			if !errors.Is(err, ErrInvalidVariableName) {
+				t.Fatalf("expected ErrInvalidVariableName for %s, got: %v", tt.value, err)
+			}
+		})
+	}
+
+	t.Run("valid names", func(t *testing.T) {
+		var got map[string]string
+		err := UnmarshalWithOptions([]byte("a: \"${HOST_}${_PRIVATE}${h0st}\"\n"), &got, UnmarshalOptions{
+			Env: map[string]string{"HOST_": "a", "_PRIVATE": "b", "h0st": "c"},
+		})
+		if err != nil {
+			t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+		}
+		if got["a"] != "abc" {
+			t.Fatalf("value mismatch: got %q, want %q", got["a"], "abc")
+		}
+	})
+}
+
+func TestUnmarshal_RawMessageSubtree(t *testing.T) {
+	type pluginConfig struct {
+		Name   string          `json:"name"`
+		Config json.RawMessage `json:"config"`
+	}
+
+	input := []byte(`
+name: ${PLUGIN_NAME:-auth}
+config:
+  endpoint: https://${AUTH_HOST}/token
+  timeout: ${AUTH_TIMEOUT:-30}
+  literal: "$${NOT_EXPANDED}"
+`)
+
+	var got pluginConfig
+	err := UnmarshalWithOptions(input, &got, UnmarshalOptions{
+		Env: map[string]string{"AUTH_HOST": "auth.local"},
+	})
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+
+	want := `{"endpoint":"https://auth.local/token","literal":"${NOT_EXPANDED}","timeout":30}`
+	if got.Name != "auth" || string(got.Config) != want {
+		t.Fatalf("decoded mismatch: name=%q config=%s, want config=%s", got.Name, got.Config, want)
+	}
+}
+
+func TestUnmarshal_RawMessageMap(t *testing.T) {
+	type authConfig struct {
+		Endpoint string `json:"endpoint"`
+		Timeout  int    `json:"timeout"`
+	}
+	type cacheConfig struct {
+		Hosts []string `json:"hosts"`
+		TTL   string   `json:"ttl"`
+	}
+
+	input := []byte(`
+auth:
+  endpoint: https://${AUTH_HOST}/token
+  timeout: ${AUTH_TIMEOUT:-30}
+cache:
+  hosts:
+    - ${CACHE_HOST}
+    - ${CACHE_HOST_2:-cache-2.local}
+  ttl: ${CACHE_TTL:-5m}
+`)
+
+	var got map[string]json.RawMessage
+	err := UnmarshalWithOptions(input, &got, UnmarshalOptions{
+		Env: map[string]string{"AUTH_HOST": "auth.local", "CACHE_HOST": "cache-1.local"},
+	})
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+
+	var auth authConfig
+	if err := json.Unmarshal(got["auth"], &auth); err != nil {
+		t.Fatalf("auth block is not valid JSON %s: %v", got["auth"], err)
+	}
+	if want := (authConfig{Endpoint: "https://auth.local/token", Timeout: 30}); auth != want {
+		t.Fatalf("auth mismatch: got %+v, want %+v", auth, want)
+	}
+
+	var cache cacheConfig
+	if err := json.Unmarshal(got["cache"], &cache); err != nil {
+		t.Fatalf("cache block is not valid JSON %s: %v", got["cache"], err)
+	}
+	want := cacheConfig{Hosts: []string{"cache-1.local", "cache-2.local"}, TTL: "5m"}
+	if !reflect.DeepEqual(cache, want) {
+		t.Fatalf("cache mismatch: got %+v, want %+v", cache, want)
+	}
+}
+
+func TestUnmarshalWithOptions_ParseExpandedYAML(t *testing.T) {
+	env := map[string]string{
+		"BLOCK":  "host: db.local\nport: ${PORT:-8080}\n",
+		"LIST":   "[a, b]",
+		"SCALAR": "42",
+		"BROKEN": "a: [",
+	}
+	input := []byte(`
+block: ${BLOCK}
+list: ${LIST}
+scalar: ${SCALAR}
+broken: ${BROKEN}
+quoted: "${LIST}"
+mixed: prefix ${LIST}
+default: ${MISSING:-[x, y]}
+`)
+
+	t.Run("enabled", func(t *testing.T) {
+		var got map[string]any
+		err := UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: env, ParseExpandedYAML: true})
+		if err != nil {
+			t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+		}
+
+		want := map[string]any{
+			"block":   map[string]any{"host": "db.local", "port": 8080},
+			"list":    []any{"a", "b"},
+			"scalar":  42,
+			"broken":  "a: [",
+			"quoted":  "[a, b]",
+			"mixed":   "prefix [a, b]",
+			"default": []any{"x", "y"},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("decoded mismatch:\ngot  %#v\nwant %#v", got, want)
+		}
+	})
+
+	t.Run("enabled with ignore paths", func(t *testing.T) {
+		var got map[string]any
+		err := UnmarshalWithOptions([]byte("block: ${BLOCK}\nskip: ${LIST}\n"), &got, UnmarshalOptions{
+			Env:               env,
+			ParseExpandedYAML: true,
+			IgnoreExpandPaths: []string{"skip"},
+		})
+		if err != nil {
+			t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+		}
+
+		block, ok := got["block"].(map[string]any)
+		if !ok || block["host"] != "db.local" || got["skip"] != "${LIST}" {
+			t.Fatalf("decoded mismatch: %#v", got)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var got map[string]any
+		err := UnmarshalWithOptions([]byte("list: ${LIST}\n"), &got, UnmarshalOptions{Env: env})
+		if err != nil {
+			t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+		}
+		if got["list"] != "[a, b]" {
+			t.Fatalf("expected plain string, got %#v", got["list"])
+		}
+	})
+}
+
+func TestUnmarshal_ExpandError(t *testing.T) {
+	tests := []struct {
+		wantIs     error
+		name       string
+		input      string
+		wantVar    string
+		wantScalar string
+	}{
+		{
+			name:       "required variable",
+			input:      "url: http://${JAMLE_EE_HOST:?host is required}:80\n",
+			wantVar:    "JAMLE_EE_HOST",
+			wantScalar: "http://${JAMLE_EE_HOST:?host is required}:80",
+		},
+		{
+			name:       "nested required variable",
+			input:      "v: ${JAMLE_EE_OUTER:-${JAMLE_EE_INNER:?inner}}\n",
+			wantVar:    "JAMLE_EE_INNER",
+			wantScalar: "${JAMLE_EE_OUTER:-${JAMLE_EE_INNER:?inner}}",
+		},
+		{
+			name:       "unsupported transform",
+			input:      "v: ${JAMLE_EE_HOST@X}\n",
+			wantVar:    "JAMLE_EE_HOST",
+			wantScalar: "${JAMLE_EE_HOST@X}",
+			wantIs:     ErrUnsupportedTransform,
+		},
+		{
+			name:       "invalid name",
+			input:      "v: ${MY/VAR}\n",
+			wantVar:    "MY/VAR",
+			wantScalar: "${MY/VAR}",
+			wantIs:     ErrInvalidVariableName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			err := UnmarshalWithOptions([]byte(tt.input), &got, UnmarshalOptions{Env: map[string]string{}})
+
+			var expandErr *ExpandError
+			if !errors.As(err, &expandErr) {
+				t.Fatalf("expected *ExpandError, got %T: %v", err, err)
+			}
+			if expandErr.Var != tt.wantVar || expandErr.Scalar != tt.wantScalar {
+				t.Fatalf("context mismatch: var=%q scalar=%q", expandErr.Var, expandErr.Scalar)
+			}
+			if tt.wantIs != nil && !errors.Is(err, tt.wantIs) {
+				t.Fatalf("expected errors.Is(%v), got: %v", tt.wantIs, err)
+			}
+			if !strings.Contains(err.Error(), tt.wantScalar) {
+				t.Fatalf("error message lacks scalar context: %v", err)
+			}
+		})
+	}
+}
+
+func TestExpandStringWithOptions_EmptyIsSet(t *testing.T) {
+	env := map[string]string{"EMPTY": ""}
+
+	tests := []struct {
+		name       string
+		input      string
+		want       string
+		wantErr    bool
+		emptyIsSet bool
+	}{
+		{name: "default for empty", input: "${EMPTY:-x}", want: "x"},
+		{name: "default for empty kept", input: "${EMPTY:-x}", emptyIsSet: true, want: ""},
+		{name: "default for unset", input: "${UNSET:-x}", emptyIsSet: true, want: "x"},
+		{name: "assign for empty", input: "${EMPTY:=x}", want: "x"},
+		{name: "assign for empty kept", input: "${EMPTY:=x}", emptyIsSet: true, want: ""},
+		{name: "required empty fails", input: "${EMPTY:?required}", wantErr: true},
+		{name: "required empty kept", input: "${EMPTY:?required}", emptyIsSet: true, want: ""},
+		{name: "required unset fails", input: "${UNSET:?required}", emptyIsSet: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandStringWithOptions(tt.input, UnmarshalOptions{Env: env, EmptyIsSet: tt.emptyIsSet})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandStringWithOptions returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("value mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalWithOptions_ErrorOnDefaultUsed(t *testing.T) {
+	env := map[string]string{"HOST": "svc.local", "EMPTY": ""}
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantVar string
+	}{
+		{name: "set variable", input: "v: ${HOST:-localhost}\n", want: "svc.local"},
+		{name: "plain variable", input: "v: ${MISSING}\n", want: ""},
+		{name: "unset with default", input: "v: ${MISSING:-x}\n", wantVar: "MISSING"},
+		{name: "empty with default", input: "v: ${EMPTY:-x}\n", wantVar: "EMPTY"},
+		{name: "assignment default", input: "v: ${MISSING:=x}\n", wantVar: "MISSING"},
+		{name: "nested default", input: "v: ${MISSING:-${HOST}}\n", wantVar: "MISSING"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]string
+			err := UnmarshalWithOptions([]byte(tt.input), &got, UnmarshalOptions{
+				Env:                env,
+				ErrorOnDefaultUsed: true,
+			})
+
+			if tt.wantVar == "" {
+				if err != nil {
+					t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+				}
+				if got["v"] != tt.want {
+					t.Fatalf("value mismatch: got %q, want %q", got["v"], tt.want)
+				}
+				return
+			}
+
+			var expandErr *ExpandError
+			if !errors.Is(err, ErrDefaultUsed) || !errors.As(err, &expandErr) {
+				t.Fatalf("expected ErrDefaultUsed, got: %v", err)
+			}
+			if expandErr.Var != tt.wantVar {
+				t.Fatalf("variable mismatch: got %q, want %q", expandErr.Var, tt.wantVar)
+			}
+		})
+	}
+}
+
+func TestUnmarshalWithOptions_YAMLBooleans(t *testing.T) {
+	tokens := map[string]bool{
+		"yes": true, "Yes": true, "on": true, "ON": true, "y": true,
+		"no": false, "No": false, "off": false, "OFF": false, "n": false,
+	}
+
+	for token, want := range tokens {
+		input := []byte("f: ${FLAG:-" + token + "}\nq: \"${FLAG:-" + token + "}\"\nt: ${FLAG:-true}\n")
+
+		for _, mode := range []string{"", YAMLBooleans12, YAMLBooleans11} {
+			t.Run(token+"/"+mode, func(t *testing.T) {
+				var got map[string]any
+				err := UnmarshalWithOptions(input, &got, UnmarshalOptions{
+					Env:          map[string]string{},
+					YAMLBooleans: mode,
+				})
+				if err != nil {
+					t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+				}
+
+				var wantF any = token
+				if mode == YAMLBooleans11 {
+					wantF = want
+				}
+				if got["f"] != wantF {
+					t.Fatalf("plain value mismatch: got %#v, want %#v", got["f"], wantF)
+				}
+				if got["q"] != token {
+					t.Fatalf("quoted value must stay string: got %#v", got["q"])
+				}
+				if got["t"] != true {
+					t.Fatalf("true must stay boolean: got %#v", got["t"])
+				}
+			})
+		}
+	}
+}
+
+func TestExpandString(t *testing.T) {
+	t.Setenv("JAMLE_EXPAND_STRING_HOST", "svc.local")
+
+	got, err := ExpandString("https://${JAMLE_EXPAND_STRING_HOST}:${JAMLE_EXPAND_STRING_PORT:-443}/$${PATH}")
+	if err != nil {
+		t.Fatalf("ExpandString returned error: %v", err)
+	}
+	if want := "https://svc.local:443/${PATH}"; got != want {
+		t.Fatalf("value mismatch: got %q, want %q", got, want)
+	}
+
+	got, err = ExpandStringWithOptions("${HOST@U}", UnmarshalOptions{Env: map[string]string{"HOST": "db"}})
+	if err != nil || got != "DB" {
+		t.Fatalf("ExpandStringWithOptions mismatch: got %q, err %v", got, err)
+	}
+
+	if _, err := ExpandString("${JAMLE_EXPAND_STRING_MISSING:?required}"); err == nil {
+		t.Fatal("expected error for required variable")
+	}
+}
+
+func TestUnmarshal_DurationAndTimeFields(t *testing.T) {
+	type config struct {
+		Started  time.Time      `json:"started"`
+		Interval *time.Duration `json:"interval"`
+		Timeout  time.Duration  `json:"timeout"`
+	}
+
+	input := []byte(`
+timeout: ${TIMEOUT:-30s}
+interval: ${INTERVAL:-1m30s}
+started: ${STARTED:-2026-01-02T03:04:05Z}
+`)
+
+	var got config
+	err := UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: map[string]string{"TIMEOUT": "250ms"}})
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+
+	if got.Timeout != 250*time.Millisecond {
+		t.Fatalf("timeout mismatch: got %v", got.Timeout)
+	}
+	if got.Interval == nil || *got.Interval != 90*time.Second {
+		t.Fatalf("interval mismatch: got %v", got.Interval)
+	}
+	if want := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC); !got.Started.Equal(want) {
+		t.Fatalf("started mismatch: got %v, want %v", got.Started, want)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			for k, v := range tt.env {
-				t.Setenv(k, v)
-			}
-
-			var result map[string]interface{}
-			err := Unmarshal([]byte(tt.yaml), &result)
+	for _, bad := range []string{"abc", "30"} {
+		err := UnmarshalWithOptions([]byte("timeout: ${TIMEOUT:-"+bad+"}\n"), &got, UnmarshalOptions{Env: map[string]string{}})
+		if err == nil {
+			t.Fatalf("expected error for duration %q", bad)
+		}
+	}
+}
 
-			if tt.expectError {
-				if err == nil {
-					t.Error("Expected error but got none")
-				}
-				return
-			}
-			if err != nil {
-				t.Fatalf("Unexpected error: %v", err)
-			}
+func TestUnmarshal_ExpandErrorPosition(t *testing.T) {
+	input := []byte(`
+server:
+  host: localhost
+  auth:
+    - user: admin
+      token: Bearer ${JAMLE_POS_TOKEN:?token is required}
+`)
 
-			// Simple check
-			for k, v := range tt.expected {
-				if result[k] != v {
-					t.Errorf("Key %q: expected %v, got %v", k, v, result[k])
-				}
-			}
+	for _, ignore := range [][]string{nil, {"server.host"}} {
+		var got map[string]any
+		err := UnmarshalWithOptions(input, &got, UnmarshalOptions{
+			Env:               map[string]string{},
+			IgnoreExpandPaths: ignore,
 		})
+
+		var expandErr *ExpandError
+		if !errors.As(err, &expandErr) {
+			t.Fatalf("expected *ExpandError, got: %v", err)
+		}
+		if expandErr.Line != 6 || expandErr.Column != 14 {
+			t.Fatalf("position mismatch: got %d:%d, want 6:14", expandErr.Line, expandErr.Column)
+		}
+		if !strings.HasPrefix(err.Error(), "line 6:14: ") {
+			t.Fatalf("error message lacks position: %v", err)
+		}
+	}
+
+	_, err := ExpandString("${JAMLE_POS_TOKEN:?token is required}")
+	var expandErr *ExpandError
+	if !errors.As(err, &expandErr) || expandErr.Line != 0 || strings.HasPrefix(err.Error(), "line ") {
+		t.Fatalf("ExpandString error must not carry position: %v", err)
 	}
 }
 
-func TestUnmarshal_AssignmentOperator(t *testing.T) {
-	const varName = "TEST_ASSIGN_X"
+func TestUnmarshal_ExpandedKeys(t *testing.T) {
+	env := map[string]string{"PREFIX": "app", "SPACED": "a: b c"}
 
-	t.Run("assigns if missing", func(t *testing.T) {
-		_ = os.Unsetenv(varName)
-		t.Cleanup(func() { _ = os.Unsetenv(varName) })
+	for _, ignore := range [][]string{nil, {"unrelated"}} {
+		t.Run("quoted and plain keys", func(t *testing.T) {
+			input := []byte("\"${PREFIX}_name\": one\n${PREFIX}_id: two\n\"${SPACED}\": three\n")
 
-		yamlStr := `val: "${TEST_ASSIGN_X:=assigned}"`
-		var res map[string]string
+			var got map[string]string
+			err := UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: env, IgnoreExpandPaths: ignore})
+			if err != nil {
+				t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+			}
 
-		if err := Unmarshal([]byte(yamlStr), &res); err != nil {
-			t.Fatal(err)
-		}
+			want := map[string]string{"app_name": "one", "app_id": "two", "a: b c": "three"}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("decoded mismatch:\ngot  %#v\nwant %#v", got, want)
+			}
+		})
 
-		if res["val"] != "assigned" {
-			t.Errorf("Expected result 'assigned', got %q", res["val"])
-		}
-		if env := os.Getenv(varName); env != "assigned" {
-			t.Errorf("Expected env var to be set to 'assigned', got %q", env)
-		}
-	})
+		t.Run("duplicate after expansion", func(t *testing.T) {
+			input := []byte("nested:\n  app_name: one\n  \"${PREFIX}_name\": two\n")
 
-	t.Run("does not assign if present", func(t *testing.T) {
-		t.Setenv(varName, "existing")
+			var got map[string]any
+			err := UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: env, IgnoreExpandPaths: ignore})
+			if !errors.Is(err, ErrDuplicateKey) {
+				t.Fatalf("expected ErrDuplicateKey, got: %v", err)
+			}
+			if !strings.Contains(err.Error(), `"app_name" at line 3:3, first defined at line 2:3`) {
+				t.Fatalf("error lacks key position: %v", err)
+			}
 
-		yamlStr := `val: "${TEST_ASSIGN_X:=new}"`
-		var res map[string]string
+			_, err = UnmarshalOrderedWithOptions(input, UnmarshalOptions{Env: env, IgnoreExpandPaths: ignore})
+			if !errors.Is(err, ErrDuplicateKey) {
+				t.Fatalf("expected ErrDuplicateKey from UnmarshalOrdered, got: %v", err)
+			}
+		})
 
-		if err := Unmarshal([]byte(yamlStr), &res); err != nil {
-			t.Fatal(err)
-		}
+		t.Run("two templated keys collide", func(t *testing.T) {
+			input := []byte("${PREFIX}_port: 1\n${NAME:-app}_port: 2\n")
 
-		if res["val"] != "existing" {
-			t.Errorf("Expected result 'existing', got %q", res["val"])
-		}
-		if env := os.Getenv(varName); env != "existing" {
-			t.Errorf("Env var changed unexpectedly to %q", env)
-		}
-	})
+			var got map[string]int
+			err := UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: env, IgnoreExpandPaths: ignore})
+			if !errors.Is(err, ErrDuplicateKey) {
+				t.Fatalf("expected ErrDuplicateKey, got: %v", err)
+			}
+			if !strings.Contains(err.Error(), `"app_port" at line 2:1, first defined at line 1:1`) {
+				t.Fatalf("error lacks both key positions: %v", err)
+			}
+		})
+	}
 }
 
-func TestUnmarshal_TypePreservation(t *testing.T) {
-	yamlStr := `
-integer: ${INT_VAL:-42}
-float: ${FLOAT_VAL:-42.5}
-bool: ${BOOL_VAL:-true}
-string_num: "${STR_VAL:-123}"
-`
+func TestUnmarshal_NullValues(t *testing.T) {
+	input := []byte(`
+tilde: ~
+word: null
+defaulted: ${UNSET:-null}
+defaulted_tilde: ${UNSET:-~}
+empty_default: ${EMPTY:-null}
+quoted: "${UNSET:-null}"
+embedded: x${UNSET:-null}
+`)
+	env := map[string]string{"EMPTY": ""}
 
-	type Config struct {
-		Integer   int     `json:"integer"`
-		Float     float64 `json:"float"`
-		Bool      bool    `json:"bool"`
-		StringNum string  `json:"string_num"`
+	var got map[string]any
+	if err := UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: env}); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
 	}
 
-	var cfg Config
-	if err := Unmarshal([]byte(yamlStr), &cfg); err != nil {
-		t.Fatalf("Unmarshal failed: %v", err)
+	want := map[string]any{
+		"tilde":           nil,
+		"word":            nil,
+		"defaulted":       nil,
+		"defaulted_tilde": nil,
+		"empty_default":   nil,
+		"quoted":          "null",
+		"embedded":        "xnull",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
 	}
 
-	if cfg.Integer != 42 {
-		t.Errorf("Expected int 42, got %d", cfg.Integer)
+	var typed struct {
+		Tilde     *string `json:"tilde"`
+		Defaulted *int    `json:"defaulted"`
+		Quoted    *string `json:"quoted"`
 	}
-	if cfg.Float != 42.5 {
-		t.Errorf("Expected float 42.5, got %f", cfg.Float)
+	if err := UnmarshalWithOptions(input, &typed, UnmarshalOptions{Env: env}); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
 	}
-	if cfg.Bool != true {
-		t.Errorf("Expected bool true, got %v", cfg.Bool)
+	if typed.Tilde != nil || typed.Defaulted != nil || typed.Quoted == nil || *typed.Quoted != "null" {
+		t.Fatalf("typed nulls mismatch: %+v", typed)
 	}
-	if cfg.StringNum != "123" {
-		t.Errorf("Expected string '123', got %q", cfg.StringNum)
+
+	out, err := ExpandPathWithOptions(input, "", UnmarshalOptions{Env: env})
+	if err != nil {
+		t.Fatalf("ExpandPathWithOptions returned error: %v", err)
+	}
+	for _, line := range []string{"tilde: ~\n", "word: null\n", "defaulted: null\n", `quoted: "null"`} {
+		if !strings.Contains(string(out), line) {
+			t.Fatalf("output %q lacks %q", out, line)
+		}
+	}
+
+	got = nil
+	if err := UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: env, KeepStrings: true}); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
 	}
+	if got["tilde"] != nil || got["defaulted"] != "null" {
+		t.Fatalf("KeepStrings: got %#v", got)
+	}
+}
+
+func TestUnmarshalWithOptions_KeepStrings(t *testing.T) {
+	input := []byte(`
+integer: ${INT_VAL:-42}
+bool: ${BOOL_VAL:-true}
+empty: ${NULL_VAL:-null}
+literal: 7
+`)
+
+	t.Run("retype by default", func(t *testing.T) {
+		var got map[string]any
+		if err := UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: map[string]string{}}); err != nil {
+			t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+		}
+		if got["integer"] != 42 || got["bool"] != true || got["empty"] != nil {
+			t.Fatalf("expected re-typed values, got %#v", got)
+		}
+	})
+
+	t.Run("keep strings", func(t *testing.T) {
+		var got map[string]any
+		if err := UnmarshalWithOptions(input, &got, UnmarshalOptions{
+			Env:         map[string]string{},
+			KeepStrings: true,
+		}); err != nil {
+			t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+		}
+		if got["integer"] != "42" || got["bool"] != "true" || got["empty"] != "null" {
+			t.Fatalf("expected string values, got %#v", got)
+		}
+		if got["literal"] != 7 {
+			t.Fatalf("expected untouched scalar to keep native type, got %#v", got["literal"])
+		}
+	})
+
+	t.Run("keep strings into numeric field fails", func(t *testing.T) {
+		var got struct {
+			Integer int `json:"integer"`
+		}
+		err := UnmarshalWithOptions([]byte("integer: ${INT_VAL:-42}\n"), &got, UnmarshalOptions{
+			Env:         map[string]string{},
+			KeepStrings: true,
+		})
+		if err == nil {
+			t.Fatal("expected decode error for string into int field")
+		}
+	})
 }
 
 func TestUnmarshal_InfiniteLoopProtection(t *testing.T) {
@@ -225,6 +1222,188 @@ func TestUnmarshal_InfiniteLoopProtection(t *testing.T) {
 	}
 }
 
+func TestUnmarshal_BareVarInDefault(t *testing.T) {
+	tests := []struct {
+		env   map[string]string
+		name  string
+		input string
+		want  string
+	}{
+		{name: "bare default with B set", input: "${A:-$B}", env: map[string]string{"B": "bee"}, want: "bee"},
+		{name: "bare default with B unset", input: "${A:-$B}", env: map[string]string{}, want: ""},
+		{name: "A set wins", input: "${A:-$B}", env: map[string]string{"A": "a", "B": "bee"}, want: "a"},
+		{name: "text around bare var", input: "${A:-http://$HOST:80/$}", env: map[string]string{"HOST": "h"}, want: "http://h:80/$"},
+		{name: "escaped bare var stays literal", input: "${A:-$$B}", env: map[string]string{"B": "bee"}, want: "$B"},
+		{name: "digits are not names", input: "${A:-$5}", env: map[string]string{}, want: "$5"},
+		{name: "bare var outside default is literal", input: "$B-${A:-x}", env: map[string]string{"B": "bee"}, want: "$B-x"},
+		{name: "assignment default", input: "${A:=$B}", env: map[string]string{"B": "bee"}, want: "bee"},
+		{name: "bare var value is expanded", input: "${A:-$B}", env: map[string]string{"B": "${C}", "C": "sea"}, want: "sea"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandStringWithOptions(tt.input, UnmarshalOptions{Env: tt.env})
+			if err != nil {
+				t.Fatalf("ExpandStringWithOptions returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("value mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_DeepDefaultChain(t *testing.T) {
+	const depth = 15
+
+	expr := "final"
+	for i := depth; i >= 1; i-- {
+		expr = "${JAMLE_CHAIN_" + strconv.Itoa(i) + ":-" + expr + "}"
+	}
+	input := []byte("v: " + expr + "\n")
+
+	var got map[string]string
+	err := UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: map[string]string{}, MaxPasses: depth})
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+	if got["v"] != "final" {
+		t.Fatalf("deep chain mismatch: got %q, want %q", got["v"], "final")
+	}
+
+	err = UnmarshalWithOptions(input, &got, UnmarshalOptions{
+		Env:       map[string]string{"JAMLE_CHAIN_15": "inner"},
+		MaxPasses: depth,
+	})
+	if err != nil || got["v"] != "inner" {
+		t.Fatalf("innermost variable mismatch: got %q, err %v", got["v"], err)
+	}
+
+	err = UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: map[string]string{}, MaxPasses: depth - 1})
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+	if !strings.HasPrefix(got["v"], "${JAMLE_CHAIN_1:-") {
+		t.Fatalf("expected outermost placeholder to remain with too few passes, got %q", got["v"])
+	}
+}
+
+func TestUnmarshal_DefaultMaxPasses(t *testing.T) {
+	chain := func(depth int) []byte {
+		expr := "final"
+		for i := depth; i >= 1; i-- {
+			expr = "${JAMLE_CHAIN_" + strconv.Itoa(i) + ":-" + expr + "}"
+		}
+		return []byte("v: " + expr + "\n")
+	}
+
+	var got map[string]string
+	if err := UnmarshalWithOptions(chain(DefaultMaxPasses), &got, UnmarshalOptions{Env: map[string]string{}}); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+	if got["v"] != "final" {
+		t.Fatalf("chain of DefaultMaxPasses levels mismatch: got %q", got["v"])
+	}
+
+	if err := UnmarshalWithOptions(chain(DefaultMaxPasses+1), &got, UnmarshalOptions{Env: map[string]string{}}); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+	if !strings.HasPrefix(got["v"], "${JAMLE_CHAIN_1:-") {
+		t.Fatalf("expected placeholder to remain past DefaultMaxPasses, got %q", got["v"])
+	}
+}
+
+func TestUnmarshal_DeepResolvedValueChain(t *testing.T) {
+	env := map[string]string{"V15": "final"}
+	for i := 1; i < 15; i++ {
+		env["V"+strconv.Itoa(i)] = "${V" + strconv.Itoa(i+1) + "}"
+	}
+	env["SHARED"] = "${V14}-${V14}"
+
+	var got map[string]string
+	err := UnmarshalWithOptions([]byte("v: ${V1}\nshared: ${SHARED}\n"), &got, UnmarshalOptions{Env: env})
+	if err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+	if got["v"] != "final" || got["shared"] != "final-final" {
+		t.Fatalf("resolved chain mismatch: %#v", got)
+	}
+}
+
+func TestUnmarshal_ExpansionCycle(t *testing.T) {
+	tests := []struct {
+		env     map[string]string
+		name    string
+		input   string
+		wantVar string
+	}{
+		{name: "self reference", env: map[string]string{"A": "${A}"}, input: "v: ${A}\n", wantVar: "A"},
+		{name: "two variables", env: map[string]string{"A": "x${B}", "B": "${A}"}, input: "v: ${A}\n", wantVar: "A"},
+		{name: "via default", env: map[string]string{"A": "${B:-${A}}"}, input: "v: ${A}\n", wantVar: "A"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]string
+			err := UnmarshalWithOptions([]byte(tt.input), &got, UnmarshalOptions{Env: tt.env, MaxPasses: 100})
+			if !errors.Is(err, ErrExpansionCycle) {
+				t.Fatalf("expected ErrExpansionCycle, got: %v (value %q)", err, got["v"])
+			}
+
+			var expandErr *ExpandError
+			if !errors.As(err, &expandErr) || expandErr.Var != tt.wantVar {
+				t.Fatalf("expected cycle variable %q, got: %v", tt.wantVar, err)
+			}
+		})
+	}
+}
+
+func TestExpandStringWithOptions_ResolvedExpansion(t *testing.T) {
+	env := map[string]string{
+		"URL":    "https://${HOST}:${PORT:-443}",
+		"HOST":   "api.local",
+		"SECRET": "s3cr3t",
+		"INJECT": "${SECRET}",
+		"SELF":   "${SELF}",
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		literal string
+	}{
+		{name: "nested value", input: "${URL}", want: "https://api.local:443", literal: "https://${HOST}:${PORT:-443}"},
+		{name: "injected placeholder", input: "token=${INJECT}", want: "token=s3cr3t", literal: "token=${SECRET}"},
+		{name: "default still expands", input: "${MISSING:-${HOST}}", want: "api.local", literal: "api.local"},
+		{name: "default resolving to value", input: "${MISSING:-${INJECT}}", want: "s3cr3t", literal: "${SECRET}"},
+		{name: "cycle is literal", input: "${SELF}", literal: "${SELF}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandStringWithOptions(tt.input, UnmarshalOptions{Env: env, DisableResolvedExpansion: true})
+			if err != nil {
+				t.Fatalf("literal mode returned error: %v", err)
+			}
+			if got != tt.literal {
+				t.Fatalf("literal mode mismatch: got %q, want %q", got, tt.literal)
+			}
+
+			if tt.want == "" {
+				return
+			}
+			got, err = ExpandStringWithOptions(tt.input, UnmarshalOptions{Env: env})
+			if err != nil {
+				t.Fatalf("default mode returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("default mode mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestUnmarshal_DoesNotExpandInComments(t *testing.T) {
 	os.Unsetenv("COMMENT_REQ")
 
@@ -523,6 +1702,59 @@ func TestUnmarshalWithOptions_NoExpandTag_YAMLSkipDoesNotLeakPath(t *testing.T)
 	}
 }
 
+func TestUnmarshalWithOptions_EmbeddedStruct(t *testing.T) {
+	type EmbedBase struct {
+		Host   string `json:"host"`
+		Script string `json:"script" jamle:"noexpand"`
+	}
+	type EmbedMeta struct {
+		Owner string `json:"owner"`
+	}
+	type untagged struct {
+		EmbedBase
+		*EmbedMeta
+		Mode string `json:"mode"`
+	}
+	type tagged struct {
+		EmbedBase `json:"base"`
+		Mode      string `json:"mode"`
+	}
+
+	env := map[string]string{"HOST": "db", "OWNER": "ops", "MODE": "prod"}
+
+	t.Run("untagged", func(t *testing.T) {
+		input := []byte("host: ${HOST}\nscript: echo ${HOST}\nowner: ${OWNER}\nmode: ${MODE}\n")
+
+		var got untagged
+		if err := UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: env}); err != nil {
+			t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+		}
+
+		want := untagged{
+			EmbedBase: EmbedBase{Host: "db", Script: "echo ${HOST}"},
+			EmbedMeta: &EmbedMeta{Owner: "ops"},
+			Mode:      "prod",
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("decoded mismatch: got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("tagged", func(t *testing.T) {
+		input := []byte("base:\n  host: ${HOST}\n  script: echo ${HOST}\nmode: ${MODE}\n")
+
+		var got tagged
+		if err := UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: env}); err != nil {
+			t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+		}
+
+		want := tagged{EmbedBase: EmbedBase{Host: "db", Script: "echo ${HOST}"}, Mode: "prod"}
+		if got != want {
+			t.Fatalf("decoded mismatch: got %+v, want %+v", got, want)
+		}
+	})
+}
+
 func TestCompilePathRules_CanonicalDedupe(t *testing.T) {
 	rules := compilePathRules([]string{
 		"spec.hooks[0].script",
@@ -569,6 +1801,15 @@ func TestUnmarshalWithOptions_NegativeTable(t *testing.T) {
 			},
 			wantErr: ErrAssignmentUnsupported.Error(),
 		},
+		{
+			name:   "unsupported transform operator",
+			input:  []byte("a: ${A@Z}\n"),
+			target: &cfg{},
+			opts: UnmarshalOptions{
+				Resolver: mapResolver{values: map[string]string{"A": "value"}},
+			},
+			wantErr: ErrUnsupportedTransform.Error(),
+		},
 		{
 			name:   "invalid yaml input",
 			input:  []byte("{a: 1"),
@@ -755,3 +1996,573 @@ func TestUnmarshal_JSONInputWithUnquotedPlaceholdersFails(t *testing.T) {
 		t.Fatal("expected parse error for unquoted placeholder in JSON")
 	}
 }
+
+func TestUnmarshalWithOptions_Env(t *testing.T) {
+	type cfg struct {
+		A string `json:"a"`
+		B string `json:"b"`
+	}
+
+	t.Run("lookup from map only", func(t *testing.T) {
+		t.Setenv("JAMLE_ENV_MAP_B", "from-process")
+
+		var got cfg
+		err := UnmarshalWithOptions([]byte("a: ${JAMLE_ENV_MAP_A}\nb: ${JAMLE_ENV_MAP_B:-fallback}\n"), &got, UnmarshalOptions{
+			Env:      map[string]string{"JAMLE_ENV_MAP_A": "from-map"},
+			Resolver: mapResolver{values: map[string]string{"JAMLE_ENV_MAP_B": "from-resolver"}},
+		})
+		if err != nil {
+			t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+		}
+		if got.A != "from-map" || got.B != "fallback" {
+			t.Fatalf("decoded config mismatch: %#v", got)
+		}
+	})
+
+	t.Run("map is never modified", func(t *testing.T) {
+		env := map[string]string{}
+
+		var got cfg
+		err := UnmarshalWithOptions([]byte("a: ${A:=value}\n"), &got, UnmarshalOptions{
+			Env: env,
+		})
+		if err != nil {
+			t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+		}
+		if got.A != "value" {
+			t.Fatalf("decoded value mismatch: got %q, want %q", got.A, "value")
+		}
+		if len(env) != 0 {
+			t.Fatalf("expected env map to stay empty, got %#v", env)
+		}
+	})
+
+	t.Run("assignment is shared across scalars in document order", func(t *testing.T) {
+		const key = "JAMLE_ENV_SHARED"
+		_ = os.Unsetenv(key)
+
+		var got cfg
+		err := UnmarshalWithOptions([]byte("a: ${JAMLE_ENV_SHARED:=computed}\nb: ${JAMLE_ENV_SHARED:-unused}-suffix\n"), &got, UnmarshalOptions{
+			Env: map[string]string{},
+		})
+		if err != nil {
+			t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+		}
+		if got.A != "computed" || got.B != "computed-suffix" {
+			t.Fatalf("decoded config mismatch: %#v", got)
+		}
+		if _, exists := os.LookupEnv(key); exists {
+			t.Fatalf("expected %s to stay unset in process environment", key)
+		}
+	})
+
+	t.Run("assignment is not shared across calls", func(t *testing.T) {
+		env := map[string]string{}
+
+		var first, second cfg
+		if err := UnmarshalWithOptions([]byte("a: ${A:=first}\n"), &first, UnmarshalOptions{Env: env}); err != nil {
+			t.Fatalf("first call returned error: %v", err)
+		}
+		if err := UnmarshalWithOptions([]byte("a: ${A:-unset}\n"), &second, UnmarshalOptions{Env: env}); err != nil {
+			t.Fatalf("second call returned error: %v", err)
+		}
+		if first.A != "first" || second.A != "unset" {
+			t.Fatalf("assignment leaked between calls: first=%q second=%q", first.A, second.A)
+		}
+	})
+
+	t.Run("assignment is shared across documents", func(t *testing.T) {
+		var got []cfg
+		err := UnmarshalAllWithOptions([]byte("---\na: ${A:=first}\n---\na: ${A}\n"), &got, UnmarshalOptions{
+			Env: map[string]string{},
+		})
+		if err != nil {
+			t.Fatalf("UnmarshalAllWithOptions returned error: %v", err)
+		}
+		if len(got) != 2 || got[1].A != "first" {
+			t.Fatalf("assignment not visible in second document: %#v", got)
+		}
+	})
+}
+
+func TestUnmarshalWithOptions_EnvConcurrentIsolation(t *testing.T) {
+	type cfg struct {
+		ID   string `json:"id"`
+		Host string `json:"host"`
+	}
+
+	const workers = 32
+	input := []byte("id: ${ID}\nhost: ${HOST:-host-${ID}}\n")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+
+	for i := range workers {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+
+			for range 50 {
+				var got cfg
+				err := UnmarshalWithOptions(input, &got, UnmarshalOptions{
+					Env: map[string]string{"ID": id},
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+				if got.ID != id || got.Host != "host-"+id {
+					errs <- fmt.Errorf("isolation broken for %s: %#v", id, got)
+					return
+				}
+			}
+		}(strconv.Itoa(i))
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestUnmarshalWithOptions_OnlyVars(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{name: "allowed variable expands", yaml: `a: "${HOST}"`, want: "svc.local"},
+		{name: "other variable stays literal", yaml: `a: "${PORT}"`, want: "${PORT}"},
+		{name: "operators are kept", yaml: `a: "${PORT:-8080}"`, want: "${PORT:-8080}"},
+		{name: "nested content is kept", yaml: `a: "${PORT:-${HOST}}"`, want: "${PORT:-${HOST}}"},
+		{name: "deferred inside allowed default", yaml: `a: "${MISSING_HOST:-${PORT:?required}}"`, want: "${PORT:?required}"},
+		{name: "mixed in one scalar", yaml: `a: "${HOST}:${PORT:-80}"`, want: "svc.local:${PORT:-80}"},
+		{name: "allowed default keeps deferred", yaml: `a: "${REGION:-${ZONE}-1}"`, want: "${ZONE}-1"},
+		{name: "escaping still works", yaml: `a: "$${HOST}"`, want: "${HOST}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]string
+			err := UnmarshalWithOptions([]byte(tt.yaml), &got, UnmarshalOptions{
+				Env:      map[string]string{"HOST": "svc.local", "PORT": "9000"},
+				OnlyVars: []string{"HOST", "REGION", "MISSING_HOST"},
+			})
+			if err != nil {
+				t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+			}
+			if got["a"] != tt.want {
+				t.Fatalf("value mismatch: got %q, want %q", got["a"], tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalWithOptions_SetOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{name: "set variable expands", yaml: `a: "${HOST}"`, want: "svc.local"},
+		{name: "set variable ignores default", yaml: `a: "${HOST:-localhost}"`, want: "svc.local"},
+		{name: "unset variable stays literal", yaml: `a: "${PORT}"`, want: "${PORT}"},
+		{name: "unset default is deferred", yaml: `a: "${PORT:-8080}"`, want: "${PORT:-8080}"},
+		{name: "unset required is deferred", yaml: `a: "${PORT:?required}"`, want: "${PORT:?required}"},
+		{name: "unset keeps nested content", yaml: `a: "${PORT:-${HOST}}"`, want: "${PORT:-${HOST}}"},
+		{name: "set default resolves nested", yaml: `a: "${EMPTY:-${HOST}}"`, want: "svc.local"},
+		{name: "mixed in one scalar", yaml: `a: "${HOST}:${PORT:-80}"`, want: "svc.local:${PORT:-80}"},
+		{name: "composed name", yaml: `a: "${HOST_${REGION}}"`, want: "eu.svc.local"},
+		{name: "escaping still works", yaml: `a: "$${HOST}"`, want: "${HOST}"},
+	}
+
+	env := map[string]string{"HOST": "svc.local", "EMPTY": "", "REGION": "EU", "HOST_EU": "eu.svc.local"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]string
+			err := UnmarshalWithOptions([]byte(tt.yaml), &got, UnmarshalOptions{Env: env, SetOnly: true})
+			if err != nil {
+				t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+			}
+			if got["a"] != tt.want {
+				t.Fatalf("value mismatch: got %q, want %q", got["a"], tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandStringWithOptions_InterpretEscapes(t *testing.T) {
+	env := map[string]string{"RAW": `a\nb`}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		escapes bool
+	}{
+		{name: "newline", input: `${MSG:-line1\nline2}`, escapes: true, want: "line1\nline2"},
+		{name: "tab", input: `${MSG:-a\tb}`, escapes: true, want: "a\tb"},
+		{name: "backslash", input: `${MSG:-C:\\dir}`, escapes: true, want: `C:\dir`},
+		{name: "escaped escape", input: `${MSG:-a\\nb}`, escapes: true, want: `a\nb`},
+		{name: "unknown kept", input: `${MSG:-a\qb}`, escapes: true, want: `a\qb`},
+		{name: "assign", input: `${MSG:=a\tb}`, escapes: true, want: "a\tb"},
+		{name: "variable value untouched", input: `${RAW:-x\ty}`, escapes: true, want: `a\nb`},
+		{name: "disabled", input: `${MSG:-line1\nline2}`, want: `line1\nline2`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := UnmarshalOptions{Env: env, InterpretEscapes: tt.escapes, DisableAssignment: true}
+			got, err := ExpandStringWithOptions(tt.input, opts)
+			if err != nil {
+				t.Fatalf("ExpandStringWithOptions returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("value mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalWithOptions_SplitDirective(t *testing.T) {
+	env := map[string]string{
+		"HOSTS":    "a.local,b.local",
+		"SPACED":   "  one two\tthree ",
+		"TRAILING": "a,b,,",
+		"EMPTY":    "",
+		"PORTS":    "80;443",
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		opts  UnmarshalOptions
+		want  any
+	}{
+		{name: "comma", input: "${HOSTS|split:,}", want: []any{"a.local", "b.local"}},
+		{name: "whitespace", input: "${SPACED|split}", want: []any{"one", "two", "three"}},
+		{name: "default", input: "${MISSING:-x, y ,z|split:,}", want: []any{"x", "y", "z"}},
+		{name: "trailing separators", input: "${TRAILING|split:,}", want: []any{"a", "b"}},
+		{name: "empty list", input: "${EMPTY|split:,}", want: []any{}},
+		{name: "unset list", input: "${MISSING|split}", want: []any{}},
+		{name: "typed items", input: "${PORTS|split:;}", want: []any{80, 443}},
+		{name: "keep strings", input: "${PORTS|split:;}", opts: UnmarshalOptions{KeepStrings: true}, want: []any{"80", "443"}},
+		{name: "quoted", input: `"${HOSTS|split:,}"`, want: []any{"a.local", "b.local"}},
+		{name: "deferred", input: "${HOSTS|split:,}", opts: UnmarshalOptions{OnlyVars: []string{"OTHER"}}, want: "${HOSTS|split:,}"},
+		{name: "default with pipe", input: "${MISSING:-a|b}", want: "a|b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Env = env
+
+			var got map[string]any
+			if err := UnmarshalWithOptions([]byte("v: "+tt.input+"\n"), &got, tt.opts); err != nil {
+				t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+			}
+			if !reflect.DeepEqual(got["v"], tt.want) {
+				t.Fatalf("value mismatch: got %#v, want %#v", got["v"], tt.want)
+			}
+		})
+	}
+
+	var cfg struct {
+		Hosts []string `json:"hosts"`
+	}
+	if err := UnmarshalWithOptions([]byte("hosts: ${HOSTS|split:,}\n"), &cfg, UnmarshalOptions{Env: env}); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+	if want := []string{"a.local", "b.local"}; !reflect.DeepEqual(cfg.Hosts, want) {
+		t.Fatalf("struct mismatch: got %#v, want %#v", cfg.Hosts, want)
+	}
+}
+
+func TestUnmarshalWithOptions_UnsetErrorFormat(t *testing.T) {
+	env := map[string]string{"EMPTY": ""}
+	format := "config error: %s is required, see https://runbook.example/config#%s"
+
+	tests := []struct {
+		name   string
+		input  string
+		format string
+		want   string
+	}{
+		{name: "custom unset", input: "v: ${DB_URL:?}", format: format, want: "config error: DB_URL is required, see https://runbook.example/config#DB_URL"},
+		{name: "custom empty", input: "v: ${EMPTY:?}", format: format, want: "config error: EMPTY is required, see https://runbook.example/config#EMPTY"},
+		{name: "custom no colon", input: "v: ${DB_URL?}", format: format, want: "config error: DB_URL is required, see https://runbook.example/config#DB_URL"},
+		{name: "own message wins", input: "v: ${DB_URL:?set DB_URL}", format: format, want: `environment variable "DB_URL" set DB_URL`},
+		{name: "default phrasing", input: "v: ${DB_URL:?}", want: `environment variable "DB_URL" is not set or empty`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]string
+			err := UnmarshalWithOptions([]byte(tt.input), &got, UnmarshalOptions{Env: env, UnsetErrorFormat: tt.format})
+
+			var expandErr *ExpandError
+			if !errors.As(err, &expandErr) {
+				t.Fatalf("expected *ExpandError, got %v", err)
+			}
+			if msg := expandErr.Err.Error(); msg != tt.want {
+				t.Fatalf("message mismatch:\ngot  %q\nwant %q", msg, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalWithOptions_WindowsVars(t *testing.T) {
+	env := map[string]string{
+		"USERPROFILE": `C:\Users\app`,
+		"HOST":        "db.local",
+		"RAW":         "%HOST%",
+		"EMPTY":       "",
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		want     string
+		disabled bool
+		onlyVars []string
+	}{
+		{name: "set", input: `%USERPROFILE%\config`, want: `C:\Users\app\config`},
+		{name: "unset stays literal", input: "%MISSING%/x", want: "%MISSING%/x"},
+		{name: "empty", input: "[%EMPTY%]", want: "[]"},
+		{name: "mixed with braces", input: "${HOST}:%HOST%", want: "db.local:db.local"},
+		{name: "inside default", input: "${MISSING:-%HOST%}", want: "db.local"},
+		{name: "value not rescanned", input: "%RAW%", want: "%HOST%"},
+		{name: "not a name", input: "50% of %HOST%", want: "50% of db.local"},
+		{name: "single percent", input: "100%", want: "100%"},
+		{name: "escaped braces", input: "$${HOST} %HOST%", want: "${HOST} db.local"},
+		{name: "deferred", input: "%HOST%", onlyVars: []string{"OTHER"}, want: "%HOST%"},
+		{name: "disabled", input: "%HOST%", disabled: true, want: "%HOST%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := UnmarshalOptions{Env: env, WindowsVars: !tt.disabled, OnlyVars: tt.onlyVars, KeepStrings: true}
+
+			var got map[string]string
+			input, _ := json.Marshal(map[string]string{"v": tt.input})
+			if err := UnmarshalWithOptions(input, &got, opts); err != nil {
+				t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+			}
+			if got["v"] != tt.want {
+				t.Fatalf("value mismatch: got %q, want %q", got["v"], tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandStringWithOptions_ComposedNames(t *testing.T) {
+	env := map[string]string{
+		"REGION":     "US",
+		"PREFIX_US":  "us-endpoint",
+		"PREFIX_EU":  "eu-endpoint",
+		"TIER":       "PROD",
+		"DB_US_PROD": "db-us-prod",
+		"BAD":        "us-east",
+		"OP":         ":-x",
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "composed", input: "${PREFIX_${REGION}}", want: "us-endpoint"},
+		{name: "two parts", input: "${DB_${REGION}_${TIER}}", want: "db-us-prod"},
+		{name: "default inner", input: "${PREFIX_${ZONE:-EU}}", want: "eu-endpoint"},
+		{name: "default outer", input: "${PREFIX_${TIER}:-none}", want: "none"},
+		{name: "whole name", input: "${${TIER_NAME:-REGION}}", want: "US"},
+		{name: "value outside name", input: "${REGION}-${BAD}", want: "US-us-east"},
+		{name: "malformed composed name", input: "${PREFIX_${BAD}}", wantErr: true},
+		{name: "operator injection", input: "${PREFIX${OP}}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandStringWithOptions(tt.input, UnmarshalOptions{Env: env})
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidVariableName) {
+					t.Fatalf("expected ErrInvalidVariableName, got %q, %v", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandStringWithOptions returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("value mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandStringWithOptions_LazyOperands(t *testing.T) {
+	env := map[string]string{"A": "set", "EMPTY": ""}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "unused default skips required", input: "${A:-${B:?required}}", want: "set"},
+		{name: "unused assign default skips required", input: "${A:=${B:?required}}", want: "set"},
+		{name: "unused alternate skips required", input: "${UNSET:+${B:?required}}", want: ""},
+		{name: "unused message skips required", input: "${A:?${B:?required}}", want: "set"},
+		{name: "unused default does not assign", input: "${A:-${C:=assigned}}[${C}]", want: "set[]"},
+		{name: "used default runs required", input: "${UNSET:-${B:?required}}", wantErr: true},
+		{name: "empty uses default", input: "${EMPTY:-${B:?required}}", wantErr: true},
+		{name: "used alternate runs required", input: "${A:+${B:?required}}", wantErr: true},
+		{name: "used default resolves", input: "${UNSET:-${A}}", want: "set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandStringWithOptions(tt.input, UnmarshalOptions{Env: env})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandStringWithOptions returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("value mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_DefaultFallsBackToRequired(t *testing.T) {
+	input := []byte("db: ${PRIMARY:-${FALLBACK:?need a fallback}}\n")
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{name: "both set", env: map[string]string{"PRIMARY": "p", "FALLBACK": "f"}, want: "p"},
+		{name: "primary only", env: map[string]string{"PRIMARY": "p"}, want: "p"},
+		{name: "fallback only", env: map[string]string{"FALLBACK": "f"}, want: "f"},
+		{name: "both missing", env: map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]string
+			err := UnmarshalWithOptions(input, &got, UnmarshalOptions{Env: tt.env})
+			if tt.want == "" {
+				var expandErr *ExpandError
+				if !errors.As(err, &expandErr) || expandErr.Var != "FALLBACK" || !strings.Contains(err.Error(), "need a fallback") {
+					t.Fatalf("expected ExpandError for FALLBACK, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+			}
+			if got["db"] != tt.want {
+				t.Fatalf("got %q, want %q", got["db"], tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandStringWithOptions_ErrorOnUnset(t *testing.T) {
+	opts := UnmarshalOptions{Env: map[string]string{"EMPTY": "", "SET": "value"}, ErrorOnUnset: true}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain unset", input: "${MISSING}", wantErr: true},
+		{name: "empty colon default is plain", input: "${MISSING:}", wantErr: true},
+		{name: "explicit empty default", input: "${MISSING:-}", want: ""},
+		{name: "explicit empty default without colon", input: "${MISSING-}", want: ""},
+		{name: "alternate", input: "${MISSING:+alt}", want: ""},
+		{name: "nested default", input: "${MISSING:-${SET}}", want: "value"},
+		{name: "nested unset in default", input: "${MISSING:-${OTHER}}", wantErr: true},
+		{name: "set but empty", input: "[${EMPTY}]", want: "[]"},
+		{name: "set", input: "${SET}", want: "value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandStringWithOptions(tt.input, opts)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnsetVariable) {
+					t.Fatalf("expected ErrUnsetVariable, got %q, %v", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandStringWithOptions returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("value mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	got, err := ExpandStringWithOptions("${MISSING}", UnmarshalOptions{Env: map[string]string{}})
+	if err != nil || got != "" {
+		t.Fatalf("without ErrorOnUnset unset must expand to empty, got %q, %v", got, err)
+	}
+}
+
+func TestUnmarshal_DisableKeyExpansion(t *testing.T) {
+	env := map[string]string{"REGION": "eu"}
+	data := []byte("${REGION}_host: db.${REGION}.local\nnested:\n  ${REGION}: x\n")
+
+	tests := []struct {
+		name string
+		opts UnmarshalOptions
+		want map[string]any
+	}{
+		{
+			name: "keys expanded by default",
+			opts: UnmarshalOptions{Env: env},
+			want: map[string]any{"eu_host": "db.eu.local", "nested": map[string]any{"eu": "x"}},
+		},
+		{
+			name: "literal keys",
+			opts: UnmarshalOptions{Env: env, DisableKeyExpansion: true},
+			want: map[string]any{"${REGION}_host": "db.eu.local", "nested": map[string]any{"${REGION}": "x"}},
+		},
+		{
+			name: "literal keys with path tracking",
+			opts: UnmarshalOptions{Env: env, DisableKeyExpansion: true, IgnoreExpandPaths: []string{"other"}},
+			want: map[string]any{"${REGION}_host": "db.eu.local", "nested": map[string]any{"${REGION}": "x"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]any
+			if err := UnmarshalWithOptions(data, &got, tt.opts); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	out, err := ExpandJSONWithOptions([]byte(`{"${REGION}": "${REGION}"}`), UnmarshalOptions{Env: env, DisableKeyExpansion: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != `{"${REGION}":"eu"}` {
+		t.Fatalf("got %s", out)
+	}
+}
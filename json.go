@@ -0,0 +1,280 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	jyaml "github.com/woozymasta/jamle/yaml"
+)
+
+// jsonFrame tracks one open JSON object or array while re-emitting tokens.
+// keys records whether each seen key was changed by expansion.
+type jsonFrame struct {
+	keys   map[string]bool
+	key    string
+	count  int
+	object bool
+}
+
+// ExpandJSON expands ${...} placeholders in JSON string keys and values and
+// re-emits compact JSON. Numbers are copied verbatim, so large integers keep
+// their precision, and object key order is preserved.
+func ExpandJSON(data []byte) ([]byte, error) {
+	return ExpandJSONWithOptions(data, UnmarshalOptions{})
+}
+
+// ExpandJSONWithOptions expands ${...} in JSON strings using configured options.
+// Expanded strings stay JSON strings; YAML-specific options such as
+// ParseExpandedYAML and KeepStrings do not apply. Multiple top-level values
+// are emitted one per line.
+func ExpandJSONWithOptions(data []byte, opts UnmarshalOptions) ([]byte, error) {
+	if err := CheckText(data); err != nil {
+		return nil, err
+	}
+
+	runtime := resolveOptions(opts, nil)
+	out, err := expandJSON(data, runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	return applyTrailingNewline(out, runtime.trailingNewline), nil
+}
+
+// UnmarshalJSONStrict expands ${...} in JSON strings and decodes data with
+// encoding/json. No YAML rules apply, so a key "on" or a value "no" stay
+// exactly as written.
+func UnmarshalJSONStrict(data []byte, v any) error {
+	return UnmarshalJSONStrictWithOptions(data, v, UnmarshalOptions{})
+}
+
+// UnmarshalJSONStrictWithOptions is UnmarshalJSONStrict with configured
+// options. Like ExpandJSONWithOptions, expanded strings stay JSON strings,
+// and `jamle:"noexpand"` fields of v are honored.
+func UnmarshalJSONStrictWithOptions(data []byte, v any, opts UnmarshalOptions) error {
+	if err := CheckText(data); err != nil {
+		return err
+	}
+
+	expanded, err := expandJSON(data, resolveOptions(opts, reflect.TypeOf(v)))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(expanded, v)
+}
+
+// ToJSON expands data and encodes the result as JSON. The jamle CLI renders
+// JSON output of single YAML and JSON documents through it. Input that is
+// valid JSON is expanded with ExpandJSON and keeps exact numbers; anything
+// else is decoded as YAML. Object keys are sorted. A non-empty indent
+// pretty-prints with that indent per level and adds a trailing newline; an
+// empty indent yields compact JSON.
+func ToJSON(data []byte, indent string) ([]byte, error) {
+	return ToJSONWithOptions(data, indent, UnmarshalOptions{})
+}
+
+// ToJSONWithOptions is ToJSON with configured options.
+func ToJSONWithOptions(data []byte, indent string, opts UnmarshalOptions) ([]byte, error) {
+	return ToJSONAs(data, jyaml.FormatAuto, indent, opts)
+}
+
+// ToJSONAs is ToJSONWithOptions with the input format given by the caller.
+// FormatJSON and FormatYAML skip content sniffing, so JSON-looking input can
+// still be decoded as YAML; FormatAuto sniffs like ToJSON.
+func ToJSONAs(data []byte, format jyaml.Format, indent string, opts UnmarshalOptions) ([]byte, error) {
+	if err := CheckText(data); err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case jyaml.FormatAuto, "":
+		trimmed := bytes.TrimSpace(data)
+		format = jyaml.FormatYAML
+		if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+			format = jyaml.FormatJSON
+		}
+	case jyaml.FormatJSON, jyaml.FormatYAML:
+	default:
+		return nil, fmt.Errorf("%w: %q", jyaml.ErrInvalidFormat, format)
+	}
+
+	var out any
+	if format == jyaml.FormatJSON {
+		expanded, err := expandJSON(data, resolveOptions(opts, nil))
+		if err != nil {
+			return nil, err
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(expanded))
+		dec.UseNumber()
+		if err := dec.Decode(&out); err != nil {
+			return nil, err
+		}
+		if dec.More() {
+			return nil, jyaml.ErrJSONTrailingData
+		}
+	} else if err := UnmarshalWithOptions(data, &out, opts); err != nil {
+		return nil, err
+	}
+
+	raw, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	if indent == "" {
+		return applyTrailingNewline(raw, opts.TrailingNewline), nil
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", indent); err != nil {
+		return nil, err
+	}
+
+	buf.WriteByte('\n')
+	return applyTrailingNewline(buf.Bytes(), opts.TrailingNewline), nil
+}
+
+// ToCanonicalJSON expands data and encodes it as deterministic JSON for
+// golden-file tests: object keys are sorted by byte order at every level,
+// nesting is indented by two spaces and the output ends with one newline.
+// TrailingNewline in opts is ignored.
+func ToCanonicalJSON(data []byte, opts UnmarshalOptions) ([]byte, error) {
+	opts.TrailingNewline = nil
+	return ToJSONWithOptions(data, "  ", opts)
+}
+
+// expandJSON re-emits the JSON token stream of data with expanded strings.
+func expandJSON(data []byte, runtime runtimeOptions) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var out bytes.Buffer
+	out.Grow(len(data))
+
+	var stack []*jsonFrame
+	topLevel := 0
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			out.WriteByte(byte(delim))
+			continue
+		}
+
+		var top *jsonFrame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		isKey := top != nil && top.object && top.count%2 == 0
+		switch {
+		case top == nil:
+			if topLevel > 0 {
+				out.WriteByte('\n')
+			}
+			topLevel++
+		case top.object && !isKey:
+			out.WriteByte(':')
+		case top.count > 0:
+			out.WriteByte(',')
+		}
+		if top != nil {
+			top.count++
+		}
+
+		switch t := tok.(type) {
+		case json.Delim:
+			out.WriteByte(byte(t))
+			stack = append(stack, &jsonFrame{object: t == '{', keys: make(map[string]bool)})
+
+		case string:
+			path := jsonFramesPath(stack, isKey, t)
+			value := t
+			if !(isKey && runtime.keepKeys) && !shouldIgnorePath(path, runtime.ignorePathRules) {
+				if value, err = expandEnvInScalar(t, runtime); err != nil {
+					return nil, err
+				}
+			}
+
+			if isKey {
+				expanded := value != t
+				if firstExpanded, dup := top.keys[value]; dup {
+					if expanded || firstExpanded {
+						return nil, fmt.Errorf("%w %q at offset %d", ErrDuplicateKey, value, dec.InputOffset())
+					}
+
+					return nil, fmt.Errorf("duplicate mapping key %q at offset %d", value, dec.InputOffset())
+				}
+				top.keys[value] = expanded
+				top.key = value
+			}
+
+			if err := writeJSONString(&out, value); err != nil {
+				return nil, err
+			}
+
+		case json.Number:
+			out.WriteString(t.String())
+
+		case bool:
+			fmt.Fprintf(&out, "%t", t)
+
+		case nil:
+			out.WriteString("null")
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return out.Bytes(), nil
+}
+
+// jsonFramesPath returns the key path of the current string token for
+// IgnoreExpandPaths matching. Keys are matched by their raw value.
+func jsonFramesPath(stack []*jsonFrame, isKey bool, raw string) []string {
+	path := make([]string, 0, len(stack))
+	for i, frame := range stack {
+		switch {
+		case !frame.object:
+			path = append(path, "*")
+		case i == len(stack)-1 && isKey:
+			path = append(path, raw)
+		default:
+			path = append(path, frame.key)
+		}
+	}
+
+	return path
+}
+
+// writeJSONString writes s as a JSON string without HTML escaping.
+func writeJSONString(out *bytes.Buffer, s string) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+
+	out.WriteString(strings.TrimSuffix(buf.String(), "\n"))
+	return nil
+}
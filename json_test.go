@@ -0,0 +1,291 @@
+package jamle
+
+import (
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	jyaml "github.com/woozymasta/jamle/yaml"
+)
+
+func TestExpandJSONWithOptions(t *testing.T) {
+	env := map[string]string{"HOST": "db", "PORT": "5432", "PREFIX": "app"}
+
+	tests := []struct {
+		name   string
+		input  string
+		want   string
+		ignore []string
+	}{
+		{
+			name:  "big integer keeps precision",
+			input: `{"id": 12345678901234567890, "ratio": 1.50e3, "host": "${HOST}"}`,
+			want:  `{"id":12345678901234567890,"ratio":1.50e3,"host":"db"}`,
+		},
+		{
+			name:  "strings stay strings and key order is kept",
+			input: `{"z": "${PORT}", "a": ["${HOST}", true, null, -0.5], "${PREFIX}_name": "x"}`,
+			want:  `{"z":"5432","a":["db",true,null,-0.5],"app_name":"x"}`,
+		},
+		{
+			name:  "escapes and html characters",
+			input: `{"tpl": "$${HOST} <${HOST}> & \"q\""}`,
+			want:  `{"tpl":"${HOST} <db> & \"q\""}`,
+		},
+		{
+			name:   "ignore paths",
+			input:  `{"keep": {"cmd": "${HOST}"}, "list": [{"cmd": "${HOST}"}], "cmd": "${HOST}"}`,
+			want:   `{"keep":{"cmd":"${HOST}"},"list":[{"cmd":"${HOST}"}],"cmd":"db"}`,
+			ignore: []string{"keep.cmd", "list.*.cmd"},
+		},
+		{
+			name:  "top-level stream",
+			input: "[1] \"${HOST}\"",
+			want:  "[1]\n\"db\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandJSONWithOptions([]byte(tt.input), UnmarshalOptions{Env: env, IgnoreExpandPaths: tt.ignore})
+			if err != nil {
+				t.Fatalf("ExpandJSONWithOptions returned error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("output mismatch:\ngot  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandJSONWithOptions_Errors(t *testing.T) {
+	env := map[string]string{"KEY": "a"}
+
+	for _, input := range []string{`{"a": 1, "${KEY}": 2}`, `{"${KEY}": 1, "a": 2}`} {
+		_, err := ExpandJSONWithOptions([]byte(input), UnmarshalOptions{Env: env})
+		if !errors.Is(err, ErrDuplicateKey) {
+			t.Fatalf("%s: expected ErrDuplicateKey, got %v", input, err)
+		}
+	}
+
+	// A key duplicated in the source is not an expansion collision.
+	_, err := ExpandJSONWithOptions([]byte(`{"a": 1, "a": 2}`), UnmarshalOptions{Env: env})
+	if err == nil || errors.Is(err, ErrDuplicateKey) || !strings.Contains(err.Error(), `duplicate mapping key "a"`) {
+		t.Fatalf("expected plain duplicate key error, got %v", err)
+	}
+
+	_, err = ExpandJSONWithOptions([]byte(`{"a": "${MISSING:?required}"}`), UnmarshalOptions{Env: env})
+	var expandErr *ExpandError
+	if !errors.As(err, &expandErr) || expandErr.Var != "MISSING" {
+		t.Fatalf("expected ExpandError for MISSING, got %v", err)
+	}
+
+	if _, err := ExpandJSON([]byte(`{"a": `)); err == nil {
+		t.Fatal("expected error for truncated JSON")
+	}
+}
+
+func TestUnmarshalJSONStrict(t *testing.T) {
+	var generic map[string]any
+	if err := UnmarshalJSONStrict([]byte(`{"on": "no", "off": "${UNSET_FLAG:-yes}"}`), &generic); err != nil {
+		t.Fatalf("UnmarshalJSONStrict returned error: %v", err)
+	}
+	if generic["on"] != "no" || generic["off"] != "yes" {
+		t.Fatalf("strict JSON mismatch: %#v", generic)
+	}
+
+	type config struct {
+		Host   string `json:"host"`
+		Port   int    `json:"port"`
+		Script string `json:"script" jamle:"noexpand"`
+	}
+
+	var cfg config
+	err := UnmarshalJSONStrictWithOptions(
+		[]byte(`{"host": "${HOST}", "port": 8080, "script": "echo ${HOME}"}`),
+		&cfg,
+		UnmarshalOptions{Env: map[string]string{"HOST": "db"}},
+	)
+	if err != nil {
+		t.Fatalf("UnmarshalJSONStrictWithOptions returned error: %v", err)
+	}
+	if cfg != (config{Host: "db", Port: 8080, Script: "echo ${HOME}"}) {
+		t.Fatalf("struct mismatch: %+v", cfg)
+	}
+
+	if err := UnmarshalJSONStrict([]byte("on: no\n"), &generic); err == nil {
+		t.Fatal("expected error for YAML input")
+	}
+}
+
+func TestToJSONWithOptions(t *testing.T) {
+	opts := UnmarshalOptions{Env: map[string]string{"PORT": "8080", "HOST": "db"}}
+
+	tests := []struct {
+		name   string
+		input  string
+		indent string
+		want   string
+	}{
+		{
+			name:   "yaml pretty",
+			input:  "server:\n  port: ${PORT}\n  host: ${HOST}\n",
+			indent: "  ",
+			want:   "{\n  \"server\": {\n    \"host\": \"db\",\n    \"port\": 8080\n  }\n}\n",
+		},
+		{
+			name:  "yaml compact",
+			input: "list:\n  - ${HOST}\n  - 1\n",
+			want:  `{"list":["db",1]}`,
+		},
+		{
+			name:   "json keeps exact numbers",
+			input:  `{"id": 12345678901234567890, "host": "${HOST}"}`,
+			indent: "\t",
+			want:   "{\n\t\"host\": \"db\",\n\t\"id\": 12345678901234567890\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToJSONWithOptions([]byte(tt.input), tt.indent, opts)
+			if err != nil {
+				t.Fatalf("ToJSONWithOptions returned error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("output mismatch:\ngot  %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ToJSON([]byte("a: ${MISSING:?required}\n"), ""); err == nil {
+		t.Fatal("expected required error")
+	}
+}
+
+func TestToJSONAs(t *testing.T) {
+	input := []byte(`{"n": 1.0, "e": 1e3}`)
+
+	tests := []struct {
+		format jyaml.Format
+		input  []byte
+		want   string
+		err    error
+	}{
+		{format: jyaml.FormatAuto, input: input, want: `{"e":1e3,"n":1.0}`},
+		{format: jyaml.FormatJSON, input: input, want: `{"e":1e3,"n":1.0}`},
+		{format: jyaml.FormatYAML, input: input, want: `{"e":1000,"n":1}`},
+		{format: jyaml.FormatJSON, input: []byte(`"x"`), want: `"x"`},
+		{format: jyaml.FormatJSON, input: []byte(`{}{}`), err: jyaml.ErrJSONTrailingData},
+		{format: "toml", input: input, err: jyaml.ErrInvalidFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format)+" "+string(tt.input), func(t *testing.T) {
+			got, err := ToJSONAs(tt.input, tt.format, "", UnmarshalOptions{})
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ToJSONAs returned error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("output mismatch:\ngot  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToCanonicalJSON(t *testing.T) {
+	no := false
+	opts := UnmarshalOptions{
+		Env:             map[string]string{"HOST": "db", "PORT": "8080"},
+		TrailingNewline: &no,
+	}
+	lines := []string{
+		"zeta: ${HOST}",
+		"alpha: 1",
+		"Beta: true",
+		"nested: {y: '${PORT}', b: [2, {d: 1, c: 0}], a: null}",
+		"_under: x",
+		"mid: ${MISSING:-fallback}",
+	}
+	want := `{
+  "Beta": true,
+  "_under": "x",
+  "alpha": 1,
+  "mid": "fallback",
+  "nested": {
+    "a": null,
+    "b": [
+      2,
+      {
+        "c": 0,
+        "d": 1
+      }
+    ],
+    "y": "8080"
+  },
+  "zeta": "db"
+}
+`
+
+	for range 20 {
+		rand.Shuffle(len(lines), func(i, j int) { lines[i], lines[j] = lines[j], lines[i] })
+		input := strings.Join(lines, "\n") + "\n"
+
+		got, err := ToCanonicalJSON([]byte(input), opts)
+		if err != nil {
+			t.Fatalf("ToCanonicalJSON returned error: %v", err)
+		}
+		if string(got) != want {
+			t.Fatalf("output mismatch for input %q:\ngot  %q\nwant %q", input, got, want)
+		}
+	}
+}
+
+// protoMode and protoEndpoint mirror protoc-gen-go output: proto field
+// names in json tags, int32 enums and a oneof behind an interface.
+type protoMode int32
+
+type protoEndpoint struct {
+	Host       string    `protobuf:"bytes,1,opt,name=host,proto3" json:"host,omitempty"`
+	MaxRetries int32     `protobuf:"varint,2,opt,name=max_retries,json=maxRetries,proto3" json:"max_retries,omitempty"`
+	Mode       protoMode `protobuf:"varint,3,opt,name=mode,proto3,enum=demo.Mode" json:"mode,omitempty"`
+	Target     any       `protobuf_oneof:"target"`
+}
+
+func TestUnmarshal_ProtobufGeneratedStruct(t *testing.T) {
+	opts := UnmarshalOptions{Env: map[string]string{"HOST": "db", "RETRIES": "3", "MODE": "MODE_FAST"}}
+
+	var ep protoEndpoint
+	data := []byte("host: ${HOST}\nmax_retries: ${RETRIES}\nmode: 1\n")
+	if err := UnmarshalWithOptions(data, &ep, opts); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+	if ep.Host != "db" || ep.MaxRetries != 3 || ep.Mode != 1 {
+		t.Fatalf("got %+v", ep)
+	}
+
+	// protojson names and enum value names are not understood by the
+	// struct decoder; such documents go through ToJSON and protojson.
+	ep = protoEndpoint{}
+	if err := UnmarshalWithOptions([]byte("maxRetries: ${RETRIES}\n"), &ep, opts); err != nil || ep.MaxRetries != 0 {
+		t.Fatalf("lowerCamel key: got %+v, %v", ep, err)
+	}
+	if err := UnmarshalWithOptions([]byte("mode: ${MODE}\n"), &ep, opts); err == nil {
+		t.Fatal("expected error for enum value name")
+	}
+
+	got, err := ToJSONWithOptions([]byte("maxRetries: ${RETRIES}\nmode: ${MODE}\nid: \"9007199254740993\"\n"), "", opts)
+	if err != nil {
+		t.Fatalf("ToJSONWithOptions returned error: %v", err)
+	}
+	if want := `{"id":"9007199254740993","maxRetries":3,"mode":"MODE_FAST"}`; string(got) != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
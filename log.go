@@ -0,0 +1,59 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Source values reported in "source" attributes of log records.
+const (
+	logSourceResolver = "resolver"
+	logSourceDefault  = "default"
+	logSourceBackend  = "backend"
+)
+
+// logResolved writes a debug record for one replaced placeholder.
+func (st *scalarState) logResolved(content, value string) {
+	if st.opts.logger == nil {
+		return
+	}
+
+	source := logSourceResolver
+	switch {
+	case st.usedBackend:
+		source = logSourceBackend
+	case st.usedDefault:
+		source = logSourceDefault
+	}
+
+	attrs := []slog.Attr{
+		slog.String("var", placeholderVarName(content)),
+		slog.String("operator", placeholderOperator(content)),
+		slog.String("source", source),
+		slog.Bool("default", st.usedDefault),
+	}
+	if st.opts.logValues {
+		attrs = append(attrs, slog.String("value", unmaskReplacer.Replace(value)))
+	}
+
+	st.opts.logger.LogAttrs(context.Background(), slog.LevelDebug, "jamle: variable resolved", attrs...)
+}
+
+// logAssigned writes a warning for a ${VAR:=default} assignment made
+// through the resolver.
+func (st *scalarState) logAssigned(name, value string) {
+	if st.opts.logger == nil {
+		return
+	}
+
+	attrs := []slog.Attr{slog.String("var", name)}
+	if st.opts.logValues {
+		attrs = append(attrs, slog.String("value", unmaskReplacer.Replace(value)))
+	}
+
+	st.opts.logger.LogAttrs(context.Background(), slog.LevelWarn, "jamle: variable assigned to environment", attrs...)
+}
@@ -0,0 +1,82 @@
+package jamle
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalWithOptions_Logger(t *testing.T) {
+	input := []byte(`
+host: ${HOST}
+port: ${PORT:-8080}
+user: ${USER_NAME:=app}
+`)
+
+	tests := []struct {
+		name      string
+		logValues bool
+		want      []map[string]any
+	}{
+		{
+			name: "without values",
+			want: []map[string]any{
+				{"level": "DEBUG", "msg": "jamle: variable resolved", "var": "HOST", "operator": "", "source": "resolver", "default": false},
+				{"level": "DEBUG", "msg": "jamle: variable resolved", "var": "PORT", "operator": ":-", "source": "default", "default": true},
+				{"level": "WARN", "msg": "jamle: variable assigned to environment", "var": "USER_NAME"},
+				{"level": "DEBUG", "msg": "jamle: variable resolved", "var": "USER_NAME", "operator": ":=", "source": "default", "default": true},
+			},
+		},
+		{
+			name:      "with values",
+			logValues: true,
+			want: []map[string]any{
+				{"level": "DEBUG", "msg": "jamle: variable resolved", "var": "HOST", "operator": "", "source": "resolver", "default": false, "value": "db.local"},
+				{"level": "DEBUG", "msg": "jamle: variable resolved", "var": "PORT", "operator": ":-", "source": "default", "default": true, "value": "8080"},
+				{"level": "WARN", "msg": "jamle: variable assigned to environment", "var": "USER_NAME", "value": "app"},
+				{"level": "DEBUG", "msg": "jamle: variable resolved", "var": "USER_NAME", "operator": ":=", "source": "default", "default": true, "value": "app"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+				Level: slog.LevelDebug,
+				ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+					if a.Key == slog.TimeKey {
+						return slog.Attr{}
+					}
+					return a
+				},
+			}))
+
+			var got map[string]any
+			err := UnmarshalWithOptions(input, &got, UnmarshalOptions{
+				Env:       map[string]string{"HOST": "db.local"},
+				Logger:    logger,
+				LogValues: tt.logValues,
+			})
+			if err != nil {
+				t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+			}
+
+			var records []map[string]any
+			for line := range strings.Lines(buf.String()) {
+				var record map[string]any
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					t.Fatalf("invalid log line %q: %v", line, err)
+				}
+				records = append(records, record)
+			}
+
+			if !reflect.DeepEqual(records, tt.want) {
+				t.Fatalf("log records mismatch:\ngot  %v\nwant %v", records, tt.want)
+			}
+		})
+	}
+}
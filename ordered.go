@@ -0,0 +1,226 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	goyaml "go.yaml.in/yaml/v3"
+)
+
+// OrderedMap is a decoded YAML mapping that preserves document key order.
+type OrderedMap struct {
+	// Values stores decoded values by key. Nested mappings are *OrderedMap,
+	// sequences are []any, and scalars use YAML native types.
+	Values map[string]any `json:"values" yaml:"values"`
+
+	// Keys lists mapping keys in document order.
+	Keys []string `json:"keys" yaml:"keys"`
+}
+
+// newOrderedMap creates an empty ordered map with capacity hint.
+func newOrderedMap(size int) *OrderedMap {
+	return &OrderedMap{
+		Keys:   make([]string, 0, size),
+		Values: make(map[string]any, size),
+	}
+}
+
+// Get returns value stored for key.
+func (m *OrderedMap) Get(key string) (any, bool) {
+	if m == nil {
+		return nil, false
+	}
+
+	v, ok := m.Values[key]
+	return v, ok
+}
+
+// Len returns number of keys in the map.
+func (m *OrderedMap) Len() int {
+	if m == nil {
+		return 0
+	}
+
+	return len(m.Keys)
+}
+
+// set stores value for key, appending key on first insertion only.
+func (m *OrderedMap) set(key string, value any) {
+	if _, exists := m.Values[key]; !exists {
+		m.Keys = append(m.Keys, key)
+	}
+
+	m.Values[key] = value
+}
+
+// UnmarshalOrdered expands ${...} and decodes a YAML mapping document
+// into an order-preserving map.
+func UnmarshalOrdered(data []byte) (*OrderedMap, error) {
+	return UnmarshalOrderedWithOptions(data, UnmarshalOptions{})
+}
+
+// UnmarshalOrderedWithOptions decodes a YAML mapping document into an
+// order-preserving map using configured expansion options.
+func UnmarshalOrderedWithOptions(data []byte, opts UnmarshalOptions) (*OrderedMap, error) {
+	root, err := decodeExpandedNode(data, resolveOptions(opts, nil))
+	if errors.Is(err, io.EOF) {
+		return newOrderedMap(0), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	node := root
+	if node.Kind == goyaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind == goyaml.AliasNode {
+		node = node.Alias
+	}
+	if node.Kind != goyaml.MappingNode {
+		return nil, fmt.Errorf("%w, got %s", ErrOrderedRootNotMapping, nodeKindName(node.Kind))
+	}
+
+	return orderedMapFromNode(node)
+}
+
+// orderedValueFromNode converts one YAML node into an ordered Go value.
+func orderedValueFromNode(n *goyaml.Node) (any, error) {
+	switch n.Kind {
+	case goyaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+
+		return orderedValueFromNode(n.Content[0])
+
+	case goyaml.AliasNode:
+		return orderedValueFromNode(n.Alias)
+
+	case goyaml.MappingNode:
+		return orderedMapFromNode(n)
+
+	case goyaml.SequenceNode:
+		out := make([]any, 0, len(n.Content))
+		for _, child := range n.Content {
+			v, err := orderedValueFromNode(child)
+			if err != nil {
+				return nil, err
+			}
+
+			out = append(out, v)
+		}
+
+		return out, nil
+
+	default:
+		var v any
+		if err := n.Decode(&v); err != nil {
+			return nil, err
+		}
+
+		return v, nil
+	}
+}
+
+// orderedMapFromNode converts a mapping node, applying YAML merge keys.
+// Explicit keys always win over merged ones, regardless of position.
+func orderedMapFromNode(n *goyaml.Node) (*OrderedMap, error) {
+	out := newOrderedMap(len(n.Content) / 2)
+	explicit := make(map[string]bool, len(n.Content)/2)
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		keyNode := n.Content[i]
+		valueNode := n.Content[i+1]
+
+		if keyNode.Kind == goyaml.ScalarNode && keyNode.ShortTag() == "!!merge" {
+			if err := mergeIntoOrderedMap(out, explicit, valueNode); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if keyNode.Kind != goyaml.ScalarNode {
+			return nil, fmt.Errorf(
+				"unsupported map key of kind %s at line %d",
+				nodeKindName(keyNode.Kind),
+				keyNode.Line,
+			)
+		}
+
+		v, err := orderedValueFromNode(valueNode)
+		if err != nil {
+			return nil, err
+		}
+
+		out.set(keyNode.Value, v)
+		explicit[keyNode.Value] = true
+	}
+
+	return out, nil
+}
+
+// mergeIntoOrderedMap applies `<<` merge sources without overriding
+// explicit keys of the destination mapping.
+func mergeIntoOrderedMap(dst *OrderedMap, explicit map[string]bool, src *goyaml.Node) error {
+	if src.Kind == goyaml.AliasNode {
+		src = src.Alias
+	}
+
+	switch src.Kind {
+	case goyaml.MappingNode:
+		merged, err := orderedMapFromNode(src)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range merged.Keys {
+			if explicit[key] {
+				continue
+			}
+			if _, exists := dst.Values[key]; exists {
+				continue
+			}
+
+			dst.set(key, merged.Values[key])
+		}
+
+		return nil
+
+	case goyaml.SequenceNode:
+		for _, item := range src.Content {
+			if err := mergeIntoOrderedMap(dst, explicit, item); err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	default:
+		return fmt.Errorf("merge key value must be a mapping at line %d", src.Line)
+	}
+}
+
+// nodeKindName returns a readable YAML node kind name for error messages.
+func nodeKindName(kind goyaml.Kind) string {
+	switch kind {
+	case goyaml.DocumentNode:
+		return "document"
+	case goyaml.SequenceNode:
+		return "sequence"
+	case goyaml.MappingNode:
+		return "mapping"
+	case goyaml.ScalarNode:
+		return "scalar"
+	case goyaml.AliasNode:
+		return "alias"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,116 @@
+package jamle
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalOrdered_PreservesKeyOrder(t *testing.T) {
+	t.Setenv("JAMLE_ORDERED_HOST", "svc.local")
+
+	input := []byte(`
+zeta: 1
+alpha:
+  host: ${JAMLE_ORDERED_HOST}
+  port: ${JAMLE_ORDERED_PORT:-8080}
+  enabled: true
+middle:
+  - b: 2
+    a: 1
+`)
+
+	got, err := UnmarshalOrdered(input)
+	if err != nil {
+		t.Fatalf("UnmarshalOrdered returned error: %v", err)
+	}
+
+	if !reflect.DeepEqual(got.Keys, []string{"zeta", "alpha", "middle"}) {
+		t.Fatalf("top-level key order mismatch: %v", got.Keys)
+	}
+
+	alphaValue, _ := got.Get("alpha")
+	alpha, ok := alphaValue.(*OrderedMap)
+	if !ok {
+		t.Fatalf("expected nested *OrderedMap, got %T", alphaValue)
+	}
+	if !reflect.DeepEqual(alpha.Keys, []string{"host", "port", "enabled"}) {
+		t.Fatalf("nested key order mismatch: %v", alpha.Keys)
+	}
+	if alpha.Values["host"] != "svc.local" || alpha.Values["port"] != 8080 {
+		t.Fatalf("nested values mismatch: %#v", alpha.Values)
+	}
+
+	middleValue, _ := got.Get("middle")
+	middle, ok := middleValue.([]any)
+	if !ok || len(middle) != 1 {
+		t.Fatalf("expected one-element sequence, got %#v", middleValue)
+	}
+	item, ok := middle[0].(*OrderedMap)
+	if !ok {
+		t.Fatalf("expected sequence item *OrderedMap, got %T", middle[0])
+	}
+	if !reflect.DeepEqual(item.Keys, []string{"b", "a"}) {
+		t.Fatalf("sequence item key order mismatch: %v", item.Keys)
+	}
+}
+
+func TestUnmarshalOrdered_MergeKeys(t *testing.T) {
+	input := []byte(`
+base: &base
+  host: base.local
+  port: 80
+svc:
+  name: api
+  <<: *base
+  port: 8080
+`)
+
+	got, err := UnmarshalOrdered(input)
+	if err != nil {
+		t.Fatalf("UnmarshalOrdered returned error: %v", err)
+	}
+
+	svcValue, _ := got.Get("svc")
+	svc := svcValue.(*OrderedMap)
+	if !reflect.DeepEqual(svc.Keys, []string{"name", "host", "port"}) {
+		t.Fatalf("merged key order mismatch: %v", svc.Keys)
+	}
+	if svc.Values["host"] != "base.local" || svc.Values["port"] != 8080 {
+		t.Fatalf("merged values mismatch: %#v", svc.Values)
+	}
+}
+
+func TestUnmarshalOrderedWithOptions(t *testing.T) {
+	t.Run("custom resolver", func(t *testing.T) {
+		got, err := UnmarshalOrderedWithOptions([]byte("b: ${B}\na: ${A:-x}\n"), UnmarshalOptions{
+			Resolver: mapResolver{values: map[string]string{"B": "from-b"}},
+		})
+		if err != nil {
+			t.Fatalf("UnmarshalOrderedWithOptions returned error: %v", err)
+		}
+		if !reflect.DeepEqual(got.Keys, []string{"b", "a"}) {
+			t.Fatalf("key order mismatch: %v", got.Keys)
+		}
+		if got.Values["b"] != "from-b" || got.Values["a"] != "x" {
+			t.Fatalf("values mismatch: %#v", got.Values)
+		}
+	})
+
+	t.Run("empty document", func(t *testing.T) {
+		got, err := UnmarshalOrdered(nil)
+		if err != nil {
+			t.Fatalf("UnmarshalOrdered returned error: %v", err)
+		}
+		if got.Len() != 0 {
+			t.Fatalf("expected empty map, got %#v", got)
+		}
+	})
+
+	t.Run("non-mapping root", func(t *testing.T) {
+		_, err := UnmarshalOrdered([]byte("- a\n- b\n"))
+		if !errors.Is(err, ErrOrderedRootNotMapping) {
+			t.Fatalf("expected ErrOrderedRootNotMapping, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+
+	jyaml "github.com/woozymasta/jamle/yaml"
+	goyaml "go.yaml.in/yaml/v3"
+)
+
+// UnmarshalRaw works like Unmarshal and also returns the expanded document
+// re-encoded as YAML, e.g. for audit logs. The document is parsed once.
+func UnmarshalRaw(data []byte, v any) ([]byte, error) {
+	return UnmarshalRawWithOptions(data, v, UnmarshalOptions{})
+}
+
+// UnmarshalRawWithOptions is UnmarshalRaw with configured options. The
+// returned bytes match ExpandPathWithOptions(data, "", opts) for the first
+// document, except that `jamle:"noexpand"` tags of v are honored in both
+// the bytes and v. Empty input yields nil bytes.
+func UnmarshalRawWithOptions(data []byte, v any, opts UnmarshalOptions) ([]byte, error) {
+	runtime := resolveOptions(opts, reflect.TypeOf(v))
+	root, err := decodeExpandedNode(data, runtime)
+	if errors.Is(err, io.EOF) {
+		return nil, jyaml.Unmarshal(data, v)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	enc := goyaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := jyaml.UnmarshalNode(root, v); err != nil {
+		return nil, err
+	}
+
+	return applyTrailingNewline(out.Bytes(), runtime.trailingNewline), nil
+}
@@ -0,0 +1,64 @@
+package jamle
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalRawWithOptions(t *testing.T) {
+	type server struct {
+		Host  string   `json:"host"`
+		Port  int      `json:"port"`
+		Tags  []string `json:"tags"`
+		Debug bool     `json:"debug"`
+	}
+	type config struct {
+		Server server `json:"server"`
+		Script string `json:"script" jamle:"noexpand"`
+	}
+
+	env := map[string]string{"HOST": "db.local", "TAG": "blue"}
+	data := []byte(`# service config
+server:
+  host: ${HOST}   # primary
+  port: ${PORT:-5432}
+  tags:
+    - ${TAG}
+    - "static"
+  debug: ${DEBUG:-false}
+`)
+
+	var cfg config
+	raw, err := UnmarshalRawWithOptions(data, &cfg, UnmarshalOptions{Env: env})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := server{Host: "db.local", Port: 5432, Tags: []string{"blue", "static"}}
+	if !reflect.DeepEqual(cfg.Server, want) {
+		t.Fatalf("got %+v, want %+v", cfg.Server, want)
+	}
+
+	expanded, err := ExpandPathWithOptions(data, "", UnmarshalOptions{Env: env})
+	if err != nil {
+		t.Fatalf("ExpandPathWithOptions returned error: %v", err)
+	}
+	if !bytes.Equal(raw, expanded) {
+		t.Fatalf("raw bytes differ from ExpandPath:\nraw      %q\nexpanded %q", raw, expanded)
+	}
+
+	// noexpand fields stay literal in both the struct and the bytes.
+	raw, err = UnmarshalRawWithOptions([]byte("script: echo ${HOST}\n"), &cfg, UnmarshalOptions{Env: env})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Script != "echo ${HOST}" || string(raw) != "script: echo ${HOST}\n" {
+		t.Fatalf("got script %q, raw %q", cfg.Script, raw)
+	}
+
+	raw, err = UnmarshalRaw(nil, &cfg)
+	if err != nil || raw != nil {
+		t.Fatalf("empty input: got %q, %v", raw, err)
+	}
+}
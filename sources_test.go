@@ -0,0 +1,74 @@
+package jamle
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandStringWithOptions_Sources(t *testing.T) {
+	overrides := MapSource(map[string]string{"PORT": "9000", "EMPTY": ""})
+	process := MapSource(map[string]string{"PORT": "8080", "HOST": "db.local", "USER": "app"})
+	defaults := MapSource(map[string]string{"PORT": "80", "HOST": "localhost", "MODE": "dev"})
+	sources := []Source{overrides, process, defaults}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		err   string
+	}{
+		{name: "first source wins", input: "${PORT}", want: "9000"},
+		{name: "falls through to second", input: "${HOST}", want: "db.local"},
+		{name: "falls through to last", input: "${MODE}", want: "dev"},
+		{name: "unset everywhere", input: "${NONE}", want: ""},
+		{name: "default applies to combined result", input: "${NONE:-fallback}", want: "fallback"},
+		{name: "default unused when any source has it", input: "${MODE:-prod}", want: "dev"},
+		{name: "empty but found stops the chain", input: "${EMPTY-x}", want: ""},
+		{name: "colon default treats found empty as unset", input: "${EMPTY:-x}", want: "x"},
+		{name: "required satisfied by later source", input: "${USER:?}", want: "app"},
+		{name: "required fails after all sources", input: "${NONE:?missing}", err: `"NONE" missing`},
+		{name: "assignment is visible to later reads", input: "${NEW:=v}-${NEW}", want: "v-v"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandStringWithOptions(tt.input, UnmarshalOptions{Sources: sources})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v (result %q)", tt.err, err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Sources replace Env and the process environment.
+	t.Setenv("JAMLE_SOURCES_TEST", "process")
+	got, err := ExpandStringWithOptions("${JAMLE_SOURCES_TEST:-none}/${PORT}", UnmarshalOptions{
+		Sources: []Source{defaults},
+		Env:     map[string]string{"PORT": "1"},
+	})
+	if err != nil || got != "none/80" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+
+	// Assignments stay in the call and do not leak into the sources.
+	if _, ok := sourcesResolver(sources).Lookup("NEW"); ok {
+		t.Fatal("assignment leaked into sources")
+	}
+
+	// Prefix applies across the whole chain.
+	got, err = ExpandStringWithOptions("${HOST}", UnmarshalOptions{
+		Sources: []Source{MapSource(map[string]string{"HOST": "plain"}), MapSource(map[string]string{"APP_HOST": "prefixed"})},
+		Prefix:  "APP_",
+	})
+	if err != nil || got != "prefixed" {
+		t.Fatalf("prefix: got %q, %v", got, err)
+	}
+}
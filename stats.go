@@ -0,0 +1,43 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import "reflect"
+
+// ExpandStats summarizes the expansion work of one document, e.g. to find
+// configs whose nesting comes close to the pass limit.
+type ExpandStats struct {
+	// Scalars is the number of scalars, keys included, that contained
+	// expansion syntax.
+	Scalars int
+
+	// Variables is the number of placeholders resolved, counted per
+	// occurrence and including nested ones.
+	Variables int
+
+	// MaxPasses is the largest number of expansion passes that one scalar
+	// or variable value needed.
+	MaxPasses int
+
+	// PassLimit is the effective UnmarshalOptions.MaxPasses. A MaxPasses
+	// equal to PassLimit means some placeholder may be left unexpanded.
+	PassLimit int
+}
+
+// UnmarshalStats works like UnmarshalWithOptions and also returns expansion
+// statistics. Statistics collected before an error are returned along
+// with it.
+func UnmarshalStats(data []byte, v any, opts UnmarshalOptions) (ExpandStats, error) {
+	runtime := resolveOptions(opts, reflect.TypeOf(v))
+	stats := ExpandStats{PassLimit: runtime.maxPasses}
+	if !hasExpansionSyntax(data, opts) {
+		return stats, UnmarshalWithOptions(data, v, opts)
+	}
+
+	runtime.stats = &stats
+	err := unmarshalExpanded(data, v, runtime)
+
+	return stats, err
+}
@@ -0,0 +1,60 @@
+package jamle
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnmarshalStats(t *testing.T) {
+	input := []byte(`
+name: app
+host: ${HOST}
+deep: ${A:-${B:-${C:-${D:-leaf}}}}
+pair: ${HOST}:${PORT:-80}
+`)
+
+	var got map[string]any
+	stats, err := UnmarshalStats(input, &got, UnmarshalOptions{Env: map[string]string{"HOST": "db"}})
+	if err != nil {
+		t.Fatalf("UnmarshalStats returned error: %v", err)
+	}
+
+	want := ExpandStats{Scalars: 3, Variables: 7, MaxPasses: 4, PassLimit: DefaultMaxPasses}
+	if stats != want {
+		t.Fatalf("stats mismatch:\ngot  %+v\nwant %+v", stats, want)
+	}
+	if got["deep"] != "leaf" || got["pair"] != "db:80" {
+		t.Fatalf("decoded document mismatch: %#v", got)
+	}
+
+	t.Run("pass limit reached", func(t *testing.T) {
+		var got map[string]any
+		stats, err := UnmarshalStats(input, &got, UnmarshalOptions{Env: map[string]string{}, MaxPasses: 2})
+		if err != nil {
+			t.Fatalf("UnmarshalStats returned error: %v", err)
+		}
+		if stats.MaxPasses != 2 || stats.PassLimit != 2 {
+			t.Fatalf("got %+v, want MaxPasses and PassLimit 2", stats)
+		}
+	})
+
+	t.Run("no placeholders", func(t *testing.T) {
+		var got map[string]any
+		stats, err := UnmarshalStats([]byte("a: 1\n"), &got, UnmarshalOptions{})
+		if err != nil || stats != (ExpandStats{PassLimit: DefaultMaxPasses}) || got["a"] != 1 {
+			t.Fatalf("unexpected result: stats=%+v got=%#v err=%v", stats, got, err)
+		}
+	})
+
+	t.Run("stats before error", func(t *testing.T) {
+		var got map[string]any
+		stats, err := UnmarshalStats([]byte("a: ${A:-x}\nb: ${B:?required}\n"), &got, UnmarshalOptions{Env: map[string]string{}})
+		var expandErr *ExpandError
+		if !errors.As(err, &expandErr) || expandErr.Var != "B" {
+			t.Fatalf("expected ExpandError for B, got %v", err)
+		}
+		if stats.Scalars != 2 || stats.Variables != 1 {
+			t.Fatalf("got %+v", stats)
+		}
+	})
+}
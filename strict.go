@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkStrictDollar reports the first $ in s that does not start a
+// placeholder. Accepted forms are ${...}, the EscapePrefix escape and, with
+// Arithmetic, $((. Inside braces a bare $NAME is accepted as well, because
+// default values expand it. Offsets are byte positions in the scalar.
+func checkStrictDollar(s string, opts *runtimeOptions) error {
+	return scanStrictDollar(s, 0, false, opts)
+}
+
+// scanStrictDollar checks s, which starts at offset base of the scalar.
+func scanStrictDollar(s string, base int, inBraces bool, opts *runtimeOptions) error {
+	for i := 0; i < len(s); i++ {
+		if strings.HasPrefix(s[i:], opts.escapePrefix) {
+			// prefix{...} is an escaped placeholder; its content stays literal.
+			open := i + len(opts.escapePrefix)
+			if end, ok := findClosingBraceIndex(s, open); ok {
+				i = end
+			} else {
+				i = open - 1
+			}
+
+			continue
+		}
+
+		if s[i] != '$' {
+			continue
+		}
+
+		next := byte(0)
+		if i+1 < len(s) {
+			next = s[i+1]
+		}
+
+		switch {
+		case next == '{':
+			end, ok := findClosingBraceIndex(s, i+1)
+			if !ok {
+				return fmt.Errorf("%w: %w at offset %d", ErrStrictDollar, ErrUnterminatedVariable, base+i)
+			}
+			if err := scanStrictDollar(s[i+2:end], base+i+2, true, opts); err != nil {
+				return err
+			}
+			i = end
+
+		case opts.arithmetic && next == '(' && i+2 < len(s) && s[i+2] == '(':
+			i += 2
+
+		case inBraces && isValidVarName(leadingVarName(s[i+1:])):
+			// Bare $NAME in a default value.
+
+		default:
+			return fmt.Errorf("%w: stray \"$\" at offset %d", ErrStrictDollar, base+i)
+		}
+	}
+
+	return nil
+}
+
+// checkEscapePrefix validates an UnmarshalOptions.EscapePrefix; an empty
+// prefix selects DefaultEscapePrefix.
+func checkEscapePrefix(prefix string) error {
+	switch {
+	case prefix == "":
+		return nil
+	case prefix == "$" || !strings.HasSuffix(prefix, "$"):
+		return fmt.Errorf("%w %q: must end with $ and be longer than $", ErrInvalidEscapePrefix, prefix)
+	case strings.ContainsAny(prefix, "{}"):
+		return fmt.Errorf("%w %q: must not contain braces", ErrInvalidEscapePrefix, prefix)
+	}
+
+	return nil
+}
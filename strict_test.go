@@ -0,0 +1,158 @@
+package jamle
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExpandStringWithOptions_StrictDollar(t *testing.T) {
+	env := map[string]string{"HOST": "db", "PRICE": "$5"}
+
+	tests := []struct {
+		name  string
+		input string
+		opts  UnmarshalOptions
+		want  string
+		err   string
+	}{
+		{name: "placeholder", input: "${HOST}:5432", want: "db:5432"},
+		{name: "escaped placeholder", input: "$${HOST}", want: "${HOST}"},
+		{name: "escaped dollar", input: "cost $$5", want: "cost $5"},
+		{name: "bare var in default", input: "${MISSING:-$HOST}", want: "db"},
+		{name: "resolved value not checked", input: "${PRICE}", want: "$5"},
+		{name: "arithmetic", input: "$((1+2))", opts: UnmarshalOptions{Arithmetic: true}, want: "3"},
+		{name: "forgotten brace", input: "http://$HOST}", err: `stray "$" at offset 7`},
+		{name: "trailing dollar", input: "cost 5$", err: `stray "$" at offset 6`},
+		{name: "unterminated", input: "x ${HOST", err: `unterminated "${" at offset 2`},
+		{name: "unterminated inside placeholder", input: "${A:-${HOST}", err: `unterminated "${" at offset 0`},
+		{name: "stray inside default", input: "${A:-$}", err: `stray "$" at offset 5`},
+		{name: "arithmetic disabled", input: "$((1+2))", err: `stray "$" at offset 0`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Env = env
+			tt.opts.StrictDollar = true
+			got, err := ExpandStringWithOptions(tt.input, tt.opts)
+			if tt.err != "" {
+				if !errors.Is(err, ErrStrictDollar) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected ErrStrictDollar containing %q, got %v (result %q)", tt.err, err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	got, err := ExpandStringWithOptions("http://$HOST} ${HOST", UnmarshalOptions{Env: env})
+	if err != nil || got != "http://$HOST} ${HOST" {
+		t.Fatalf("non-strict: got %q, %v", got, err)
+	}
+}
+
+func TestUnmarshal_StrictDollarPosition(t *testing.T) {
+	var cfg map[string]string
+	err := UnmarshalWithOptions([]byte("a: plain\nurl: http://$HOST/\n"), &cfg, UnmarshalOptions{StrictDollar: true})
+
+	var expandErr *ExpandError
+	if !errors.As(err, &expandErr) || !errors.Is(err, ErrStrictDollar) {
+		t.Fatalf("expected ExpandError wrapping ErrStrictDollar, got %v", err)
+	}
+	if expandErr.Line != 2 || expandErr.Column != 6 {
+		t.Fatalf("got position %d:%d, want 2:6", expandErr.Line, expandErr.Column)
+	}
+}
+
+func TestUnmarshal_StrictDollarUnterminated(t *testing.T) {
+	data := []byte("db:\n  host: ${DB_HOST\n  port: ${DB_PORT:-5432}\n")
+
+	var cfg map[string]map[string]string
+	err := UnmarshalWithOptions(data, &cfg, UnmarshalOptions{StrictDollar: true, Env: map[string]string{"DB_HOST": "db"}})
+	if !errors.Is(err, ErrUnterminatedVariable) || !errors.Is(err, ErrStrictDollar) {
+		t.Fatalf("expected ErrUnterminatedVariable, got %v", err)
+	}
+
+	want := `line 2:9: unrecognized $ sequence: unterminated "${" at offset 0 (in "${DB_HOST")`
+	if err.Error() != want {
+		t.Fatalf("got %q, want %q", err.Error(), want)
+	}
+
+	_, err = ExpandStringWithOptions("$HOST}", UnmarshalOptions{StrictDollar: true})
+	if errors.Is(err, ErrUnterminatedVariable) {
+		t.Fatalf("stray $ must not match ErrUnterminatedVariable: %v", err)
+	}
+
+	// Without StrictDollar the text is kept verbatim, as before.
+	if err := UnmarshalWithOptions(data, &cfg, UnmarshalOptions{Env: map[string]string{}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg["db"]["host"] != "${DB_HOST" {
+		t.Fatalf("got %q", cfg["db"]["host"])
+	}
+}
+
+func TestExpandStringWithOptions_EscapePrefix(t *testing.T) {
+	env := map[string]string{"HOST": "db"}
+
+	tests := []struct {
+		name   string
+		input  string
+		prefix string
+		strict bool
+		want   string
+		err    error
+	}{
+		{name: "default prefix", input: "$${HOST} $$5 ${HOST}", want: "${HOST} $5 db"},
+		{name: "backslash escape", input: `\${HOST} ${HOST}`, prefix: `\$`, want: "${HOST} db"},
+		{name: "backslash lone dollar", input: `cost \$5`, prefix: `\$`, want: "cost $5"},
+		{name: "nested placeholder stays literal", input: `\${A:-${HOST}}`, prefix: `\$`, want: "${A:-${HOST}}"},
+		{name: "escape inside default", input: `${MISSING:-\${HOST}}`, prefix: `\$`, want: "${HOST}"},
+		{name: "double dollar no longer special", input: "$$5", prefix: `\$`, want: "$$5"},
+		{name: "other backslashes untouched", input: `a\b ${HOST}`, prefix: `\$`, want: `a\b db`},
+		{name: "word prefix", input: "ESC${HOST}", prefix: "ESC$", want: "${HOST}"},
+		{name: "strict accepts custom escape", input: `\${HOST} \$5`, prefix: `\$`, strict: true, want: "${HOST} $5"},
+		{name: "strict rejects old escape", input: "$$5", prefix: `\$`, strict: true, err: ErrStrictDollar},
+		{name: "lone dollar prefix", input: "${HOST}", prefix: "$", err: ErrInvalidEscapePrefix},
+		{name: "no trailing dollar", input: "${HOST}", prefix: `\`, err: ErrInvalidEscapePrefix},
+		{name: "braces", input: "${HOST}", prefix: "{$", err: ErrInvalidEscapePrefix},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := UnmarshalOptions{Env: env, EscapePrefix: tt.prefix, StrictDollar: tt.strict}
+			got, err := ExpandStringWithOptions(tt.input, opts)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected %v, got %v (result %q)", tt.err, err, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A document whose only special text is the custom escape still expands.
+	var cfg map[string]string
+	if err := UnmarshalWithOptions([]byte(`price: \$5`+"\n"), &cfg, UnmarshalOptions{EscapePrefix: `\$`}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg["price"] != "$5" {
+		t.Fatalf("got %q", cfg["price"])
+	}
+
+	err := UnmarshalWithOptions([]byte("a: 1\n"), &cfg, UnmarshalOptions{EscapePrefix: "$"})
+	if !errors.Is(err, ErrInvalidEscapePrefix) {
+		t.Fatalf("expected ErrInvalidEscapePrefix for a document without placeholders, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// maxSuggestions limits how many close matches a missing-variable error lists.
+const maxSuggestions = 3
+
+// suggestionSuffix returns "; did you mean X?" listing set variables close
+// to name, or an empty string when suggestions are off or nothing is close.
+func (opts *runtimeOptions) suggestionSuffix(name string) string {
+	if opts.suggestNames == nil {
+		return ""
+	}
+
+	// Short names allow one edit only, so ${ID} does not suggest every
+	// two- or three-letter variable.
+	maxDistance := 2
+	if len(name) < 5 {
+		maxDistance = 1
+	}
+
+	type match struct {
+		name     string
+		distance int
+	}
+	var matches []match
+	seen := make(map[string]struct{})
+	upper := strings.ToUpper(name)
+	for candidate := range opts.suggestNames {
+		// Prefixed lookups fall back to the bare name, so both spellings
+		// are offered as the name to write inside ${...}.
+		if opts.prefix != "" {
+			candidate = strings.TrimPrefix(candidate, opts.prefix)
+		}
+		if candidate == name {
+			continue
+		}
+		if _, ok := seen[candidate]; ok {
+			continue
+		}
+		seen[candidate] = struct{}{}
+
+		if d := levenshtein(upper, strings.ToUpper(candidate)); d <= maxDistance {
+			matches = append(matches, match{name: candidate, distance: d})
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+
+	slices.SortFunc(matches, func(a, b match) int {
+		return cmp.Or(cmp.Compare(a.distance, b.distance), strings.Compare(a.name, b.name))
+	})
+
+	// Only the closest matches are listed: a case-only difference should
+	// not be drowned out by names two edits away.
+	names := make([]string, 0, maxSuggestions)
+	for _, m := range matches {
+		if m.distance > matches[0].distance || len(names) == maxSuggestions {
+			break
+		}
+		names = append(names, m.name)
+	}
+	if len(names) == 1 {
+		return "; did you mean " + names[0] + "?"
+	}
+
+	return "; did you mean " + strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1] + "?"
+}
+
+// levenshtein returns the edit distance between a and b in bytes.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
@@ -0,0 +1,102 @@
+package jamle
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExpandStringWithOptions_SuggestVariables(t *testing.T) {
+	env := map[string]string{"DB_HOST": "db", "DB_PORT": "5432", "ID": "1", "APP_TOKEN": "t"}
+
+	tests := []struct {
+		name  string
+		input string
+		opts  UnmarshalOptions
+		want  string
+	}{
+		{
+			name:  "near miss",
+			input: "${DB_HOSTT:?}",
+			want:  `environment variable "DB_HOSTT" is not set or empty; did you mean DB_HOST?`,
+		},
+		{
+			name:  "case difference",
+			input: "${db_host:?}",
+			want:  "did you mean DB_HOST?",
+		},
+		{
+			name:  "several matches",
+			input: "${DB_POST:?}",
+			want:  "did you mean DB_HOST or DB_PORT?",
+		},
+		{
+			name:  "custom message",
+			input: "${DB_HOTS:?needed for migrations}",
+			want:  `"DB_HOTS" needed for migrations; did you mean DB_HOST?`,
+		},
+		{
+			name:  "unset format",
+			input: "${DB_HOTS:?}",
+			opts:  UnmarshalOptions{UnsetErrorFormat: "%s is required"},
+			want:  "DB_HOTS is required; did you mean DB_HOST?",
+		},
+		{
+			name:  "error on unset",
+			input: "${DB_PORTT}",
+			opts:  UnmarshalOptions{ErrorOnUnset: true},
+			want:  `"DB_PORTT"; did you mean DB_PORT?`,
+		},
+		{
+			name:  "prefix stripped",
+			input: "${TOKN:?}",
+			opts:  UnmarshalOptions{Prefix: "APP_"},
+			want:  "did you mean TOKEN?",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Env = env
+			tt.opts.SuggestVariables = true
+			_, err := ExpandStringWithOptions(tt.input, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	for _, input := range []string{"${NOTHING_CLOSE:?}", "${Q:?}"} {
+		_, err := ExpandStringWithOptions(input, UnmarshalOptions{Env: env, SuggestVariables: true})
+		if err == nil || strings.Contains(err.Error(), "did you mean") {
+			t.Fatalf("%s: expected error without suggestion, got %v", input, err)
+		}
+	}
+
+	_, err := ExpandStringWithOptions("${DB_HOSTT:?}", UnmarshalOptions{Env: env})
+	if err == nil || strings.Contains(err.Error(), "DB_HOST?") {
+		t.Fatalf("suggestions must be opt-in, got %v", err)
+	}
+
+	_, err = ExpandStringWithOptions("${DB_PORTT}", UnmarshalOptions{Env: env, ErrorOnUnset: true, SuggestVariables: true})
+	if !errors.Is(err, ErrUnsetVariable) {
+		t.Fatalf("expected ErrUnsetVariable, got %v", err)
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"DB_HOST", "DB_HOSTT", 1},
+		{"DB_HOST", "DB_HOTS", 2},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
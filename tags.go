@@ -0,0 +1,176 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// expandTagTokens expands ${...} inside node tags such as `!type-${VARIANT}`.
+// YAML does not allow braces in tags, so tags are expanded in the source
+// text before parsing. A tag is recognized only where a node may start:
+// at the beginning of a line, after "- ", "? ", ": " or a flow indicator.
+func expandTagTokens(data []byte, opts runtimeOptions) ([]byte, error) {
+	if !bytes.Contains(data, []byte("!")) || !bytes.Contains(data, []byte("${")) {
+		return data, nil
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	changed := false
+	for i, line := range lines {
+		expanded, err := expandTagTokensInLine(line, opts)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+
+		if expanded != line {
+			lines[i] = expanded
+			changed = true
+		}
+	}
+
+	if !changed {
+		return data, nil
+	}
+
+	return []byte(strings.Join(lines, "")), nil
+}
+
+// expandTagTokensInLine expands tag tokens with ${...} in one source line.
+// Quoted scalars and comments are copied as is.
+func expandTagTokensInLine(line string, opts runtimeOptions) (string, error) {
+	if !strings.Contains(line, "!") || !strings.Contains(line, "${") {
+		return line, nil
+	}
+
+	var out strings.Builder
+	out.Grow(len(line))
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			out.WriteString(line[i:])
+			return out.String(), nil
+
+		case (c == '"' || c == '\'') && isNodeStart(line[:i]):
+			end := quotedScalarEnd(line, i)
+			out.WriteString(line[i:end])
+			i = end - 1
+			continue
+
+		case c != '!' || !isNodeStart(line[:i]):
+			out.WriteByte(c)
+			continue
+		}
+
+		end := tagTokenEnd(line, i)
+		token := line[i+1 : end]
+		if !strings.Contains(token, "${") {
+			out.WriteString(line[i:end])
+			i = end - 1
+			continue
+		}
+
+		expanded, err := expandEnvInScalar(token, opts)
+		if err != nil {
+			return "", err
+		}
+		if !isValidTagSuffix(expanded) {
+			return "", fmt.Errorf("%w %q from !%s", ErrInvalidTag, "!"+expanded, token)
+		}
+
+		out.WriteByte('!')
+		out.WriteString(expanded)
+		i = end - 1
+	}
+
+	return out.String(), nil
+}
+
+// isNodeStart reports whether a node (and so a tag or a quoted scalar) may
+// start after prefix: at line start, after "- ", "? ", ": " or after a flow
+// indicator.
+func isNodeStart(prefix string) bool {
+	trimmed := strings.TrimRight(prefix, " \t")
+	if trimmed == "" {
+		return true
+	}
+
+	last := trimmed[len(trimmed)-1]
+	switch last {
+	case '[', '{', ',':
+		return true
+	case ':':
+		return len(trimmed) < len(prefix)
+	case '-', '?':
+		return len(trimmed) < len(prefix) && strings.TrimLeft(trimmed, " \t-?") == ""
+	}
+
+	return false
+}
+
+// quotedScalarEnd returns the index after the quoted scalar starting at
+// line[start], or len(line) when it continues on the next line.
+func quotedScalarEnd(line string, start int) int {
+	quote := line[start]
+	for i := start + 1; i < len(line); i++ {
+		switch {
+		case quote == '"' && line[i] == '\\':
+			i++
+		case line[i] == quote && quote == '\'' && i+1 < len(line) && line[i+1] == '\'':
+			i++
+		case line[i] == quote:
+			return i + 1
+		}
+	}
+
+	return len(line)
+}
+
+// tagTokenEnd returns the end index of a tag token starting at line[start],
+// skipping over balanced ${...} placeholders that may contain spaces.
+func tagTokenEnd(line string, start int) int {
+	i := start + 1
+	for i < len(line) {
+		switch line[i] {
+		case ' ', '\t', '\r', '\n', ',', '[', ']', '}':
+			return i
+		case '$':
+			if i+1 < len(line) && line[i+1] == '{' {
+				if j, ok := findClosingBraceIndex(line, i+1); ok {
+					i = j + 1
+					continue
+				}
+			}
+		case '{':
+			return i
+		}
+		i++
+	}
+
+	return i
+}
+
+// isValidTagSuffix reports whether s may follow '!' in a YAML tag shorthand.
+func isValidTagSuffix(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("-#;/?:@&=+$_.~*'()%!", c) >= 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
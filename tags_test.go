@@ -0,0 +1,96 @@
+package jamle
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUnmarshalWithOptions_ExpandTags(t *testing.T) {
+	env := map[string]string{"VARIANT": "blue", "KIND": "secret", "BAD": "a b"}
+
+	input := []byte(`key: !type-${VARIANT} value
+list:
+  - !${KIND} ${VARIANT}
+  - [!kind-${VARIANT} x, "!quoted-${VARIANT}"]
+plain: hello !${VARIANT} # comment !${VARIANT}
+quoted: '!${VARIANT}'
+`)
+
+	root, err := decodeExpandedNode(input, resolveOptions(UnmarshalOptions{Env: env, ExpandTags: true}, nil))
+	if err != nil {
+		t.Fatalf("decodeExpandedNode returned error: %v", err)
+	}
+
+	doc := root.Content[0]
+	if tag := doc.Content[1].Tag; tag != "!type-blue" {
+		t.Fatalf("key tag mismatch: %q", tag)
+	}
+
+	list := doc.Content[3]
+	if item := list.Content[0]; item.Tag != "!secret" || item.Value != "blue" {
+		t.Fatalf("sequence item mismatch: tag=%q value=%q", item.Tag, item.Value)
+	}
+	flow := list.Content[1]
+	if flow.Content[0].Tag != "!kind-blue" || flow.Content[1].Value != "!quoted-blue" {
+		t.Fatalf("flow items mismatch: tag=%q value=%q", flow.Content[0].Tag, flow.Content[1].Value)
+	}
+
+	if got := doc.Content[5].Value; got != "hello !blue" {
+		t.Fatalf("plain scalar mismatch: %q", got)
+	}
+	if got := doc.Content[7].Value; got != "!blue" {
+		t.Fatalf("quoted scalar mismatch: %q", got)
+	}
+
+	t.Run("invalid tag", func(t *testing.T) {
+		var got map[string]any
+		err := UnmarshalWithOptions([]byte("key: !x-${BAD} v\n"), &got, UnmarshalOptions{Env: env, ExpandTags: true})
+		if !errors.Is(err, ErrInvalidTag) {
+			t.Fatalf("expected ErrInvalidTag, got %v", err)
+		}
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		var got map[string]any
+		if err := UnmarshalWithOptions([]byte("key: !type-${VARIANT} value\n"), &got, UnmarshalOptions{Env: env}); err == nil {
+			t.Fatal("expected YAML error for braces in tag without ExpandTags")
+		}
+	})
+
+	t.Run("all documents", func(t *testing.T) {
+		var docs []map[string]string
+		err := UnmarshalAllWithOptions([]byte("a: !!str ${VARIANT}\n---\nb: !t-${VARIANT} y\n"), &docs, UnmarshalOptions{Env: env, ExpandTags: true})
+		if err != nil {
+			t.Fatalf("UnmarshalAllWithOptions returned error: %v", err)
+		}
+		if len(docs) != 2 || docs[0]["a"] != "blue" || docs[1]["b"] != "y" {
+			t.Fatalf("documents mismatch: %#v", docs)
+		}
+	})
+}
+
+func TestIsNodeStart(t *testing.T) {
+	tests := map[string]bool{
+		"":             true,
+		"    ":         true,
+		"key: ":        true,
+		"key:":         false,
+		"  - ":         true,
+		"- - ":         true,
+		"? ":           true,
+		"[":            true,
+		"[a, ":         true,
+		"msg: hello ":  false,
+		"msg: a - ":    false,
+		"url: http:":   false,
+		"{k: ":         true,
+		"text-":        false,
+		"key: value, ": true,
+	}
+
+	for prefix, want := range tests {
+		if got := isNodeStart(prefix); got != want {
+			t.Errorf("isNodeStart(%q) = %v, want %v", prefix, got, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+
+	jyaml "github.com/woozymasta/jamle/yaml"
+	goyaml "go.yaml.in/yaml/v3"
+)
+
+// Template is a parsed document that can be rendered many times with
+// different environments. It is safe for concurrent use when the
+// configured Resolver is.
+type Template struct {
+	root    *goyaml.Node
+	data    []byte
+	opts    UnmarshalOptions
+	hasVars bool
+}
+
+// Compile parses the first YAML document of data once for repeated Render
+// calls, e.g. to render one template for several environments.
+func Compile(data []byte) (*Template, error) {
+	return CompileWithOptions(data, UnmarshalOptions{})
+}
+
+// CompileWithOptions is Compile with options applied to every Render.
+// With ExpandTags, tags are expanded in the source text, so each Render
+// parses data again, as it does for a document without content.
+func CompileWithOptions(data []byte, opts UnmarshalOptions) (*Template, error) {
+	if err := CheckText(data); err != nil {
+		return nil, err
+	}
+
+	t := &Template{
+		data:    bytes.Clone(data),
+		opts:    opts,
+		hasVars: hasExpansionSyntax(data, opts),
+	}
+	if opts.ExpandTags {
+		return t, nil
+	}
+
+	var root goyaml.Node
+	dec := goyaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(false)
+	err := dec.Decode(&root)
+	if errors.Is(err, io.EOF) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t.root = &root
+	return t, nil
+}
+
+// Render expands a copy of the parsed document with variables from env and
+// decodes it into v. A nil env resolves like the compile options do, by
+// default from the process environment.
+func (t *Template) Render(env map[string]string, v any) error {
+	opts := t.opts
+	if env != nil {
+		opts.Env, opts.Sources = env, nil
+	}
+
+	if t.root == nil {
+		return UnmarshalWithOptions(t.data, v, opts)
+	}
+
+	runtime := resolveOptions(opts, reflect.TypeOf(v))
+	root := cloneNode(t.root, make(map[*goyaml.Node]*goyaml.Node))
+	if t.hasVars {
+		if err := expandEnvInNode(root, runtime); err != nil {
+			return err
+		}
+	}
+
+	return jyaml.UnmarshalNode(root, v)
+}
+
+// cloneNode deep-copies n; copied maps anchors to their copies, so aliases
+// of the copy point into the copy.
+func cloneNode(n *goyaml.Node, copied map[*goyaml.Node]*goyaml.Node) *goyaml.Node {
+	if n == nil {
+		return nil
+	}
+	if c, ok := copied[n]; ok {
+		return c
+	}
+
+	c := *n
+	copied[n] = &c
+	if len(n.Content) > 0 {
+		c.Content = make([]*goyaml.Node, len(n.Content))
+		for i, child := range n.Content {
+			c.Content[i] = cloneNode(child, copied)
+		}
+	}
+	c.Alias = cloneNode(n.Alias, copied)
+
+	return &c
+}
@@ -0,0 +1,82 @@
+package jamle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplate_Render(t *testing.T) {
+	tmpl, err := Compile([]byte(`
+base: &base
+  region: ${REGION:-eu}
+  replicas: ${REPLICAS:-1}
+app:
+  <<: *base
+  name: api-${STAGE}
+`))
+	if err != nil {
+		t.Fatalf("Compile returned error: %v", err)
+	}
+
+	type service struct {
+		Region   string `json:"region"`
+		Replicas int    `json:"replicas"`
+		Name     string `json:"name"`
+	}
+
+	stages := []struct {
+		env  map[string]string
+		want service
+	}{
+		{env: map[string]string{"STAGE": "dev"}, want: service{Region: "eu", Replicas: 1, Name: "api-dev"}},
+		{env: map[string]string{"STAGE": "prod", "REGION": "us", "REPLICAS": "3"}, want: service{Region: "us", Replicas: 3, Name: "api-prod"}},
+		{env: map[string]string{"STAGE": "stage"}, want: service{Region: "eu", Replicas: 1, Name: "api-stage"}},
+	}
+	for _, stage := range stages {
+		var cfg struct {
+			App service `json:"app"`
+		}
+		if err := tmpl.Render(stage.env, &cfg); err != nil {
+			t.Fatalf("Render(%v) returned error: %v", stage.env, err)
+		}
+		if cfg.App != stage.want {
+			t.Fatalf("Render(%v) = %+v, want %+v", stage.env, cfg.App, stage.want)
+		}
+	}
+
+	t.Run("render error", func(t *testing.T) {
+		tmpl, err := Compile([]byte("a: ${A:?required}\n"))
+		if err != nil {
+			t.Fatalf("Compile returned error: %v", err)
+		}
+
+		var got map[string]any
+		if err := tmpl.Render(map[string]string{}, &got); err == nil {
+			t.Fatal("expected required error")
+		}
+		if err := tmpl.Render(map[string]string{"A": "ok"}, &got); err != nil || got["a"] != "ok" {
+			t.Fatalf("got %#v, %v", got, err)
+		}
+	})
+
+	t.Run("expand tags", func(t *testing.T) {
+		tmpl, err := CompileWithOptions([]byte("v: !${TAG} 42\n"), UnmarshalOptions{ExpandTags: true})
+		if err != nil {
+			t.Fatalf("CompileWithOptions returned error: %v", err)
+		}
+
+		var got map[string]any
+		if err := tmpl.Render(map[string]string{"TAG": "!str"}, &got); err != nil {
+			t.Fatalf("Render returned error: %v", err)
+		}
+		if !reflect.DeepEqual(got, map[string]any{"v": "42"}) {
+			t.Fatalf("got %#v", got)
+		}
+	})
+
+	t.Run("parse error", func(t *testing.T) {
+		if _, err := Compile([]byte("a: [\n")); err == nil {
+			t.Fatal("expected parse error")
+		}
+	})
+}
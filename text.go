@@ -0,0 +1,31 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+)
+
+// CheckText returns ErrBinaryInput when data does not look like text:
+// it contains a NUL byte or is not valid UTF-8. Input starting with a
+// UTF-16 byte order mark is accepted, as YAML parsers support it.
+// Unmarshal APIs run this check before parsing.
+func CheckText(data []byte) error {
+	if bytes.HasPrefix(data, []byte{0xFF, 0xFE}) || bytes.HasPrefix(data, []byte{0xFE, 0xFF}) {
+		return nil
+	}
+
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return fmt.Errorf("%w: NUL byte at offset %d", ErrBinaryInput, i)
+	}
+
+	if !utf8.Valid(data) {
+		return fmt.Errorf("%w: invalid UTF-8", ErrBinaryInput)
+	}
+
+	return nil
+}
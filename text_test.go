@@ -0,0 +1,66 @@
+package jamle
+
+import (
+	"errors"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestCheckText(t *testing.T) {
+	random := make([]byte, 512)
+	rng := rand.New(rand.NewPCG(1, 2))
+	for i := range random {
+		random[i] = byte(rng.UintN(256))
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+	}{
+		{name: "yaml", data: []byte("a: ${A:-1}\nb: ünïcode\n")},
+		{name: "empty", data: nil},
+		{name: "utf-16 bom", data: []byte{0xFF, 0xFE, 'a', 0, ':', 0}},
+		{name: "nul byte", data: []byte("a: 1\x00"), wantErr: true},
+		{name: "invalid utf-8", data: []byte("a: \xff\xfe\xfd"), wantErr: true},
+		{name: "random bytes", data: random, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckText(tt.data)
+			if tt.wantErr != errors.Is(err, ErrBinaryInput) {
+				t.Fatalf("CheckText error mismatch: %v", err)
+			}
+		})
+	}
+}
+
+func TestUnmarshal_BinaryInput(t *testing.T) {
+	inputs := map[string][]byte{
+		"without placeholders": []byte("\x7fELF\x02\x01\x01\x00\x00\x00"),
+		"with placeholders":    []byte("a: ${A}\n\x00\xff"),
+	}
+
+	for name, data := range inputs {
+		t.Run(name, func(t *testing.T) {
+			var got any
+			if err := Unmarshal(data, &got); !errors.Is(err, ErrBinaryInput) {
+				t.Fatalf("Unmarshal: expected ErrBinaryInput, got %v", err)
+			}
+
+			var docs []any
+			if err := UnmarshalAll(data, &docs); !errors.Is(err, ErrBinaryInput) {
+				t.Fatalf("UnmarshalAll: expected ErrBinaryInput, got %v", err)
+			}
+
+			if _, err := UnmarshalOrdered(data); !errors.Is(err, ErrBinaryInput) {
+				t.Fatalf("UnmarshalOrdered: expected ErrBinaryInput, got %v", err)
+			}
+
+			if _, err := ExpandJSON(data); !errors.Is(err, ErrBinaryInput) {
+				t.Fatalf("ExpandJSON: expected ErrBinaryInput, got %v", err)
+			}
+		})
+	}
+}
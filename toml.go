@@ -0,0 +1,152 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	jyaml "github.com/woozymasta/jamle/yaml"
+	goyaml "go.yaml.in/yaml/v3"
+)
+
+// UnmarshalTOML parses TOML data, expands ${...} in string keys and values,
+// and stores the result in the value pointed to by v using json tags.
+func UnmarshalTOML(data []byte, v any) error {
+	return UnmarshalTOMLWithOptions(data, v, UnmarshalOptions{})
+}
+
+// UnmarshalTOMLWithOptions parses TOML and expands ${...} using configured options.
+func UnmarshalTOMLWithOptions(data []byte, v any, opts UnmarshalOptions) error {
+	if err := CheckText(data); err != nil {
+		return err
+	}
+
+	root, err := decodeTOMLNode(data, opts.RetypeTOMLStrings)
+	if err != nil {
+		return err
+	}
+
+	if hasExpansionSyntax(data, opts) {
+		if err := expandEnvInNode(root, resolveOptions(opts, reflect.TypeOf(v))); err != nil {
+			return err
+		}
+	}
+
+	return jyaml.UnmarshalNode(root, v)
+}
+
+// decodeTOMLNode decodes TOML into a YAML AST, so the regular node expansion
+// and JSON-tag-aware decoding can be reused. Table keys keep their source
+// order. TOML strings with placeholders stay strings unless retype is set.
+func decodeTOMLNode(data []byte, retype bool) (*goyaml.Node, error) {
+	var doc map[string]any
+	md, err := toml.Decode(string(data), &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	order := make(map[string][]string)
+	seen := make(map[string]struct{})
+	for _, key := range md.Keys() {
+		parent := tomlPathID(key[:len(key)-1])
+		id := parent + "\x00" + key[len(key)-1]
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			order[parent] = append(order[parent], key[len(key)-1])
+		}
+	}
+
+	root, err := tomlNode(doc, nil, order)
+	if err != nil {
+		return nil, err
+	}
+
+	// TOML strings are always quoted. With retype, placeholders are re-typed
+	// after expansion the same way as plain YAML scalars ("${PORT}" -> 8080).
+	style := goyaml.DoubleQuotedStyle
+	if retype {
+		style = 0
+	}
+	walkScalarNodes(root, func(n *goyaml.Node) {
+		if n.Tag == "!!str" && strings.Contains(n.Value, "${") {
+			n.Style = style
+		}
+	})
+
+	return root, nil
+}
+
+// tomlNode builds the YAML node for the TOML value at path. Table keys are
+// ordered as listed in order; keys it does not list follow, sorted.
+func tomlNode(v any, path []string, order map[string][]string) (*goyaml.Node, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for _, key := range order[tomlPathID(path)] {
+			if _, ok := v[key]; ok {
+				keys = append(keys, key)
+			}
+		}
+		rest := make([]string, 0, len(v)-len(keys))
+		for key := range v {
+			if !slices.Contains(keys, key) {
+				rest = append(rest, key)
+			}
+		}
+		slices.Sort(rest)
+
+		n := &goyaml.Node{Kind: goyaml.MappingNode, Tag: "!!map"}
+		for _, key := range append(keys, rest...) {
+			child, err := tomlNode(v[key], slices.Concat(path, []string{key}), order)
+			if err != nil {
+				return nil, err
+			}
+
+			keyNode := &goyaml.Node{Kind: goyaml.ScalarNode, Tag: "!!str", Value: key}
+			n.Content = append(n.Content, keyNode, child)
+		}
+
+		return n, nil
+	case []map[string]any:
+		n := &goyaml.Node{Kind: goyaml.SequenceNode, Tag: "!!seq"}
+		for _, elem := range v {
+			child, err := tomlNode(elem, path, order)
+			if err != nil {
+				return nil, err
+			}
+
+			n.Content = append(n.Content, child)
+		}
+
+		return n, nil
+	case []any:
+		n := &goyaml.Node{Kind: goyaml.SequenceNode, Tag: "!!seq"}
+		for _, elem := range v {
+			child, err := tomlNode(elem, path, order)
+			if err != nil {
+				return nil, err
+			}
+
+			n.Content = append(n.Content, child)
+		}
+
+		return n, nil
+	}
+
+	var n goyaml.Node
+	if err := n.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return &n, nil
+}
+
+// tomlPathID joins a TOML key path into a map key.
+func tomlPathID(path []string) string {
+	return strings.Join(path, "\x00")
+}
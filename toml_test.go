@@ -0,0 +1,153 @@
+package jamle
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	goyaml "go.yaml.in/yaml/v3"
+)
+
+func TestUnmarshalTOML(t *testing.T) {
+	type server struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+	type hook struct {
+		Script string `json:"script"`
+	}
+	type cfg struct {
+		Name    string   `json:"name"`
+		Version string   `json:"version"`
+		Server  server   `json:"server"`
+		Hooks   []hook   `json:"hooks"`
+		Tags    []string `json:"tags"`
+	}
+
+	t.Setenv("JAMLE_TOML_HOST", "toml.local")
+
+	input := []byte(`
+name = "${JAMLE_TOML_NAME:-svc}"
+version = "123"
+tags = ["${JAMLE_TOML_HOST}", "static"]
+
+[server]
+host = "${JAMLE_TOML_HOST}"
+port = "${JAMLE_TOML_PORT:-8080}"
+
+[[hooks]]
+script = "echo ${HOME}"
+`)
+
+	var got cfg
+	err := UnmarshalTOMLWithOptions(input, &got, UnmarshalOptions{
+		IgnoreExpandPaths: []string{"hooks.*.script"},
+		RetypeTOMLStrings: true,
+	})
+	if err != nil {
+		t.Fatalf("UnmarshalTOMLWithOptions returned error: %v", err)
+	}
+
+	if got.Name != "svc" || got.Version != "123" {
+		t.Fatalf("top-level values mismatch: %#v", got)
+	}
+	if got.Server.Host != "toml.local" || got.Server.Port != 8080 {
+		t.Fatalf("server values mismatch: %#v", got.Server)
+	}
+	if len(got.Tags) != 2 || got.Tags[0] != "toml.local" {
+		t.Fatalf("tags mismatch: %#v", got.Tags)
+	}
+	if len(got.Hooks) != 1 || got.Hooks[0].Script != "echo ${HOME}" {
+		t.Fatalf("ignored hook script must stay literal: %#v", got.Hooks)
+	}
+}
+
+func TestUnmarshalTOML_Strings(t *testing.T) {
+	input := []byte(`
+version = "${V}"
+port = "${PORT}"
+count = 3
+`)
+	env := map[string]string{"V": "1.10", "PORT": "8080"}
+
+	var got map[string]any
+	if err := UnmarshalTOMLWithOptions(input, &got, UnmarshalOptions{Env: env}); err != nil {
+		t.Fatalf("UnmarshalTOMLWithOptions returned error: %v", err)
+	}
+
+	want := map[string]any{"version": "1.10", "port": "8080", "count": 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("strings must stay strings:\ngot  %#v\nwant %#v", got, want)
+	}
+
+	got = nil
+	if err := UnmarshalTOMLWithOptions(input, &got, UnmarshalOptions{Env: env, RetypeTOMLStrings: true}); err != nil {
+		t.Fatalf("UnmarshalTOMLWithOptions returned error: %v", err)
+	}
+
+	want = map[string]any{"version": 1.1, "port": 8080, "count": 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("retyped values mismatch:\ngot  %#v\nwant %#v", got, want)
+	}
+}
+
+func TestDecodeTOMLNode_KeyOrder(t *testing.T) {
+	input := []byte(`
+zeta = 1
+alpha = "a"
+inline = {y = 1, b = 2}
+
+[server]
+port = 80
+host = "h"
+
+[[hooks]]
+when = "pre"
+script = "x"
+
+[[hooks]]
+script = "y"
+extra = true
+`)
+
+	root, err := decodeTOMLNode(input, false)
+	if err != nil {
+		t.Fatalf("decodeTOMLNode returned error: %v", err)
+	}
+
+	out, err := goyaml.Marshal(root)
+	if err != nil {
+		t.Fatalf("Marshal returned error: %v", err)
+	}
+
+	want := `zeta: 1
+alpha: a
+inline:
+    y: 1
+    b: 2
+server:
+    port: 80
+    host: h
+hooks:
+    - when: pre
+      script: x
+    - script: "y"
+      extra: true
+`
+	if string(out) != want {
+		t.Fatalf("key order mismatch:\ngot\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestUnmarshalTOML_Errors(t *testing.T) {
+	var got map[string]any
+
+	if err := UnmarshalTOML([]byte("a = \n"), &got); err == nil {
+		t.Fatal("expected TOML syntax error")
+	}
+
+	err := UnmarshalTOML([]byte(`a = "${JAMLE_TOML_REQUIRED:?is required}"`), &got)
+	if err == nil || !strings.Contains(err.Error(), "is required") {
+		t.Fatalf("expected required variable error, got: %v", err)
+	}
+}
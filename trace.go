@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+// TraceEvent describes one expansion pass that changed a scalar.
+// It is reported to UnmarshalOptions.Trace.
+type TraceEvent struct {
+	// Scalar is the original scalar value being expanded.
+	Scalar string
+
+	// Before and After hold the scalar text around this pass.
+	// Escaped $${...} placeholders are shown in their final ${...} form.
+	Before string
+	After  string
+
+	// Substitutions lists placeholders replaced in this pass, in order.
+	Substitutions []TraceSubstitution
+
+	// Pass is the 1-based pass number. Values of variables that contain
+	// placeholders themselves are expanded in their own passes, numbered
+	// from 1 again.
+	Pass int
+}
+
+// TraceSubstitution describes one replaced ${...} placeholder.
+type TraceSubstitution struct {
+	// Var is the variable name of the placeholder.
+	Var string
+
+	// Operator is the applied operator, e.g. ":-", "-", ":=", ":?" or "@U".
+	// It is empty for plain ${VAR}.
+	Operator string
+
+	// Value is the text the placeholder was replaced with.
+	Value string
+
+	// Default reports whether the default branch of a - or = operator
+	// (with or without colon) was taken.
+	Default bool
+}
+
+// traceFunc is the callback type used by runtime options.
+type traceFunc func(TraceEvent)
+
+// placeholderOperator returns the operator part of ${...} content.
+func placeholderOperator(content string) string {
+	rest := content[len(placeholderVarName(content)):]
+	switch {
+	case rest == "":
+		return ""
+	case rest[0] == '@':
+		return rest
+	case isExpansionOperator(rest[0]):
+		return rest[:1]
+	case len(rest) > 1 && isExpansionOperator(rest[1]):
+		return rest[:2]
+	default:
+		return ":"
+	}
+}
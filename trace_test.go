@@ -0,0 +1,79 @@
+package jamle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalWithOptions_Trace(t *testing.T) {
+	var events []TraceEvent
+	opts := UnmarshalOptions{
+		Env: map[string]string{"HOST": "db", "REGION": "eu"},
+		Trace: func(e TraceEvent) {
+			events = append(events, e)
+		},
+	}
+
+	input := []byte(`
+url: ${SCHEME:-https}://${HOST}-${REGION@U}/$${RAW}
+nested: ${MISSING:-${HOST}}
+plain: value
+`)
+
+	var out map[string]any
+	if err := UnmarshalWithOptions(input, &out, opts); err != nil {
+		t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+	}
+
+	want := []TraceEvent{
+		{
+			Scalar: "${SCHEME:-https}://${HOST}-${REGION@U}/$${RAW}",
+			Before: "${SCHEME:-https}://${HOST}-${REGION@U}/${RAW}",
+			After:  "https://db-EU/${RAW}",
+			Substitutions: []TraceSubstitution{
+				{Var: "SCHEME", Operator: ":-", Value: "https", Default: true},
+				{Var: "HOST", Value: "db"},
+				{Var: "REGION", Operator: "@U", Value: "EU"},
+			},
+			Pass: 1,
+		},
+		{
+			Scalar:        "${MISSING:-${HOST}}",
+			Before:        "${MISSING:-${HOST}}",
+			After:         "${MISSING:-db}",
+			Substitutions: []TraceSubstitution{{Var: "HOST", Value: "db"}},
+			Pass:          1,
+		},
+		{
+			Scalar:        "${MISSING:-${HOST}}",
+			Before:        "${MISSING:-db}",
+			After:         "db",
+			Substitutions: []TraceSubstitution{{Var: "MISSING", Operator: ":-", Value: "db", Default: true}},
+			Pass:          2,
+		},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("trace mismatch:\ngot  %#v\nwant %#v", events, want)
+	}
+}
+
+func TestPlaceholderOperator(t *testing.T) {
+	tests := map[string]string{
+		"VAR":          "",
+		"VAR:":         ":",
+		"VAR:-x":       ":-",
+		"VAR:=x":       ":=",
+		"VAR-x":        "-",
+		"VAR:+x":       ":+",
+		"VAR+x":        "+",
+		"VAR:?message": ":?",
+		"VAR:x":        ":",
+		"VAR@Q":        "@Q",
+	}
+
+	for content, want := range tests {
+		if got := placeholderOperator(content); got != want {
+			t.Errorf("placeholderOperator(%q) = %q, want %q", content, got, want)
+		}
+	}
+}
@@ -6,10 +6,14 @@ package jamle
 
 import (
 	"bytes"
+	"cmp"
 	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"maps"
 	"reflect"
+	"strings"
 
 	jyaml "github.com/woozymasta/jamle/yaml"
 	goyaml "go.yaml.in/yaml/v3"
@@ -19,7 +23,7 @@ import (
 Unmarshal parses the YAML-encoded data and stores the result in the value pointed to by v.
 
 Before parsing, it recursively expands environment variables within the data.
-The function performs up to 10 passes to resolve nested variables (e.g., ${A:=${B}})
+The function performs up to DefaultMaxPasses passes to resolve nested variables (e.g., ${A:=${B}})
 and prevents infinite loops.
 */
 func Unmarshal(data []byte, v any) error {
@@ -29,26 +33,54 @@ func Unmarshal(data []byte, v any) error {
 // UnmarshalWithOptions parses YAML and expands ${...} using configured options.
 func UnmarshalWithOptions(data []byte, v any, opts UnmarshalOptions) error {
 	// Fast path: if there are no variable markers, decode directly.
-	if !bytes.Contains(data, []byte("${")) {
+	if !hasExpansionSyntax(data, opts) {
+		if err := CheckText(data); err != nil {
+			return err
+		}
+
 		return jyaml.Unmarshal(data, v)
 	}
 
-	resolvedOpts := resolveOptions(opts, reflect.TypeOf(v))
+	return unmarshalExpanded(data, v, resolveOptions(opts, reflect.TypeOf(v)))
+}
+
+// unmarshalExpanded expands the first YAML document and decodes it into v.
+func unmarshalExpanded(data []byte, v any, opts runtimeOptions) error {
+	root, err := decodeExpandedNode(data, opts)
+	if err != nil {
+		return err
+	}
+
+	// Decode from transformed AST directly to avoid YAML re-encode/re-decode.
+	return jyaml.UnmarshalNode(root, v)
+}
+
+// decodeExpandedNode parses the first YAML document and expands ${...} in it.
+func decodeExpandedNode(data []byte, opts runtimeOptions) (*goyaml.Node, error) {
+	if err := CheckText(data); err != nil {
+		return nil, err
+	}
+
+	if opts.expandTags {
+		var err error
+		if data, err = expandTagTokens(data, opts); err != nil {
+			return nil, err
+		}
+	}
 
 	// Parse into YAML AST (comments are stored in node fields, not in scalar values)
 	var root goyaml.Node
 	dec := goyaml.NewDecoder(bytes.NewReader(data))
 	dec.KnownFields(false)
 	if err := dec.Decode(&root); err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := expandEnvInNode(&root, resolvedOpts); err != nil {
-		return err
+	if err := expandEnvInNode(&root, opts); err != nil {
+		return nil, err
 	}
 
-	// Decode from transformed AST directly to avoid YAML re-encode/re-decode.
-	return jyaml.UnmarshalNode(&root, v)
+	return &root, nil
 }
 
 // UnmarshalAll parses all YAML documents from the input stream and appends
@@ -67,10 +99,20 @@ func UnmarshalAllWithOptions(data []byte, out any, opts UnmarshalOptions) error
 		return fmt.Errorf("%w, got %T", ErrOutMustBePointerToSlice, out)
 	}
 
+	if err := CheckText(data); err != nil {
+		return err
+	}
+
 	sliceValue := outValue.Elem()
 	elemType := sliceValue.Type().Elem()
 	resolvedOpts := resolveOptions(opts, elemType)
-	containsVars := bytes.Contains(data, []byte("${"))
+	containsVars := hasExpansionSyntax(data, opts)
+	if containsVars && resolvedOpts.expandTags {
+		var err error
+		if data, err = expandTagTokens(data, resolvedOpts); err != nil {
+			return err
+		}
+	}
 
 	dec := goyaml.NewDecoder(bytes.NewReader(data))
 	dec.KnownFields(false)
@@ -125,13 +167,54 @@ func decodeDocument(root *goyaml.Node, elemType reflect.Type) (reflect.Value, er
 // resolveOptions normalizes options and applies defaults.
 func resolveOptions(opts UnmarshalOptions, outType reflect.Type) runtimeOptions {
 	resolver := opts.Resolver
-	if resolver == nil {
+	var names iter.Seq[string]
+	switch {
+	case len(opts.Sources) > 0:
+		resolver = &overlayResolver{
+			base:     sourcesResolver(opts.Sources),
+			assigned: make(map[string]string),
+		}
+	case opts.Environ != nil:
+		env := mergeEnviron(opts.Environ, opts.Env)
+		resolver = &overlayResolver{
+			base:     mapEnvResolver(env),
+			assigned: make(map[string]string),
+		}
+		names = maps.Keys(env)
+	case opts.Env != nil:
+		resolver = &overlayResolver{
+			base:     mapEnvResolver(opts.Env),
+			assigned: make(map[string]string),
+		}
+		names = maps.Keys(opts.Env)
+	case resolver == nil:
 		resolver = envResolver{}
+		names = processEnvNames()
+	}
+
+	var foldedNames map[string][]string
+	if opts.CaseInsensitive && names != nil {
+		foldedNames = foldEnvNames(names, opts.Prefix)
+		// Environment names shadow Defaults keys instead of clashing.
+		for name := range opts.Defaults {
+			if _, ok := foldedNames[strings.ToUpper(name)]; !ok {
+				addFoldedName(foldedNames, name)
+			}
+		}
+	}
+	if !opts.SuggestVariables {
+		names = nil
+	}
+	if opts.Prefix != "" {
+		resolver = prefixResolver{base: resolver, prefix: opts.Prefix}
+	}
+	if len(opts.Defaults) > 0 {
+		resolver = defaultsResolver{base: resolver, defaults: opts.Defaults}
 	}
 
 	maxPasses := opts.MaxPasses
 	if maxPasses <= 0 {
-		maxPasses = defaultMaxPasses
+		maxPasses = DefaultMaxPasses
 	}
 
 	ignorePaths := append([]string{}, opts.IgnoreExpandPaths...)
@@ -142,8 +225,42 @@ func resolveOptions(opts UnmarshalOptions, outType reflect.Type) runtimeOptions
 	runtime := runtimeOptions{
 		resolver:        resolver,
 		maxPasses:       maxPasses,
+		setOnly:         opts.SetOnly,
 		allowAssignment: !opts.DisableAssignment,
+		literalValues:   opts.DisableResolvedExpansion,
+		rejectAssign:    opts.DisallowAssign,
 		enforceRequired: !opts.DisableRequiredErrors,
+		unsetFormat:     opts.UnsetErrorFormat,
+		validators:      opts.Validators,
+		transform:       opts.Transform,
+		keepStrings:     opts.KeepStrings,
+		parseYAML:       opts.ParseExpandedYAML,
+		errorOnDefault:  opts.ErrorOnDefaultUsed,
+		errorOnUnset:    opts.ErrorOnUnset,
+		yaml11Bools:     opts.YAMLBooleans == YAMLBooleans11,
+		trace:           opts.Trace,
+		logger:          opts.Logger,
+		logValues:       opts.LogValues,
+		emptyIsSet:      opts.EmptyIsSet,
+		expandTags:      opts.ExpandTags,
+		escapes:         opts.InterpretEscapes,
+		windowsVars:     opts.WindowsVars,
+		arithmetic:      opts.Arithmetic,
+		strictDollar:    opts.StrictDollar,
+		escapePrefix:    cmp.Or(opts.EscapePrefix, DefaultEscapePrefix),
+		escapeErr:       checkEscapePrefix(opts.EscapePrefix),
+		keepKeys:        opts.DisableKeyExpansion,
+		keepHeader:      opts.PreserveDocumentHeader,
+		trailingNewline: opts.TrailingNewline,
+		foldedNames:     foldedNames,
+		suggestNames:    names,
+		prefix:          opts.Prefix,
+	}
+	if len(opts.OnlyVars) > 0 {
+		runtime.onlyVars = make(map[string]struct{}, len(opts.OnlyVars))
+		for _, name := range opts.OnlyVars {
+			runtime.onlyVars[name] = struct{}{}
+		}
 	}
 	if len(ignorePaths) == 0 {
 		return runtime
@@ -0,0 +1,147 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidateIntRange returns a validator that accepts base-10 integers
+// between minValue and maxValue inclusive.
+func ValidateIntRange(minValue, maxValue int) func(string) error {
+	return func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < minValue || n > maxValue {
+			return fmt.Errorf("must be an integer between %d and %d, got %q", minValue, maxValue, value)
+		}
+
+		return nil
+	}
+}
+
+// ValidateOneOf returns a validator that accepts only the listed values.
+func ValidateOneOf(values ...string) func(string) error {
+	return func(value string) error {
+		if !slices.Contains(values, value) {
+			return fmt.Errorf("must be one of %s, got %q", strings.Join(values, ", "), value)
+		}
+
+		return nil
+	}
+}
+
+// requiredTypeHint splits the operand of ${VAR:?...} into a type hint
+// (int, float, bool, url or duration) and the custom message after it,
+// as in ${PORT:?int:need a port}. Other operands are a message only.
+func requiredTypeHint(operand string) (string, string) {
+	hint, msg, _ := strings.Cut(operand, ":")
+	switch hint {
+	case "int", "float", "bool", "url", "duration":
+		return hint, msg
+	}
+
+	return "", operand
+}
+
+// checkTypeHint reports a value of variable name that does not parse as
+// hint, prefixed by msg when it is not empty.
+func checkTypeHint(name, hint, msg, value string) error {
+	var err error
+	switch hint {
+	case "":
+		return nil
+	case "int":
+		if _, parseErr := strconv.ParseInt(value, 10, 64); parseErr != nil {
+			err = fmt.Errorf("must be an integer, got %q", value)
+		}
+	case "float":
+		if _, parseErr := strconv.ParseFloat(value, 64); parseErr != nil {
+			err = fmt.Errorf("must be a number, got %q", value)
+		}
+	case "bool":
+		switch value {
+		case "true", "True", "TRUE", "false", "False", "FALSE":
+		default:
+			err = fmt.Errorf("must be true or false, got %q", value)
+		}
+	case "url":
+		if u, parseErr := url.Parse(value); parseErr != nil || u.Scheme == "" || (u.Host == "" && u.Opaque == "") {
+			err = fmt.Errorf("must be an absolute URL, got %q", value)
+		}
+	case "duration":
+		if _, parseErr := time.ParseDuration(value); parseErr != nil {
+			err = fmt.Errorf("must be a duration such as 30s, got %q", value)
+		}
+	}
+	if err == nil {
+		return nil
+	}
+
+	if msg != "" {
+		return fmt.Errorf("%w for %q: %s: %w", ErrValidation, name, msg, err)
+	}
+
+	return fmt.Errorf("%w for %q: %w", ErrValidation, name, err)
+}
+
+// validate runs the validator registered for the variable of a resolved
+// ${...} placeholder. Alternate values and transforms are not validated.
+func (st *scalarState) validate(content, resolved string) error {
+	if len(st.opts.validators) == 0 {
+		return nil
+	}
+
+	name := placeholderVarName(content)
+	fn, ok := st.opts.validators[name]
+	if !ok {
+		return nil
+	}
+
+	switch op := placeholderOperator(content); {
+	case op == "+", op == ":+", strings.HasPrefix(op, "@"):
+		return nil
+	}
+
+	if err := fn(unmaskReplacer.Replace(resolved)); err != nil {
+		return &ExpandError{Var: name, Err: fmt.Errorf("%w for %q: %w", ErrValidation, name, err)}
+	}
+
+	return nil
+}
+
+// transform passes the resolved value of a placeholder through
+// opts.transform and returns the masked result. Like validators, it skips
+// `${VAR:+alt}` and `${VAR@X}`. The result is used literally.
+func (st *scalarState) transform(content, resolved string) (string, error) {
+	if st.opts.transform == nil {
+		return resolved, nil
+	}
+
+	switch op := placeholderOperator(content); {
+	case op == "+", op == ":+", strings.HasPrefix(op, "@"):
+		return resolved, nil
+	}
+
+	name := placeholderVarName(content)
+	value := unmaskReplacer.Replace(resolved)
+	out, err := st.opts.transform(name, value)
+	if err != nil {
+		return "", &ExpandError{Var: name, Err: fmt.Errorf("%w for %q: %w", ErrValueTransform, name, err)}
+	}
+	if out == value {
+		return resolved, nil
+	}
+
+	if strings.ContainsAny(out, maskStart+maskEnd+maskDollar) {
+		return "", &ExpandError{Var: name, Err: fmt.Errorf("%w: control character in transformed value of %q", ErrBinaryInput, name)}
+	}
+
+	return strings.ReplaceAll(out, "${", maskStart), nil
+}
@@ -0,0 +1,180 @@
+package jamle
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalWithOptions_Validators(t *testing.T) {
+	validators := map[string]func(string) error{
+		"PORT":      ValidateIntRange(1, 65535),
+		"LOG_LEVEL": ValidateOneOf("debug", "info", "warn", "error"),
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "numeric from env", input: "port: ${PORT}", env: map[string]string{"PORT": "8080"}},
+		{name: "numeric default", input: "port: ${PORT:-443}"},
+		{name: "numeric not a number", input: "port: ${PORT}", env: map[string]string{"PORT": "http"}, wantErr: `"PORT": must be an integer between 1 and 65535, got "http"`},
+		{name: "numeric out of range", input: "port: ${PORT:-70000}", wantErr: `"PORT": must be an integer between 1 and 65535, got "70000"`},
+		{name: "numeric unset", input: "port: ${PORT}", wantErr: `got ""`},
+		{name: "enum", input: "level: ${LOG_LEVEL:-info}"},
+		{name: "enum rejected", input: "level: ${LOG_LEVEL}", env: map[string]string{"LOG_LEVEL": "verbose"}, wantErr: `"LOG_LEVEL": must be one of debug, info, warn, error, got "verbose"`},
+		{name: "alternate skipped", input: "tls: ${PORT:+enabled}", env: map[string]string{"PORT": "x"}},
+		{name: "transform skipped", input: "level: ${LOG_LEVEL@U}", env: map[string]string{"LOG_LEVEL": "info"}},
+		{name: "unvalidated variable", input: "host: ${HOST:-anything}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := tt.env
+			if env == nil {
+				env = map[string]string{}
+			}
+
+			var got map[string]any
+			err := UnmarshalWithOptions([]byte(tt.input), &got, UnmarshalOptions{Env: env, Validators: validators})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("UnmarshalWithOptions returned error: %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrValidation) {
+				t.Fatalf("expected ErrValidation, got %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q does not contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnmarshalWithOptions_Transform(t *testing.T) {
+	env := map[string]string{
+		"NAME":       "  app \n",
+		"SECRET_B64": base64.StdEncoding.EncodeToString([]byte("s3cr3t")),
+		"RAW_B64":    base64.StdEncoding.EncodeToString([]byte("${NOT_EXPANDED}")),
+		"BROKEN_B64": "not base64!",
+	}
+	transform := func(name, value string) (string, error) {
+		if strings.HasSuffix(name, "_B64") {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			return string(decoded), err
+		}
+		return strings.TrimSpace(value), nil
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "trim", input: "[${NAME}]", want: "[app]"},
+		{name: "trim default", input: "${MISSING:-  padded  }", want: "padded"},
+		{name: "decode", input: "${SECRET_B64}", want: "s3cr3t"},
+		{name: "decoded value is literal", input: "${RAW_B64}", want: "${NOT_EXPANDED}"},
+		{name: "alternate skipped", input: "${NAME:+  alt  }", want: "  alt  "},
+		{name: "decode error", input: "${BROKEN_B64}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandStringWithOptions(tt.input, UnmarshalOptions{Env: env, Transform: transform})
+			if tt.wantErr {
+				if !errors.Is(err, ErrValueTransform) || !strings.Contains(err.Error(), `"BROKEN_B64"`) {
+					t.Fatalf("expected ErrValueTransform naming the variable, got %q, %v", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandStringWithOptions returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("value mismatch: got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var cfg struct {
+		Port int `json:"port"`
+	}
+	err := UnmarshalWithOptions([]byte("port: ${PORT}\n"), &cfg, UnmarshalOptions{
+		Env:        map[string]string{"PORT": " 8080 "},
+		Transform:  transform,
+		Validators: map[string]func(string) error{"PORT": ValidateIntRange(1, 65535)},
+	})
+	if err != nil || cfg.Port != 8080 {
+		t.Fatalf("validator should see the transformed value, got %d, %v", cfg.Port, err)
+	}
+}
+
+func TestExpandStringWithOptions_RequiredTypeHints(t *testing.T) {
+	env := map[string]string{
+		"PORT": "8080", "BAD_PORT": "80a",
+		"RATIO": "0.5", "BAD_RATIO": "half",
+		"DEBUG": "true", "BAD_DEBUG": "yes",
+		"ENDPOINT": "https://api.example.com/v1", "BAD_ENDPOINT": "api.example.com",
+		"TIMEOUT": "1m30s", "BAD_TIMEOUT": "90",
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{name: "int", input: "${PORT:?int}", want: "8080"},
+		{name: "int invalid", input: "${BAD_PORT:?int}", wantErr: `must be an integer, got "80a"`},
+		{name: "float", input: "${RATIO:?float}", want: "0.5"},
+		{name: "float invalid", input: "${BAD_RATIO:?float}", wantErr: `must be a number, got "half"`},
+		{name: "bool", input: "${DEBUG:?bool}", want: "true"},
+		{name: "bool invalid", input: "${BAD_DEBUG:?bool}", wantErr: `must be true or false, got "yes"`},
+		{name: "url", input: "${ENDPOINT:?url}", want: "https://api.example.com/v1"},
+		{name: "url invalid", input: "${BAD_ENDPOINT:?url}", wantErr: `must be an absolute URL, got "api.example.com"`},
+		{name: "duration", input: "${TIMEOUT:?duration}", want: "1m30s"},
+		{name: "duration invalid", input: "${BAD_TIMEOUT:?duration}", wantErr: `must be a duration such as 30s, got "90"`},
+		{name: "custom message", input: "${BAD_PORT:?int:PORT must be numeric}", wantErr: `PORT must be numeric: must be an integer`},
+		{name: "unset with hint", input: "${MISSING:?int}", wantErr: `"MISSING" is not set or empty`},
+		{name: "unset with hint and message", input: "${MISSING:?int:need a port}", wantErr: `"MISSING" need a port`},
+		{name: "no-colon form", input: "${BAD_PORT?int}", wantErr: `must be an integer`},
+		{name: "plain message", input: "${MISSING:?integer please}", wantErr: `integer please`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandStringWithOptions(tt.input, UnmarshalOptions{Env: env})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	_, err := ExpandStringWithOptions("${BAD_PORT:?int}", UnmarshalOptions{Env: env})
+	var expandErr *ExpandError
+	if !errors.Is(err, ErrValidation) || !errors.As(err, &expandErr) || expandErr.Var != "BAD_PORT" {
+		t.Fatalf("expected ErrValidation for BAD_PORT, got %v", err)
+	}
+
+	got, err := ExpandStringWithOptions("${BAD_PORT:?int}", UnmarshalOptions{Env: env, DisableRequiredErrors: true})
+	if err != nil || got != "80a" {
+		t.Fatalf("DisableRequiredErrors: got %q, %v", got, err)
+	}
+}
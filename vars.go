@@ -0,0 +1,190 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+
+	goyaml "go.yaml.in/yaml/v3"
+)
+
+// ExtractVariables returns sorted unique variable names referenced by
+// ${...} placeholders in all YAML documents of data, including names used
+// inside nested defaults. Escaped $${...} placeholders and comments are
+// ignored. Nothing is resolved or expanded.
+func ExtractVariables(data []byte) ([]string, error) {
+	refs, err := referencedVars(data)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// UnusedVars returns sorted names of env that are not referenced by any
+// ${...} placeholder in data. Only the document is inspected; references
+// inside env values themselves are not followed.
+func UnusedVars(data []byte, env map[string]string) ([]string, error) {
+	refs, err := referencedVars(data)
+	if err != nil {
+		return nil, err
+	}
+
+	unused := make([]string, 0, len(env))
+	for name := range env {
+		if _, ok := refs[name]; !ok {
+			unused = append(unused, name)
+		}
+	}
+	slices.Sort(unused)
+
+	return unused, nil
+}
+
+// MissingRequired returns sorted unique names of variables referenced by
+// ${VAR:?message} or ${VAR?message} placeholders in data that env does not
+// satisfy: unset or empty for the colon form, unset for the other. All
+// placeholders are checked, including those in default branches that would
+// not be reached, so the result lists every variable that may fail.
+// Nothing is expanded and env is not modified.
+func MissingRequired(data []byte, env map[string]string) ([]string, error) {
+	// required maps a name to whether any of its placeholders uses :?.
+	required := make(map[string]bool)
+	err := scanScalars(data, func(value string) {
+		collectRequiredVars(value, required)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for name, colon := range required {
+		value, ok := env[name]
+		if !ok || (colon && value == "") {
+			missing = append(missing, name)
+		}
+	}
+	slices.Sort(missing)
+
+	return missing, nil
+}
+
+// referencedVars collects variable names referenced in all documents.
+func referencedVars(data []byte) (map[string]struct{}, error) {
+	refs := make(map[string]struct{})
+	err := scanScalars(data, func(value string) {
+		collectScalarVars(value, refs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return refs, nil
+}
+
+// scanScalars calls fn with the value of every scalar node in all documents
+// of data that contain a ${...} placeholder.
+func scanScalars(data []byte, fn func(string)) error {
+	if !bytes.Contains(data, []byte("${")) {
+		return nil
+	}
+
+	dec := goyaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var root goyaml.Node
+		err := dec.Decode(&root)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		walkScalarNodes(&root, func(n *goyaml.Node) {
+			if strings.Contains(n.Value, "${") {
+				fn(n.Value)
+			}
+		})
+	}
+}
+
+// collectScalarVars adds variable names of all non-escaped ${...} in s.
+func collectScalarVars(s string, refs map[string]struct{}) {
+	if !strings.Contains(s, "${") {
+		return
+	}
+
+	s = maskEscapedVars(s, DefaultEscapePrefix)
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			return
+		}
+
+		name := leadingVarName(s[i+2:])
+		if rest, ok := strings.CutPrefix(s[i+2+len(name):], ":"); ok {
+			if _, ok := backendFor(name, rest); ok {
+				s = s[i+2:]
+				continue
+			}
+		}
+		if isValidVarName(name) {
+			refs[name] = struct{}{}
+		}
+
+		start := i + 2 + len(name)
+		if strings.HasPrefix(s[start:], ":") {
+			start++
+		}
+		if start < len(s) && (s[start] == '-' || s[start] == '=') {
+			if end, ok := findClosingBraceIndex(s, i+1); ok {
+				collectBareVars(s[start+1:end], refs)
+			}
+		}
+
+		s = s[i+2:]
+	}
+}
+
+// collectRequiredVars adds names of all non-escaped ${VAR:?...} and
+// ${VAR?...} in s, recording whether the colon form is used.
+func collectRequiredVars(s string, required map[string]bool) {
+	s = maskEscapedVars(s, DefaultEscapePrefix)
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			return
+		}
+
+		s = s[i+2:]
+		name := leadingVarName(s)
+		rest := s[len(name):]
+		colon := strings.HasPrefix(rest, ":?")
+		if !isValidVarName(name) || (!colon && !strings.HasPrefix(rest, "?")) {
+			continue
+		}
+		required[name] = required[name] || colon
+	}
+}
+
+// collectBareVars adds names of bare $NAME references in a default value.
+func collectBareVars(s string, refs map[string]struct{}) {
+	for i := 0; i < len(s); i++ {
+		if name := bareVarName(s, i); name != "" {
+			refs[name] = struct{}{}
+			i += len(name)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package jamle
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractVariables(t *testing.T) {
+	input := []byte(`
+# ${COMMENTED} is ignored
+${KEY_PREFIX}_name: ${HOST:-${FALLBACK_HOST}}
+bare: ${BARE_OUTER:-$BARE_DEFAULT/$$ESCAPED_BARE} $NOT_IN_DEFAULT ${NO_COLON-$NO_COLON_DEFAULT}
+port: ${PORT:?required}
+escaped: $${ESCAPED}
+upper: ${REGION@U}
+list:
+  - ${HOST}
+---
+second: ${SECOND_DOC}
+`)
+
+	got, err := ExtractVariables(input)
+	if err != nil {
+		t.Fatalf("ExtractVariables returned error: %v", err)
+	}
+
+	want := []string{
+		"BARE_DEFAULT", "BARE_OUTER", "FALLBACK_HOST", "HOST", "KEY_PREFIX",
+		"NO_COLON", "NO_COLON_DEFAULT", "PORT", "REGION", "SECOND_DOC",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("variables mismatch:\ngot  %v\nwant %v", got, want)
+	}
+
+	if _, err := ExtractVariables([]byte("a: ${X}\n  b: [\n")); err == nil {
+		t.Fatal("expected parse error")
+	}
+}
+
+func TestUnusedVars(t *testing.T) {
+	env := map[string]string{
+		"HOST":     "svc.local",
+		"ZETA":     "unused",
+		"ALPHA":    "unused",
+		"ESCAPED":  "unused",
+		"FALLBACK": "used in default",
+	}
+
+	got, err := UnusedVars([]byte("host: ${HOST:-${FALLBACK}}\nlit: $${ESCAPED}\n"), env)
+	if err != nil {
+		t.Fatalf("UnusedVars returned error: %v", err)
+	}
+	if want := []string{"ALPHA", "ESCAPED", "ZETA"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unused mismatch: got %v, want %v", got, want)
+	}
+
+	got, err = UnusedVars(nil, map[string]string{"B": "", "A": ""})
+	if err != nil || !reflect.DeepEqual(got, []string{"A", "B"}) {
+		t.Fatalf("empty document mismatch: got %v, err %v", got, err)
+	}
+}
+
+func TestMissingRequired(t *testing.T) {
+	input := []byte(`
+# ${COMMENTED:?ignored}
+db:
+  user: ${DB_USER:?database user is required}
+  password: ${DB_PASSWORD:?}
+  host: ${DB_HOST:-${DB_FALLBACK:?no host}}
+token: ${TOKEN?token must be defined}
+empty_ok: ${EMPTY_OK?}
+optional: ${OPTIONAL:-none}
+escaped: $${ESCAPED:?kept}
+${KEY:?key required}: value
+---
+region: ${REGION:?region is required}
+`)
+	env := map[string]string{
+		"DB_USER":  "app",
+		"EMPTY_OK": "",
+		"KEY":      "",
+		"REGION":   "eu",
+	}
+
+	got, err := MissingRequired(input, env)
+	if err != nil {
+		t.Fatalf("MissingRequired returned error: %v", err)
+	}
+
+	want := []string{"DB_FALLBACK", "DB_PASSWORD", "KEY", "TOKEN"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("missing mismatch:\ngot  %v\nwant %v", got, want)
+	}
+	if len(env) != 4 {
+		t.Fatalf("env must not be modified: %v", env)
+	}
+
+	got, err = MissingRequired([]byte("a: ${A:?}\n"), map[string]string{"A": "set"})
+	if err != nil || len(got) != 0 {
+		t.Fatalf("expected no missing variables, got %v, %v", got, err)
+	}
+
+	if _, err := MissingRequired([]byte("a: ${X:?}\n  b: [\n"), nil); err == nil {
+		t.Fatal("expected parse error")
+	}
+}
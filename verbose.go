@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	"reflect"
+	"slices"
+)
+
+// UnmarshalVerbose works like UnmarshalWithOptions and also returns
+// non-fatal warnings about the expansion: defaults taken, `${VAR:=default}`
+// assignments made through the resolver, and plain placeholders that
+// resolved to an empty string. Each warning starts with the variable name;
+// the list is sorted and deduplicated. Values are never included, so the
+// warnings are safe to log. Warnings collected before an error are returned
+// along with it.
+func UnmarshalVerbose(data []byte, v any, opts UnmarshalOptions) ([]string, error) {
+	if !hasExpansionSyntax(data, opts) {
+		return nil, UnmarshalWithOptions(data, v, opts)
+	}
+
+	seen := make(map[string]struct{})
+	runtime := resolveOptions(opts, reflect.TypeOf(v))
+	runtime.warn = func(msg string) {
+		seen[msg] = struct{}{}
+	}
+
+	err := unmarshalExpanded(data, v, runtime)
+
+	warnings := make([]string, 0, len(seen))
+	for msg := range seen {
+		warnings = append(warnings, msg)
+	}
+	slices.Sort(warnings)
+
+	return warnings, err
+}
+
+// warnf reports a warning for variable name when warnings are collected.
+func (st *scalarState) warnf(name, msg string) {
+	if st.opts.warn != nil {
+		st.opts.warn(name + ": " + msg)
+	}
+}
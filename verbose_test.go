@@ -0,0 +1,61 @@
+package jamle
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalVerbose(t *testing.T) {
+	input := []byte(`
+host: ${HOST}
+port: ${PORT:-8080}
+region: ${REGION:=eu}
+empty: ${EMPTY}
+missing: ${MISSING}
+again: ${PORT:-9090}
+token: ${TOKEN}
+`)
+
+	env := map[string]string{"HOST": "db", "EMPTY": "", "TOKEN": "secret-value"}
+
+	var got map[string]any
+	warnings, err := UnmarshalVerbose(input, &got, UnmarshalOptions{Env: env})
+	if err != nil {
+		t.Fatalf("UnmarshalVerbose returned error: %v", err)
+	}
+
+	want := []string{
+		"EMPTY: is set but empty",
+		"MISSING: is not set, expanded to empty string",
+		"PORT: default value used",
+		"REGION: default value assigned to environment",
+		"REGION: default value used",
+	}
+	if !reflect.DeepEqual(warnings, want) {
+		t.Fatalf("warnings mismatch:\ngot  %q\nwant %q", warnings, want)
+	}
+	if got["port"] != 8080 || got["region"] != "eu" {
+		t.Fatalf("decoded document mismatch: %#v", got)
+	}
+
+	t.Run("no placeholders", func(t *testing.T) {
+		var got map[string]any
+		warnings, err := UnmarshalVerbose([]byte("a: 1\n"), &got, UnmarshalOptions{})
+		if err != nil || len(warnings) != 0 || got["a"] != 1 {
+			t.Fatalf("unexpected result: warnings=%q got=%#v err=%v", warnings, got, err)
+		}
+	})
+
+	t.Run("warnings before error", func(t *testing.T) {
+		var got map[string]any
+		warnings, err := UnmarshalVerbose([]byte("a: ${A:-x}\nb: ${B:?required}\n"), &got, UnmarshalOptions{Env: map[string]string{}})
+		var expandErr *ExpandError
+		if !errors.As(err, &expandErr) || expandErr.Var != "B" {
+			t.Fatalf("expected ExpandError for B, got %v", err)
+		}
+		if !reflect.DeepEqual(warnings, []string{"A: default value used"}) {
+			t.Fatalf("warnings mismatch: %q", warnings)
+		}
+	})
+}
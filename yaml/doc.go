@@ -20,6 +20,9 @@ Core APIs:
   - Marshal encodes a Go value as YAML.
   - YAMLToJSON and JSONToYAML convert between formats.
 
+Fields typed json.RawMessage receive the JSON encoding of their YAML
+subtree, so nested sections can be handed to other JSON decoders as-is.
+
 Optional I/O helpers:
   - ReadFile reads and decodes YAML/JSON from file.
   - WriteFile encodes and writes YAML/JSON to file.
@@ -5,7 +5,9 @@
 package yaml
 
 import (
+	"encoding/json"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -19,15 +21,27 @@ var structFieldLookupCache struct {
 }
 
 // structFieldLookup stores exact and folded JSON-key lookup for one struct.
+// embedKeys holds the go-yaml keys of embedded structs that promote fields.
 type structFieldLookup struct {
-	exact  map[string]decodeField
-	folded map[string]decodeField
+	exact     map[string]decodeField
+	folded    map[string]decodeField
+	embedKeys map[string]struct{}
 }
 
+// rawMessageType is the reflect type of json.RawMessage targets.
+var rawMessageType = reflect.TypeFor[json.RawMessage]()
+
+// nodeType is the reflect type of goyaml.Node targets, which keep the
+// subtree as written.
+var nodeType = reflect.TypeFor[goyaml.Node]()
+
 // decodeField links JSON-key matches to YAML decode target metadata.
+// embedPath lists the go-yaml keys of the embedded structs that promote
+// the field, outermost first; it is empty for direct fields.
 type decodeField struct {
 	typ       reflect.Type
 	decodeKey string
+	embedPath []string
 }
 
 // UnmarshalNode decodes a YAML node into target using JSON-tag key mapping.
@@ -60,7 +74,11 @@ func remapJSONTagKeys(n *goyaml.Node, target reflect.Type) {
 	}
 
 	target = derefType(target)
-	if target == nil {
+	if target == nil || target == nodeType {
+		return
+	}
+	if target == rawMessageType {
+		encodeRawMessageNode(n)
 		return
 	}
 
@@ -89,6 +107,48 @@ func remapJSONTagKeys(n *goyaml.Node, target reflect.Type) {
 	}
 }
 
+// encodeRawMessageNode replaces a subtree decoded into json.RawMessage
+// with its JSON encoding. go-yaml cannot decode scalars into byte slices,
+// so the bytes are emitted as a sequence of integers. Null nodes and
+// subtrees that cannot be represented as JSON are left for go-yaml to report.
+func encodeRawMessageNode(n *goyaml.Node) {
+	if n.Kind == goyaml.ScalarNode && n.ShortTag() == "!!null" {
+		return
+	}
+
+	var v any
+	if err := n.Decode(&v); err != nil {
+		return
+	}
+
+	jsonable, err := convertToJSONableObject(v, nil)
+	if err != nil {
+		return
+	}
+
+	raw, err := json.Marshal(jsonable)
+	if err != nil {
+		return
+	}
+
+	content := make([]*goyaml.Node, len(raw))
+	for i, b := range raw {
+		content[i] = &goyaml.Node{
+			Kind:  goyaml.ScalarNode,
+			Tag:   "!!int",
+			Value: strconv.Itoa(int(b)),
+		}
+	}
+
+	*n = goyaml.Node{
+		Kind:    goyaml.SequenceNode,
+		Tag:     "!!seq",
+		Content: content,
+		Line:    n.Line,
+		Column:  n.Column,
+	}
+}
+
 // remapMappingNodeKeys remaps mapping keys for struct/map targets recursively.
 func remapMappingNodeKeys(n *goyaml.Node, target reflect.Type) {
 	switch target.Kind() {
@@ -106,8 +166,12 @@ func remapMappingNodeKeys(n *goyaml.Node, target reflect.Type) {
 }
 
 // remapStructMappingNodeKeys remaps one struct mapping node by JSON tag rules.
+// go-yaml does not flatten embedded structs, so keys of promoted fields are
+// moved into nested mappings under the embedded fields' keys.
 func remapStructMappingNodeKeys(n *goyaml.Node, target reflect.Type) {
 	lookup := cachedStructFieldLookup(target)
+	content := make([]*goyaml.Node, 0, len(n.Content))
+	embedded := make(map[string]*goyaml.Node)
 	for i := 0; i+1 < len(n.Content); i += 2 {
 		keyNode := n.Content[i]
 		valNode := n.Content[i+1]
@@ -117,12 +181,50 @@ func remapStructMappingNodeKeys(n *goyaml.Node, target reflect.Type) {
 			decodedField, ok = lookup.folded[strings.ToLower(keyNode.Value)]
 		}
 		if !ok {
+			// JSON never decodes a key into an embedded struct itself;
+			// keeping it would collide with the nested mapping below.
+			if _, clash := lookup.embedKeys[keyNode.Value]; !clash {
+				content = append(content, keyNode, valNode)
+			}
 			continue
 		}
 
 		keyNode.Value = decodedField.decodeKey
 		remapJSONTagKeys(valNode, decodedField.typ)
+		if len(decodedField.embedPath) == 0 {
+			content = append(content, keyNode, valNode)
+			continue
+		}
+
+		parent := embeddedMappingNode(&content, embedded, decodedField.embedPath)
+		parent.Content = append(parent.Content, keyNode, valNode)
+	}
+
+	n.Content = content
+}
+
+// embeddedMappingNode returns the mapping node for an embedded struct path,
+// creating it and its parents in content on first use.
+func embeddedMappingNode(content *[]*goyaml.Node, embedded map[string]*goyaml.Node, path []string) *goyaml.Node {
+	var parent *goyaml.Node
+	for i := range path {
+		id := strings.Join(path[:i+1], "\x00")
+		node, ok := embedded[id]
+		if !ok {
+			node = &goyaml.Node{Kind: goyaml.MappingNode, Tag: "!!map"}
+			key := &goyaml.Node{Kind: goyaml.ScalarNode, Tag: "!!str", Value: path[i]}
+			if parent == nil {
+				*content = append(*content, key, node)
+			} else {
+				parent.Content = append(parent.Content, key, node)
+			}
+			embedded[id] = node
+		}
+
+		parent = node
 	}
+
+	return parent
 }
 
 // cachedStructFieldLookup returns cached struct key lookup metadata.
@@ -150,8 +252,9 @@ func cachedStructFieldLookup(t reflect.Type) structFieldLookup {
 func buildStructFieldLookup(t reflect.Type) structFieldLookup {
 	fields := cachedTypeFields(t)
 	lookup := structFieldLookup{
-		exact:  make(map[string]decodeField, len(fields)),
-		folded: make(map[string]decodeField, len(fields)),
+		exact:     make(map[string]decodeField, len(fields)),
+		folded:    make(map[string]decodeField, len(fields)),
+		embedKeys: make(map[string]struct{}),
 	}
 
 	for i := range fields {
@@ -164,6 +267,10 @@ func buildStructFieldLookup(t reflect.Type) structFieldLookup {
 		df := decodeField{
 			decodeKey: yamlDecodeKeyForField(sf),
 			typ:       sf.Type,
+			embedPath: embeddedDecodePath(t, ff.index),
+		}
+		for _, key := range df.embedPath {
+			lookup.embedKeys[key] = struct{}{}
 		}
 
 		if _, exists := lookup.exact[ff.name]; !exists {
@@ -179,6 +286,22 @@ func buildStructFieldLookup(t reflect.Type) structFieldLookup {
 	return lookup
 }
 
+// embeddedDecodePath returns the go-yaml keys of the embedded structs along
+// index, skipping embeds tagged `yaml:",inline"` that go-yaml flattens itself.
+func embeddedDecodePath(t reflect.Type, index []int) []string {
+	var path []string
+	for _, i := range index[:len(index)-1] {
+		sf := t.Field(i)
+		if _, opts, _ := strings.Cut(sf.Tag.Get("yaml"), ","); !tagOptions(opts).Contains("inline") {
+			path = append(path, yamlDecodeKeyForField(sf))
+		}
+
+		t = derefType(sf.Type)
+	}
+
+	return path
+}
+
 // derefType strips pointers until a concrete type is reached.
 func derefType(t reflect.Type) reflect.Type {
 	for t != nil && t.Kind() == reflect.Ptr {
@@ -2,6 +2,7 @@ package yaml
 
 import (
 	"bytes"
+	"encoding/json"
 	"reflect"
 	"strings"
 	"testing"
@@ -63,6 +64,114 @@ func TestUnmarshalNode_AnonymousEmbeddedInline(t *testing.T) {
 	}
 }
 
+func TestUnmarshalNode_AnonymousEmbeddedFlatten(t *testing.T) {
+	t.Parallel()
+
+	type Base struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+	type Meta struct {
+		Owner string `json:"owner"`
+	}
+	type Deep struct {
+		Base
+		Zone string `json:"zone"`
+	}
+	type flat struct {
+		Base
+		*Meta
+		Mode string `json:"mode"`
+	}
+	type nested struct {
+		Deep
+		Mode string `json:"mode"`
+	}
+	type shadow struct {
+		Base
+		Host string `json:"host"`
+	}
+	type tagged struct {
+		Base `json:"base"`
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		out   any
+		want  any
+	}{
+		{
+			name:  "untagged",
+			input: "host: db\nPORT: 5432\nowner: ops\nmode: x\n",
+			out:   &flat{},
+			want:  &flat{Base: Base{Host: "db", Port: 5432}, Meta: &Meta{Owner: "ops"}, Mode: "x"},
+		},
+		{
+			name:  "nested",
+			input: "host: db\nzone: eu\nmode: x\n",
+			out:   &nested{},
+			want:  &nested{Deep: Deep{Base: Base{Host: "db"}, Zone: "eu"}, Mode: "x"},
+		},
+		{
+			name:  "shadowed",
+			input: "host: outer\n",
+			out:   &shadow{},
+			want:  &shadow{Host: "outer"},
+		},
+		{
+			name:  "embed key ignored",
+			input: "base: {host: nope}\nport: 1\n",
+			out:   &flat{},
+			want:  &flat{Base: Base{Port: 1}},
+		},
+		{
+			name:  "tagged",
+			input: "base:\n  host: db\n",
+			out:   &tagged{},
+			want:  &tagged{Base: Base{Host: "db"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := unmarshalNodeFromString(tt.input, tt.out); err != nil {
+				t.Fatalf("UnmarshalNode returned error: %v", err)
+			}
+			if !reflect.DeepEqual(tt.out, tt.want) {
+				t.Fatalf("decoded value mismatch: got %#v, want %#v", tt.out, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalNode_NodeTarget(t *testing.T) {
+	t.Parallel()
+
+	type sample struct {
+		Name string      `json:"name"`
+		Raw  goyaml.Node `json:"raw"`
+	}
+
+	var got sample
+	if err := unmarshalNodeFromString("NAME: x\nraw:\n  Value: 1\n  kind: 2\n", &got); err != nil {
+		t.Fatalf("UnmarshalNode returned error: %v", err)
+	}
+	if got.Name != "x" || len(got.Raw.Content) != 4 || got.Raw.Content[0].Value != "Value" || got.Raw.Content[2].Value != "kind" {
+		t.Fatalf("node field must keep its keys: %#v", got.Raw.Content)
+	}
+
+	var node goyaml.Node
+	if err := unmarshalNodeFromString("Tag: a\nb: 1\n", &node); err != nil {
+		t.Fatalf("UnmarshalNode returned error: %v", err)
+	}
+	if node.Kind != goyaml.MappingNode || node.Content[0].Value != "Tag" {
+		t.Fatalf("node target must keep its keys: %#v", node)
+	}
+}
+
 func TestUnmarshalNode_PointerSliceMap(t *testing.T) {
 	t.Parallel()
 
@@ -99,6 +208,70 @@ dict:
 	}
 }
 
+func TestUnmarshalNode_RawMessage(t *testing.T) {
+	t.Parallel()
+
+	type sample struct {
+		Plugin   json.RawMessage            `json:"plugin"`
+		Scalar   json.RawMessage            `json:"scalar"`
+		Missing  json.RawMessage            `json:"missing"`
+		Null     json.RawMessage            `json:"null_value"`
+		List     []json.RawMessage          `json:"list"`
+		ByName   map[string]json.RawMessage `json:"by_name"`
+		Pointer  *json.RawMessage           `json:"pointer"`
+		Name     string                     `json:"name"`
+		Trailing int                        `json:"trailing"`
+	}
+
+	input := `
+name: root
+plugin:
+  host: svc.local
+  port: 8080
+  tags: [a, b]
+scalar: "text"
+null_value: null
+list:
+  - {a: 1}
+  - 2
+by_name:
+  one: {enabled: true}
+pointer: [1, 2]
+trailing: 3
+`
+
+	var got sample
+	if err := unmarshalNodeFromString(input, &got); err != nil {
+		t.Fatalf("UnmarshalNode returned error: %v", err)
+	}
+
+	checks := map[string]string{
+		"plugin":  string(got.Plugin),
+		"scalar":  string(got.Scalar),
+		"list[0]": string(got.List[0]),
+		"list[1]": string(got.List[1]),
+		"by_name": string(got.ByName["one"]),
+		"pointer": string(*got.Pointer),
+	}
+	want := map[string]string{
+		"plugin":  `{"host":"svc.local","port":8080,"tags":["a","b"]}`,
+		"scalar":  `"text"`,
+		"list[0]": `{"a":1}`,
+		"list[1]": `2`,
+		"by_name": `{"enabled":true}`,
+		"pointer": `[1,2]`,
+	}
+	if !reflect.DeepEqual(checks, want) {
+		t.Fatalf("raw message mismatch:\ngot  %#v\nwant %#v", checks, want)
+	}
+	if got.Missing != nil || got.Null != nil {
+		t.Fatalf("expected nil raw messages, got missing=%q null=%q", got.Missing, got.Null)
+	}
+	if got.Name != "root" || got.Trailing != 3 {
+		t.Fatalf("sibling fields mismatch: %#v", got)
+	}
+}
+
 func TestUnmarshalNode_DuplicateAfterCaseFold(t *testing.T) {
 	t.Parallel()
 
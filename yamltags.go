@@ -0,0 +1,36 @@
+// SPDX-License-Identifier: MIT
+// Copyright (c) 2026 WoozyMasta
+// Source: github.com/woozymasta/jamle
+
+package jamle
+
+import (
+	goyaml "go.yaml.in/yaml/v3"
+)
+
+// UnmarshalYAMLTags parses YAML, expands ${...} and decodes the result with
+// go.yaml.in/yaml/v3, so `yaml:` struct tags and yaml.Unmarshaler
+// implementations are honored instead of `json:` tags.
+func UnmarshalYAMLTags(data []byte, v any) error {
+	return UnmarshalYAMLTagsWithOptions(data, v, UnmarshalOptions{})
+}
+
+// UnmarshalYAMLTagsWithOptions is UnmarshalYAMLTags with configured options.
+// `jamle:"noexpand"` tags are not applied, because their paths follow json
+// field names; use IgnoreExpandPaths instead.
+func UnmarshalYAMLTagsWithOptions(data []byte, v any, opts UnmarshalOptions) error {
+	if err := CheckText(data); err != nil {
+		return err
+	}
+
+	if !hasExpansionSyntax(data, opts) {
+		return goyaml.Unmarshal(data, v)
+	}
+
+	root, err := decodeExpandedNode(data, resolveOptions(opts, nil))
+	if err != nil {
+		return err
+	}
+
+	return root.Decode(v)
+}
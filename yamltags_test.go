@@ -0,0 +1,80 @@
+package jamle
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	goyaml "go.yaml.in/yaml/v3"
+)
+
+// yamlTagsLevel is a custom yaml.Unmarshaler that accepts names or numbers.
+type yamlTagsLevel int
+
+func (l *yamlTagsLevel) UnmarshalYAML(node *goyaml.Node) error {
+	switch strings.ToLower(node.Value) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 3
+	default:
+		var n int
+		if err := node.Decode(&n); err != nil {
+			return fmt.Errorf("invalid level %q", node.Value)
+		}
+		*l = yamlTagsLevel(n)
+	}
+
+	return nil
+}
+
+type yamlTagsConfig struct {
+	ServerHost string        `yaml:"server_host"`
+	Port       int           `yaml:"port"`
+	Level      yamlTagsLevel `yaml:"level"`
+	Debug      bool          `yaml:"debug"`
+	Tags       []string      `yaml:"tags"`
+}
+
+func TestUnmarshalYAMLTags(t *testing.T) {
+	t.Setenv("JAMLE_YAMLTAGS_HOST", "db.local")
+	t.Setenv("JAMLE_YAMLTAGS_LEVEL", "High")
+
+	data := []byte(`server_host: ${JAMLE_YAMLTAGS_HOST}
+port: ${JAMLE_YAMLTAGS_PORT:-5432}
+level: ${JAMLE_YAMLTAGS_LEVEL}
+debug: ${JAMLE_YAMLTAGS_DEBUG:-true}
+tags:
+  - ${JAMLE_YAMLTAGS_HOST}
+  - static
+`)
+
+	var cfg yamlTagsConfig
+	if err := UnmarshalYAMLTags(data, &cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := yamlTagsConfig{ServerHost: "db.local", Port: 5432, Level: 3, Debug: true, Tags: []string{"db.local", "static"}}
+	if fmt.Sprint(cfg) != fmt.Sprint(want) {
+		t.Fatalf("got %+v, want %+v", cfg, want)
+	}
+
+	// Without placeholders the document is decoded directly.
+	var plain yamlTagsConfig
+	if err := UnmarshalYAMLTags([]byte("server_host: h\nlevel: low\n"), &plain); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plain.ServerHost != "h" || plain.Level != 1 {
+		t.Fatalf("got %+v", plain)
+	}
+
+	err := UnmarshalYAMLTagsWithOptions([]byte("level: ${LEVEL}\n"), &cfg, UnmarshalOptions{Env: map[string]string{"LEVEL": "medium"}})
+	if err == nil || !strings.Contains(err.Error(), `invalid level "medium"`) {
+		t.Fatalf("expected UnmarshalYAML error, got %v", err)
+	}
+
+	err = UnmarshalYAMLTagsWithOptions([]byte("port: ${PORT:?}\n"), &cfg, UnmarshalOptions{Env: map[string]string{}})
+	if err == nil || !strings.Contains(err.Error(), `"PORT"`) {
+		t.Fatalf("expected required error, got %v", err)
+	}
+}